@@ -1,16 +1,34 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/follow1123/photos/common"
 )
 
 const (
-	DATA_DIR  = "photos"
-	FILES_DIR = "files"
+	DATA_DIR   = "photos"
+	FILES_DIR  = "files"
+	CACHE_DIR  = "cache"
+	RENDER_DIR = "render"
+	// 断点续传未完成的文件
+	UPLOADS_DIR = "uploads"
+
+	CONFIG_FILE = "config.json"
+
+	// 单次上传请求解码图片可以使用的内存，默认 512MB
+	DEFAULT_MEMORY_BUDGET int64 = 512 << 20
+
+	// 回收站内的图片默认保存 30 天
+	DEFAULT_TRASH_RETENTION = 30 * 24 * time.Hour
+
+	// 回忆默认包含前后 3 天的图片
+	DEFAULT_MEMORIES_WINDOW_DAYS = 3
 )
 
 func WithAddress(addr string) common.Option[Config] {
@@ -25,9 +43,64 @@ func WithPath(path string) common.Option[Config] {
 	})
 }
 
+// WithMemoryBudget 单次上传请求解码图片可以使用的内存（字节），渲染请求共用相同大小的预算
+func WithMemoryBudget(budget int64) common.Option[Config] {
+	return common.OptionFunc[Config](func(c *Config) {
+		c.memoryBudget = budget
+	})
+}
+
+// WithTrashRetention 回收站内图片的保存时间，超过时间后彻底删除，0 表示不自动删除
+func WithTrashRetention(retention time.Duration) common.Option[Config] {
+	return common.OptionFunc[Config](func(c *Config) {
+		c.trashRetention = &retention
+	})
+}
+
+// WithMemoriesWindowDays 回忆包含前后多少天内拍摄的图片
+func WithMemoriesWindowDays(days int) common.Option[Config] {
+	return common.OptionFunc[Config](func(c *Config) {
+		c.memoriesWindowDays = &days
+	})
+}
+
+// WithRemoteCredentials 访问 ftp、scp 上的图片使用的账号
+func WithRemoteCredentials(credentials ...RemoteCredential) common.Option[Config] {
+	return common.OptionFunc[Config](func(c *Config) {
+		c.remotes = credentials
+	})
+}
+
+// RemoteCredential 访问 ftp、scp 上的图片使用的账号
+type RemoteCredential struct {
+	// 协议和主机，如 ftp://nas.local、scp://nas.local:2222，没有端口时匹配所有端口
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// scp 登录使用的私钥文件
+	PrivateKeyFile string `json:"privateKeyFile"`
+	// scp 校验主机使用的 known_hosts 文件，默认使用 ~/.ssh/known_hosts
+	KnownHostsFile string `json:"knownHostsFile"`
+}
+
 type Config struct {
-	address    string
-	prefixPath string
+	address        string
+	prefixPath     string
+	memoryBudget   int64
+	trashRetention *time.Duration
+
+	memoriesWindowDays *int
+	remotes            []RemoteCredential
+}
+
+// fileConfig 数据目录下 config.json 内的配置，未配置的字段使用默认值
+type fileConfig struct {
+	Address            string `json:"address"`
+	MemoryBudget       int64  `json:"memoryBudget"`
+	TrashRetentionDays *int   `json:"trashRetentionDays"`
+	MemoriesWindowDays *int   `json:"memoriesWindowDays"`
+
+	Remotes []RemoteCredential `json:"remotes"`
 }
 
 func NewConfig(opts ...common.Option[Config]) *Config {
@@ -42,13 +115,84 @@ func NewConfig(opts ...common.Option[Config]) *Config {
 	if conf.prefixPath == "" {
 		conf.prefixPath = initPath()
 	}
+	if conf.memoryBudget <= 0 {
+		conf.memoryBudget = DEFAULT_MEMORY_BUDGET
+	}
+	if conf.trashRetention == nil {
+		retention := DEFAULT_TRASH_RETENTION
+		conf.trashRetention = &retention
+	}
+	if conf.memoriesWindowDays == nil || *conf.memoriesWindowDays < 0 {
+		days := DEFAULT_MEMORIES_WINDOW_DAYS
+		conf.memoriesWindowDays = &days
+	}
 	return conf
 }
 
+// LoadFile 读取数据目录下的配置文件，文件不存在时不做处理
+func (c *Config) LoadFile() error {
+	data, err := os.ReadFile(c.GetConfigFilePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return err
+	}
+	if fc.Address != "" {
+		c.address = fc.Address
+	}
+	if fc.MemoryBudget > 0 {
+		c.memoryBudget = fc.MemoryBudget
+	}
+	if fc.TrashRetentionDays != nil && *fc.TrashRetentionDays >= 0 {
+		retention := time.Duration(*fc.TrashRetentionDays) * 24 * time.Hour
+		c.trashRetention = &retention
+	}
+	if fc.MemoriesWindowDays != nil && *fc.MemoriesWindowDays >= 0 {
+		c.memoriesWindowDays = fc.MemoriesWindowDays
+	}
+	if len(fc.Remotes) > 0 {
+		c.remotes = fc.Remotes
+	}
+	return nil
+}
+
 func (c *Config) GetFilesPath() string {
 	return filepath.Join(c.prefixPath, FILES_DIR)
 }
 
+func (c *Config) GetRenderCachePath() string {
+	return filepath.Join(c.prefixPath, CACHE_DIR, RENDER_DIR)
+}
+
+func (c *Config) GetUploadsPath() string {
+	return filepath.Join(c.prefixPath, UPLOADS_DIR)
+}
+
+func (c *Config) GetConfigFilePath() string {
+	return filepath.Join(c.prefixPath, CONFIG_FILE)
+}
+
+func (c *Config) GetMemoryBudget() int64 {
+	return c.memoryBudget
+}
+
+func (c *Config) GetTrashRetention() time.Duration {
+	return *c.trashRetention
+}
+
+func (c *Config) GetMemoriesWindowDays() int {
+	return *c.memoriesWindowDays
+}
+
+func (c *Config) GetRemoteCredentials() []RemoteCredential {
+	return c.remotes
+}
+
 func (c *Config) GetAddr() string {
 	return c.address
 }
@@ -71,6 +215,14 @@ func (c *Config) CreatePath() error {
 	if err != nil {
 		return err
 	}
+	err = os.MkdirAll(c.GetRenderCachePath(), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.GetUploadsPath(), 0755)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
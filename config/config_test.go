@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -23,4 +24,28 @@ func (s *ConfigTestSuite) TestInitConfig() {
 	s.Equal(":8080", conf.GetAddr())
 	s.Equal(filepath.Join(home, ".local/share", DATA_DIR), conf.GetPrefixPath())
 	s.Equal(filepath.Join(home, ".local/share", DATA_DIR, FILES_DIR), conf.GetFilesPath())
+	s.Equal(filepath.Join(home, ".local/share", DATA_DIR, UPLOADS_DIR), conf.GetUploadsPath())
+}
+
+func (s *ConfigTestSuite) TestLoadFile() {
+	conf := NewConfig(WithPath(s.T().TempDir()))
+	s.Equal(DEFAULT_MEMORY_BUDGET, conf.GetMemoryBudget())
+
+	// 配置文件不存在时使用默认值
+	s.Nil(conf.LoadFile())
+	s.Equal(":8080", conf.GetAddr())
+
+	s.Equal(DEFAULT_TRASH_RETENTION, conf.GetTrashRetention())
+	s.Equal(DEFAULT_MEMORIES_WINDOW_DAYS, conf.GetMemoriesWindowDays())
+	s.Empty(conf.GetRemoteCredentials())
+
+	data := []byte(`{"address": ":9090", "memoryBudget": 1048576, "trashRetentionDays": 7, "memoriesWindowDays": 0,
+		"remotes": [{"server": "ftp://nas.local", "username": "photos", "password": "secret"}]}`)
+	s.Nil(os.WriteFile(conf.GetConfigFilePath(), data, 0644))
+	s.Nil(conf.LoadFile())
+	s.Equal(":9090", conf.GetAddr())
+	s.Equal(int64(1048576), conf.GetMemoryBudget())
+	s.Equal(7*24*time.Hour, conf.GetTrashRetention())
+	s.Equal(0, conf.GetMemoriesWindowDays())
+	s.Equal([]RemoteCredential{{Server: "ftp://nas.local", Username: "photos", Password: "secret"}}, conf.GetRemoteCredentials())
 }
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ALBUM_API_GETBYID     string = "/album/:id"
+	ALBUM_API_LIST               = "/album"
+	ALBUM_API_CREATE             = ALBUM_API_LIST
+	ALBUM_API_UPDATE             = ALBUM_API_LIST
+	ALBUM_API_DELETE             = ALBUM_API_GETBYID
+	ALBUM_API_PHOTOS             = ALBUM_API_GETBYID + "/photos"
+	ALBUM_API_PHOTOS_SORT        = ALBUM_API_PHOTOS + "/sort"
+)
+
+type AlbumController struct {
+	logger.AppLogger
+	ctx  *application.AppContext
+	serv service.AlbumService
+}
+
+func NewAlbumController(ctx *application.AppContext, service service.AlbumService) *AlbumController {
+	return &AlbumController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+}
+
+func (ac *AlbumController) GetAlbumById(c *gin.Context) {
+	param := &dto.AlbumIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	albumDto, err := ac.serv.GetAlbumById(param.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, albumDto)
+}
+
+func (ac *AlbumController) AlbumPage(c *gin.Context) {
+	pageParam := dto.PageParam[struct{}]{}
+	if err := c.BindQuery(&pageParam); err != nil {
+		return
+	}
+	result, err := ac.serv.AlbumPage(pageParam)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (ac *AlbumController) CreateAlbum(c *gin.Context) {
+	var param dto.CreateAlbumParam
+	if err := c.BindJSON(&param); err != nil {
+		return
+	}
+	albumDto, err := ac.serv.CreateAlbum(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, albumDto)
+}
+
+func (ac *AlbumController) UpdateAlbum(c *gin.Context) {
+	var param dto.AlbumParam
+	if err := c.BindJSON(&param); err != nil {
+		return
+	}
+	albumDto, err := ac.serv.UpdateAlbum(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, albumDto)
+}
+
+func (ac *AlbumController) DeleteAlbum(c *gin.Context) {
+	param := &dto.AlbumIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	if err := ac.serv.DeleteAlbum(param.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindAlbumPhotos 绑定路径内的相册 id 和请求体内的图片 id
+func (ac *AlbumController) bindAlbumPhotos(c *gin.Context) (*dto.AlbumIdParam, *dto.AlbumPhotosParam, bool) {
+	param := &dto.AlbumIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return nil, nil, false
+	}
+	photosParam := &dto.AlbumPhotosParam{}
+	if err := c.BindJSON(photosParam); err != nil {
+		return nil, nil, false
+	}
+	return param, photosParam, true
+}
+
+func (ac *AlbumController) AddPhotos(c *gin.Context) {
+	param, photosParam, ok := ac.bindAlbumPhotos(c)
+	if !ok {
+		return
+	}
+	if err := ac.serv.AddPhotos(param.ID, photosParam.PhotoIDs); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ac *AlbumController) RemovePhotos(c *gin.Context) {
+	param, photosParam, ok := ac.bindAlbumPhotos(c)
+	if !ok {
+		return
+	}
+	if err := ac.serv.RemovePhotos(param.ID, photosParam.PhotoIDs); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ac *AlbumController) SortPhotos(c *gin.Context) {
+	param, photosParam, ok := ac.bindAlbumPhotos(c)
+	if !ok {
+		return
+	}
+	if err := ac.serv.SortPhotos(param.ID, photosParam.PhotoIDs); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ac *AlbumController) SetHandleMapping(engine *gin.Engine) {
+	engine.GET(ALBUM_API_GETBYID, ac.GetAlbumById)
+	engine.GET(ALBUM_API_LIST, ac.AlbumPage)
+	engine.POST(ALBUM_API_CREATE, ac.CreateAlbum)
+	engine.PUT(ALBUM_API_UPDATE, ac.UpdateAlbum)
+	engine.DELETE(ALBUM_API_DELETE, ac.DeleteAlbum)
+	engine.POST(ALBUM_API_PHOTOS, ac.AddPhotos)
+	engine.DELETE(ALBUM_API_PHOTOS, ac.RemovePhotos)
+	engine.PUT(ALBUM_API_PHOTOS_SORT, ac.SortPhotos)
+}
@@ -0,0 +1,194 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/controller"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/mocks"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AlbumAPISuite struct {
+	suite.Suite
+	r    *gin.Engine
+	serv *mocks.AlbumService
+}
+
+func TestAlbumAPISuite(t *testing.T) {
+	suite.Run(t, &AlbumAPISuite{})
+}
+
+func (s *AlbumAPISuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	ws, err := appgen.GenWebServer(appComponents)
+	s.Nil(err)
+	s.serv = &mocks.AlbumService{}
+
+	ws.InitMiddleware()
+
+	ws.SetRouters(
+		controller.NewAlbumController(ctx, s.serv),
+	)
+	ws.InitRouter()
+
+	s.r = ws.GetEngine()
+}
+
+func (s *AlbumAPISuite) TestGetAlbumById() {
+	scenarios := []struct {
+		uri          string
+		err          error
+		expectedCode int
+	}{
+		{"/album/1", nil, http.StatusOK},
+		{"/album/1", application.ErrDataNotFound, http.StatusNotFound},
+		{"/album/a", nil, http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		var albumDto *dto.AlbumDto
+		if scenario.err == nil {
+			albumDto = &dto.AlbumDto{ID: 1, Name: "album"}
+		}
+		s.serv.On("GetAlbumById", mock.Anything).Return(albumDto, scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", scenario.uri, nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("GetAlbumById").Unset()
+	}
+}
+
+func (s *AlbumAPISuite) TestAlbumPage() {
+	expectedData := dto.PageResult[dto.AlbumDto]{
+		List:     []dto.AlbumDto{{ID: 1, Name: "album"}},
+		PageNum:  1,
+		PageSize: 10,
+		Total:    1,
+	}
+	s.serv.On("AlbumPage", mock.Anything).Return(&expectedData, nil)
+	defer s.serv.On("AlbumPage").Unset()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/album?pageNum=1&pageSize=10", nil)
+	s.r.ServeHTTP(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	expectedDataJson, err := json.Marshal(expectedData)
+	s.Nil(err)
+	s.Equal(string(expectedDataJson), w.Body.String())
+}
+
+func (s *AlbumAPISuite) TestCreateAlbum() {
+	scenarios := []struct {
+		body         string
+		expectedCode int
+	}{
+		{`{"name":"album","desc":"desc"}`, http.StatusCreated},
+		{`{"desc":"desc"}`, http.StatusBadRequest},
+		{`{"name":"album","startDate":"2024-01-01T00:00:00Z"}`, http.StatusCreated},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On("CreateAlbum", mock.Anything).Return(&dto.AlbumDto{ID: 1}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/album", strings.NewReader(scenario.body))
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("CreateAlbum").Unset()
+	}
+}
+
+func (s *AlbumAPISuite) TestUpdateAlbum() {
+	scenarios := []struct {
+		body         string
+		err          error
+		expectedCode int
+	}{
+		{`{"id":1,"coverPhotoId":2}`, nil, http.StatusOK},
+		{`{"coverPhotoId":2}`, nil, http.StatusBadRequest},
+		{`{"id":1,"coverPhotoId":3}`, application.NewAppError(http.StatusBadRequest, "cover"), http.StatusBadRequest},
+		{`{"id":2}`, application.ErrDataNotFound, http.StatusNotFound},
+	}
+
+	for _, scenario := range scenarios {
+		var albumDto *dto.AlbumDto
+		if scenario.err == nil {
+			albumDto = &dto.AlbumDto{ID: 1}
+		}
+		s.serv.On("UpdateAlbum", mock.Anything).Return(albumDto, scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/album", strings.NewReader(scenario.body))
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("UpdateAlbum").Unset()
+	}
+}
+
+func (s *AlbumAPISuite) TestDeleteAlbum() {
+	scenarios := []struct {
+		err          error
+		expectedCode int
+	}{
+		{nil, http.StatusNoContent},
+		{application.ErrDataNotFound, http.StatusNotFound},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On("DeleteAlbum", mock.Anything).Return(scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/album/1", nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("DeleteAlbum").Unset()
+	}
+}
+
+func (s *AlbumAPISuite) TestAlbumPhotos() {
+	scenarios := []struct {
+		method       string
+		uri          string
+		serviceFunc  string
+		body         string
+		err          error
+		expectedCode int
+	}{
+		{"POST", "/album/1/photos", "AddPhotos", `{"photoIds":[1,2]}`, nil, http.StatusNoContent},
+		{"POST", "/album/1/photos", "AddPhotos", `{"photoIds":[]}`, nil, http.StatusBadRequest},
+		{"POST", "/album/1/photos", "AddPhotos", `{"photoIds":[1]}`, application.ErrDataNotFound, http.StatusNotFound},
+		{"DELETE", "/album/1/photos", "RemovePhotos", `{"photoIds":[1]}`, nil, http.StatusNoContent},
+		{"DELETE", "/album/a/photos", "RemovePhotos", `{"photoIds":[1]}`, nil, http.StatusBadRequest},
+		{"PUT", "/album/1/photos/sort", "SortPhotos", `{"photoIds":[2,1]}`, nil, http.StatusNoContent},
+		{"PUT", "/album/1/photos/sort", "SortPhotos", `{"photoIds":[3]}`, application.NewAppError(http.StatusBadRequest, "sort"), http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On(scenario.serviceFunc, mock.Anything, mock.Anything).Return(scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(scenario.method, scenario.uri, strings.NewReader(scenario.body))
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario.uri)
+
+		s.serv.On(scenario.serviceFunc).Unset()
+	}
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DATE_SHIFT_API_CREATE  string = "/date-shift"
+	DATE_SHIFT_API_GETBYID        = DATE_SHIFT_API_CREATE + "/:id"
+	DATE_SHIFT_API_UNDO           = DATE_SHIFT_API_GETBYID + "/undo"
+)
+
+type DateShiftController struct {
+	logger.AppLogger
+	ctx  *application.AppContext
+	serv service.DateShiftService
+}
+
+func NewDateShiftController(ctx *application.AppContext, service service.DateShiftService) *DateShiftController {
+	return &DateShiftController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+}
+
+func (dc *DateShiftController) ShiftDates(c *gin.Context) {
+	var param dto.DateShiftParam
+	if err := c.BindJSON(&param); err != nil {
+		return
+	}
+	dateShiftDto, err := dc.serv.ShiftDates(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if param.DryRun {
+		c.JSON(http.StatusOK, dateShiftDto)
+		return
+	}
+	c.JSON(http.StatusCreated, dateShiftDto)
+}
+
+func (dc *DateShiftController) GetDateShift(c *gin.Context) {
+	param := &dto.DateShiftIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	dateShiftDto, err := dc.serv.GetDateShift(param.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, dateShiftDto)
+}
+
+func (dc *DateShiftController) UndoDateShift(c *gin.Context) {
+	param := &dto.DateShiftIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	dateShiftDto, err := dc.serv.UndoDateShift(param.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, dateShiftDto)
+}
+
+func (dc *DateShiftController) SetHandleMapping(engine *gin.Engine) {
+	engine.POST(DATE_SHIFT_API_CREATE, dc.ShiftDates)
+	engine.GET(DATE_SHIFT_API_GETBYID, dc.GetDateShift)
+	engine.POST(DATE_SHIFT_API_UNDO, dc.UndoDateShift)
+}
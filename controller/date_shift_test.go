@@ -0,0 +1,99 @@
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/controller"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/mocks"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DateShiftAPISuite struct {
+	suite.Suite
+	r    *gin.Engine
+	serv *mocks.DateShiftService
+}
+
+func TestDateShiftAPISuite(t *testing.T) {
+	suite.Run(t, &DateShiftAPISuite{})
+}
+
+func (s *DateShiftAPISuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	ws, err := appgen.GenWebServer(appComponents)
+	s.Nil(err)
+	s.serv = &mocks.DateShiftService{}
+
+	ws.InitMiddleware()
+
+	ws.SetRouters(
+		controller.NewDateShiftController(ctx, s.serv),
+	)
+	ws.InitRouter()
+
+	s.r = ws.GetEngine()
+}
+
+func (s *DateShiftAPISuite) TestShiftDates() {
+	scenarios := []struct {
+		body         string
+		expectedCode int
+	}{
+		{`{"photoIds": [1], "offset": "-1h"}`, http.StatusCreated},
+		{`{"query": "tag:trip", "fromZone": "UTC", "toZone": "Asia/Tokyo", "dryRun": true}`, http.StatusOK},
+		{`{"photoIds": [1], "fromZone": "UTC"}`, http.StatusBadRequest},
+		{`{"photoIds": [0], "offset": "1h"}`, http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On("ShiftDates", mock.Anything).Return(&dto.DateShiftDto{}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/date-shift", strings.NewReader(scenario.body))
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario.body)
+
+		s.serv.On("ShiftDates").Unset()
+	}
+}
+
+func (s *DateShiftAPISuite) TestGetAndUndoDateShift() {
+	scenarios := []struct {
+		method       string
+		httpMethod   string
+		uri          string
+		err          error
+		expectedCode int
+	}{
+		{"GetDateShift", "GET", "/date-shift/1", nil, http.StatusOK},
+		{"GetDateShift", "GET", "/date-shift/1", application.ErrDataNotFound, http.StatusNotFound},
+		{"GetDateShift", "GET", "/date-shift/a", nil, http.StatusBadRequest},
+		{"UndoDateShift", "POST", "/date-shift/1/undo", nil, http.StatusOK},
+		{"UndoDateShift", "POST", "/date-shift/1/undo", application.NewAppError(http.StatusBadRequest, "已经撤销"), http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		var dateShiftDto *dto.DateShiftDto
+		if scenario.err == nil {
+			dateShiftDto = &dto.DateShiftDto{}
+		}
+		s.serv.On(scenario.method, mock.Anything).Return(dateShiftDto, scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(scenario.httpMethod, scenario.uri, nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario.uri)
+
+		s.serv.On(scenario.method).Unset()
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	JOB_API_GETBYID string = "/jobs/:id"
+	JOB_API_EVENTS         = JOB_API_GETBYID + "/events"
+)
+
+type JobController struct {
+	logger.AppLogger
+	ctx  *application.AppContext
+	serv service.JobService
+}
+
+func NewJobController(ctx *application.AppContext, service service.JobService) *JobController {
+	return &JobController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+}
+
+func (jc *JobController) GetJob(c *gin.Context) {
+	param := &dto.JobIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	job, err := jc.serv.GetJob(param.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// JobEvents 使用 SSE 推送任务的进度，任务完成后结束
+func (jc *JobController) JobEvents(c *gin.Context) {
+	param := &dto.JobIdParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	events, unsubscribe, err := jc.serv.SubscribeJob(param.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-store")
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Event, event.Data)
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			// 客户端断开连接
+			return
+		}
+	}
+}
+
+func (jc *JobController) SetHandleMapping(engine *gin.Engine) {
+	engine.GET(JOB_API_GETBYID, jc.GetJob)
+	engine.GET(JOB_API_EVENTS, jc.JobEvents)
+}
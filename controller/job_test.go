@@ -0,0 +1,99 @@
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/controller"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/mocks"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobAPISuite struct {
+	suite.Suite
+	r    *gin.Engine
+	serv *mocks.JobService
+}
+
+func TestJobAPISuite(t *testing.T) {
+	suite.Run(t, &JobAPISuite{})
+}
+
+func (s *JobAPISuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	ws, err := appgen.GenWebServer(appComponents)
+	s.Nil(err)
+	s.serv = &mocks.JobService{}
+
+	ws.InitMiddleware()
+
+	ws.SetRouters(
+		controller.NewJobController(ctx, s.serv),
+	)
+	ws.InitRouter()
+
+	s.r = ws.GetEngine()
+}
+
+func (s *JobAPISuite) TestGetJob() {
+	scenarios := []struct {
+		err          error
+		expectedCode int
+	}{
+		{nil, http.StatusOK},
+		{application.ErrDataNotFound, http.StatusNotFound},
+	}
+
+	for _, scenario := range scenarios {
+		var job *dto.JobDto
+		if scenario.err == nil {
+			job = &dto.JobDto{ID: "job", Status: "done"}
+		}
+		s.serv.On("GetJob", mock.Anything).Return(job, scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job", nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("GetJob").Unset()
+	}
+}
+
+func (s *JobAPISuite) TestJobEvents() {
+	events := make(chan dto.JobEvent, 3)
+	events <- dto.JobEvent{Event: "job", Data: dto.JobDto{ID: "job", Status: "running"}}
+	events <- dto.JobEvent{Event: "file", Data: dto.JobFileDto{UploadID: 1, Status: "saved", PhotoID: 3}}
+	events <- dto.JobEvent{Event: "done", Data: dto.JobDto{ID: "job", Status: "done"}}
+	close(events)
+	unsubscribed := false
+	s.serv.On("SubscribeJob", "job").Return((<-chan dto.JobEvent)(events), func() { unsubscribed = true }, nil)
+	defer s.serv.On("SubscribeJob", "job").Unset()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/jobs/job/events", nil)
+	s.r.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Header().Get("Content-Type"), "text/event-stream")
+	s.Contains(w.Body.String(), "event:file\ndata:{\"uploadId\":1,\"name\":\"\",\"status\":\"saved\",\"photoId\":3}\n")
+	s.Contains(w.Body.String(), "event:done\n")
+	s.True(unsubscribed)
+}
+
+func (s *JobAPISuite) TestJobEventsNotFound() {
+	s.serv.On("SubscribeJob", "none").Return(nil, nil, application.ErrDataNotFound)
+	defer s.serv.On("SubscribeJob", "none").Unset()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/jobs/none/events", nil)
+	s.r.ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+}
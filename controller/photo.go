@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/common"
 	"github.com/follow1123/photos/imagemanager"
 	"github.com/follow1123/photos/logger"
 	"github.com/follow1123/photos/model/dto"
@@ -26,16 +28,43 @@ const (
 	PHOTO_API_PREVIEW_ORIGINAL          = PHOTO_API_GETBYID + "/preview/original"
 	PHOTO_API_PREVIEW_COMPRESSED        = PHOTO_API_GETBYID + "/preview/compressed"
 	PHOTO_API_DOWNLOAD                  = PHOTO_API_GETBYID + "/download"
+	PHOTO_API_GEO                       = PHOTO_API_LIST + "/geo"
+	PHOTO_API_RENDER                    = PHOTO_API_GETBYID + "/render"
+	PHOTO_API_DUPLICATES                = PHOTO_API_LIST + "/duplicates"
+	PHOTO_API_CURSOR                    = PHOTO_API_LIST + "/cursor"
+	PHOTO_API_TIMELINE                  = PHOTO_API_LIST + "/timeline"
+	PHOTO_API_MEMORIES                  = PHOTO_API_LIST + "/memories"
+	PHOTO_API_FAVORITE                  = PHOTO_API_GETBYID + "/favorite"
+	PHOTO_API_RATING                    = PHOTO_API_GETBYID + "/rating"
+	PHOTO_API_BATCH_FAVORITE            = PHOTO_API_LIST + "/favorite"
+	PHOTO_API_BATCH_RATING              = PHOTO_API_LIST + "/rating"
+	PHOTO_API_BATCH                     = PHOTO_API_LIST + "/batch"
 )
 
+// WithJobService 上传图片时可以使用 async=true 创建异步导入的任务
+func WithJobService(jobServ service.JobService) common.Option[PhotoController] {
+	return common.OptionFunc[PhotoController](func(pc *PhotoController) {
+		pc.jobServ = jobServ
+	})
+}
+
 type PhotoController struct {
 	logger.AppLogger
-	ctx  *application.AppContext
-	serv service.PhotoService
+	ctx     *application.AppContext
+	serv    service.PhotoService
+	jobServ service.JobService
 }
 
-func NewPhotoController(ctx *application.AppContext, service service.PhotoService) *PhotoController {
-	return &PhotoController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+func NewPhotoController(
+	ctx *application.AppContext,
+	service service.PhotoService,
+	opts ...common.Option[PhotoController],
+) *PhotoController {
+	pc := &PhotoController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+	for _, opt := range opts {
+		opt.Apply(pc)
+	}
+	return pc
 }
 
 func (pc *PhotoController) GetPhotoById(c *gin.Context) {
@@ -61,6 +90,7 @@ func (pc *PhotoController) PhotoPage(c *gin.Context) {
 	if err := c.BindQuery(&photoParam); err != nil {
 		return
 	}
+	pageParam.Params = photoParam
 
 	result, err := pc.serv.PhotoPage(pageParam)
 	if err != nil {
@@ -70,7 +100,69 @@ func (pc *PhotoController) PhotoPage(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+func (pc *PhotoController) PhotoCursorPage(c *gin.Context) {
+	cursorParam := dto.CursorParam[dto.PhotoPageParam]{}
+	photoParam := dto.PhotoPageParam{}
+
+	if err := c.BindQuery(&cursorParam); err != nil {
+		return
+	}
+	if err := c.BindQuery(&photoParam); err != nil {
+		return
+	}
+	cursorParam.Params = photoParam
+
+	result, err := pc.serv.PhotoCursorPage(cursorParam)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (pc *PhotoController) PhotoTimeline(c *gin.Context) {
+	timelineParam := dto.TimelineParam{}
+	photoParam := dto.PhotoPageParam{}
+
+	if err := c.BindQuery(&timelineParam); err != nil {
+		return
+	}
+	if err := c.BindQuery(&photoParam); err != nil {
+		return
+	}
+	timelineParam.Params = photoParam
+
+	buckets, err := pc.serv.PhotoTimeline(timelineParam)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, buckets)
+}
+
+func (pc *PhotoController) PhotoMemories(c *gin.Context) {
+	var param dto.MemoryParam
+	if err := c.BindQuery(&param); err != nil {
+		return
+	}
+	groups, err := pc.serv.PhotoMemories(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
 func (pc *PhotoController) CreatePhoto(c *gin.Context) {
+	var asyncParam dto.AsyncParam
+	if err := c.BindQuery(&asyncParam); err != nil {
+		return
+	}
+	if asyncParam.Async && pc.jobServ == nil {
+		c.Error(application.NewAppError(http.StatusNotImplemented, "不支持异步导入"))
+		return
+	}
+
 	metaData := c.PostForm("metaData")
 	pc.Debug("meta data: %s", metaData)
 
@@ -80,7 +172,8 @@ func (pc *PhotoController) CreatePhoto(c *gin.Context) {
 		return
 	}
 
-	for _, param := range params {
+	for i := range params {
+		param := &params[i]
 		fileHeader, err := c.FormFile(fmt.Sprintf("file_%d", param.UploadID))
 		if err != nil {
 			if errors.Is(err, http.ErrMissingFile) {
@@ -89,7 +182,15 @@ func (pc *PhotoController) CreatePhoto(c *gin.Context) {
 					c.Error(application.NewAppError(http.StatusBadRequest, "不上传文件时，uri 必须填写"))
 					return
 				}
-				param.ImageSource = imagemanager.NewRemoteUriSource(param.Uri)
+				if err := imagemanager.CheckRemoteUri(param.Uri); err != nil {
+					if errors.Is(err, imagemanager.ErrRemoteUriCredentials) {
+						c.Error(application.NewAppError(http.StatusBadRequest, "uri 不能包含用户名和密码，请在配置文件 remotes 内配置账号"))
+						return
+					}
+					c.Error(application.NewAppError(http.StatusBadRequest, "uri [ %s ] 格式错误", param.Uri))
+					return
+				}
+				param.ImageSource = pc.ctx.GetImageManager().NewRemoteSource(param.Uri)
 				continue
 			}
 			c.Error(application.NewAppError(http.StatusBadRequest, "上传了错误的文件"))
@@ -97,6 +198,19 @@ func (pc *PhotoController) CreatePhoto(c *gin.Context) {
 		}
 		param.ImageSource = imagemanager.NewMultipartSource(fileHeader)
 	}
+
+	// 异步导入时返回任务，通过 /jobs/:id 查询进度
+	if asyncParam.Async {
+		job, err := pc.jobServ.EnqueueImport(params)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.Header("Location", strings.Replace(JOB_API_GETBYID, ":id", job.ID, 1))
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
 	failedResults := pc.serv.CreatePhoto(params)
 	failureCount := len(failedResults)
 	if failureCount == 0 {
@@ -140,6 +254,75 @@ func (pc *PhotoController) DeletePhoto(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// bindPhotoID 绑定路径内的图片 id，路径内没有 id 时由请求体提供图片 id
+func (pc *PhotoController) bindPhotoID(c *gin.Context) (uint, bool, bool) {
+	if c.Param("id") == "" {
+		return 0, false, true
+	}
+	photoParam := &dto.PhotoParam{}
+	if err := c.BindUri(photoParam); err != nil {
+		return 0, false, false
+	}
+	return photoParam.ID, true, true
+}
+
+func (pc *PhotoController) SetFavorite(c *gin.Context) {
+	param := dto.PhotoFavoriteParam{}
+	id, hasID, ok := pc.bindPhotoID(c)
+	if !ok {
+		return
+	}
+	if hasID {
+		favoriteParam := dto.FavoriteParam{}
+		if err := c.BindJSON(&favoriteParam); err != nil {
+			return
+		}
+		param = dto.PhotoFavoriteParam{PhotoIDs: []uint{id}, Favorite: favoriteParam.Favorite}
+	} else if err := c.BindJSON(&param); err != nil {
+		return
+	}
+	if err := pc.serv.SetFavorite(param); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (pc *PhotoController) SetRating(c *gin.Context) {
+	param := dto.PhotoRatingParam{}
+	id, hasID, ok := pc.bindPhotoID(c)
+	if !ok {
+		return
+	}
+	if hasID {
+		ratingParam := dto.RatingParam{}
+		if err := c.BindJSON(&ratingParam); err != nil {
+			return
+		}
+		param = dto.PhotoRatingParam{PhotoIDs: []uint{id}, Rating: ratingParam.Rating}
+	} else if err := c.BindJSON(&param); err != nil {
+		return
+	}
+	if err := pc.serv.SetRating(param); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (pc *PhotoController) BatchPhotos(c *gin.Context) {
+	var param dto.BatchParam
+	if err := c.BindJSON(&param); err != nil {
+		return
+	}
+	results, err := pc.serv.BatchPhotos(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
 func (pc *PhotoController) PreviewOriginalPhoto(c *gin.Context) {
 	param := &dto.PhotoParam{}
 	if err := c.BindUri(param); err != nil {
@@ -150,7 +333,17 @@ func (pc *PhotoController) PreviewOriginalPhoto(c *gin.Context) {
 	isDownload := strings.HasSuffix(urlPath, "download")
 	isCompressed := strings.HasSuffix(urlPath, "compressed")
 
-	rc, imgInfo, err := pc.serv.GetPhotoFile(param.ID, !isCompressed)
+	var (
+		rc      io.ReadCloser
+		imgInfo *imagemanager.ImageInfo
+		err     error
+	)
+	if isCompressed {
+		size := c.DefaultQuery("size", imagemanager.RENDITION_PREVIEW)
+		rc, imgInfo, err = pc.serv.GetPhotoRendition(param.ID, size)
+	} else {
+		rc, imgInfo, err = pc.serv.GetPhotoFile(param.ID, true)
+	}
 	if err != nil {
 		c.Error(err)
 		return
@@ -178,6 +371,75 @@ func (pc *PhotoController) PreviewOriginalPhoto(c *gin.Context) {
 	)
 }
 
+func (pc *PhotoController) RenderPhoto(c *gin.Context) {
+	param := &dto.PhotoParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	var renderParam dto.RenderParam
+	if err := c.BindQuery(&renderParam); err != nil {
+		return
+	}
+
+	// 彻底删除后 id 可能被新的图片使用，每次请求都使用 ETag 校验
+	etag, err := pc.serv.RenderETag(param.ID, renderParam)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	extraHeaders := map[string]string{
+		"Cache-Control": "public, no-cache",
+		"ETag":          etag,
+	}
+	if c.GetHeader("If-None-Match") == etag {
+		for key, value := range extraHeaders {
+			c.Header(key, value)
+		}
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	rc, imgInfo, err := pc.serv.RenderPhoto(param.ID, renderParam)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rc.Close()
+	c.DataFromReader(
+		http.StatusOK,
+		imgInfo.Size,
+		fmt.Sprintf("image/%s", imgInfo.Format),
+		rc,
+		extraHeaders,
+	)
+}
+
+func (pc *PhotoController) GeoPhotos(c *gin.Context) {
+	var param dto.GeoParam
+	if err := c.BindQuery(&param); err != nil {
+		return
+	}
+	result, err := pc.serv.GeoPhotos(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (pc *PhotoController) DuplicatePhotos(c *gin.Context) {
+	var param dto.DuplicateParam
+	if err := c.BindQuery(&param); err != nil {
+		return
+	}
+	clusters, err := pc.serv.DuplicatePhotos(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, clusters)
+}
+
 func (pc *PhotoController) SetHandleMapping(engine *gin.Engine) {
 	engine.GET(PHOTO_API_GETBYID, pc.GetPhotoById)
 	engine.GET(PHOTO_API_LIST, pc.PhotoPage)
@@ -187,4 +449,15 @@ func (pc *PhotoController) SetHandleMapping(engine *gin.Engine) {
 	engine.GET(PHOTO_API_PREVIEW_ORIGINAL, pc.PreviewOriginalPhoto)
 	engine.GET(PHOTO_API_PREVIEW_COMPRESSED, pc.PreviewOriginalPhoto)
 	engine.GET(PHOTO_API_DOWNLOAD, pc.PreviewOriginalPhoto)
+	engine.GET(PHOTO_API_GEO, pc.GeoPhotos)
+	engine.GET(PHOTO_API_RENDER, pc.RenderPhoto)
+	engine.GET(PHOTO_API_DUPLICATES, pc.DuplicatePhotos)
+	engine.GET(PHOTO_API_CURSOR, pc.PhotoCursorPage)
+	engine.GET(PHOTO_API_TIMELINE, pc.PhotoTimeline)
+	engine.GET(PHOTO_API_MEMORIES, pc.PhotoMemories)
+	engine.PUT(PHOTO_API_FAVORITE, pc.SetFavorite)
+	engine.PUT(PHOTO_API_BATCH_FAVORITE, pc.SetFavorite)
+	engine.PUT(PHOTO_API_RATING, pc.SetRating)
+	engine.PUT(PHOTO_API_BATCH_RATING, pc.SetRating)
+	engine.POST(PHOTO_API_BATCH, pc.BatchPhotos)
 }
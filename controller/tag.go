@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TAG_API_LIST       string = "/tag"
+	TAG_API_PHOTOS            = TAG_API_LIST + "/photos"
+	TAG_API_PHOTO_TAGS        = PHOTO_API_GETBYID + "/tags"
+)
+
+type TagController struct {
+	logger.AppLogger
+	ctx  *application.AppContext
+	serv service.TagService
+}
+
+func NewTagController(ctx *application.AppContext, service service.TagService) *TagController {
+	return &TagController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+}
+
+func (tc *TagController) SearchTags(c *gin.Context) {
+	var param dto.TagSearchParam
+	if err := c.BindQuery(&param); err != nil {
+		return
+	}
+	tagDtoList, err := tc.serv.SearchTags(param)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, tagDtoList)
+}
+
+// bindPhotoTags 绑定批量操作的参数，或者路径内的图片 id 和请求体内的标签
+func (tc *TagController) bindPhotoTags(c *gin.Context) (*dto.PhotoTagsParam, bool) {
+	if c.Param("id") == "" {
+		param := &dto.PhotoTagsParam{}
+		if err := c.BindJSON(param); err != nil {
+			return nil, false
+		}
+		return param, true
+	}
+
+	photoParam := &dto.PhotoParam{}
+	if err := c.BindUri(photoParam); err != nil {
+		return nil, false
+	}
+	tagsParam := &dto.TagsParam{}
+	if err := c.BindJSON(tagsParam); err != nil {
+		return nil, false
+	}
+	return &dto.PhotoTagsParam{PhotoIDs: []uint{photoParam.ID}, Tags: tagsParam.Tags}, true
+}
+
+func (tc *TagController) AddTags(c *gin.Context) {
+	param, ok := tc.bindPhotoTags(c)
+	if !ok {
+		return
+	}
+	if err := tc.serv.AddTags(*param); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (tc *TagController) RemoveTags(c *gin.Context) {
+	param, ok := tc.bindPhotoTags(c)
+	if !ok {
+		return
+	}
+	if err := tc.serv.RemoveTags(*param); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (tc *TagController) SetHandleMapping(engine *gin.Engine) {
+	engine.GET(TAG_API_LIST, tc.SearchTags)
+	engine.POST(TAG_API_PHOTOS, tc.AddTags)
+	engine.DELETE(TAG_API_PHOTOS, tc.RemoveTags)
+	engine.POST(TAG_API_PHOTO_TAGS, tc.AddTags)
+	engine.DELETE(TAG_API_PHOTO_TAGS, tc.RemoveTags)
+}
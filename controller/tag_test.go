@@ -0,0 +1,107 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/controller"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/mocks"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TagAPISuite struct {
+	suite.Suite
+	r    *gin.Engine
+	serv *mocks.TagService
+}
+
+func TestTagAPISuite(t *testing.T) {
+	suite.Run(t, &TagAPISuite{})
+}
+
+func (s *TagAPISuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	ws, err := appgen.GenWebServer(appComponents)
+	s.Nil(err)
+	s.serv = &mocks.TagService{}
+
+	ws.InitMiddleware()
+
+	ws.SetRouters(
+		controller.NewTagController(ctx, s.serv),
+	)
+	ws.InitRouter()
+
+	s.r = ws.GetEngine()
+}
+
+func (s *TagAPISuite) TestSearchTags() {
+	scenarios := []struct {
+		uri          string
+		expectedCode int
+	}{
+		{"/tag?prefix=pa", http.StatusOK},
+		{"/tag?prefix=pa&limit=5", http.StatusOK},
+		{"/tag?limit=0", http.StatusOK},
+		{"/tag?limit=1000", http.StatusBadRequest},
+	}
+
+	expectedData := []dto.TagDto{{ID: 1, Name: "paris", Count: 3}}
+	expectedDataJson, err := json.Marshal(expectedData)
+	s.Nil(err)
+	for _, scenario := range scenarios {
+		s.serv.On("SearchTags", mock.Anything).Return(expectedData, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", scenario.uri, nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario.uri)
+		if scenario.expectedCode == http.StatusOK {
+			s.Equal(string(expectedDataJson), w.Body.String())
+		}
+
+		s.serv.On("SearchTags").Unset()
+	}
+}
+
+func (s *TagAPISuite) TestPhotoTags() {
+	scenarios := []struct {
+		method       string
+		uri          string
+		serviceFunc  string
+		body         string
+		err          error
+		expectedCode int
+	}{
+		{"POST", "/photo/1/tags", "AddTags", `{"tags":["travel"]}`, nil, http.StatusNoContent},
+		{"POST", "/photo/1/tags", "AddTags", `{"tags":[]}`, nil, http.StatusBadRequest},
+		{"POST", "/photo/a/tags", "AddTags", `{"tags":["travel"]}`, nil, http.StatusBadRequest},
+		{"POST", "/photo/1/tags", "AddTags", `{"tags":["travel"]}`, application.NewAppError(http.StatusBadRequest, "tag"), http.StatusBadRequest},
+		{"DELETE", "/photo/1/tags", "RemoveTags", `{"tags":["travel"]}`, nil, http.StatusNoContent},
+		{"POST", "/tag/photos", "AddTags", `{"photoIds":[1,2],"tags":["travel"]}`, nil, http.StatusNoContent},
+		{"POST", "/tag/photos", "AddTags", `{"tags":["travel"]}`, nil, http.StatusBadRequest},
+		{"DELETE", "/tag/photos", "RemoveTags", `{"photoIds":[1,2],"tags":["travel"]}`, nil, http.StatusNoContent},
+		{"DELETE", "/tag/photos", "RemoveTags", `{"photoIds":[1],"tags":[""]}`, nil, http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On(scenario.serviceFunc, mock.Anything).Return(scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(scenario.method, scenario.uri, strings.NewReader(scenario.body))
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario.uri)
+
+		s.serv.On(scenario.serviceFunc).Unset()
+	}
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TRASH_API_LIST    string = "/trash"
+	TRASH_API_DELETE         = TRASH_API_LIST + "/:id"
+	TRASH_API_RESTORE        = TRASH_API_DELETE + "/restore"
+)
+
+type TrashController struct {
+	logger.AppLogger
+	ctx  *application.AppContext
+	serv service.TrashService
+}
+
+func NewTrashController(ctx *application.AppContext, service service.TrashService) *TrashController {
+	return &TrashController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+}
+
+func (tc *TrashController) TrashPage(c *gin.Context) {
+	pageParam := dto.PageParam[struct{}]{}
+	if err := c.BindQuery(&pageParam); err != nil {
+		return
+	}
+	result, err := tc.serv.TrashPage(pageParam)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (tc *TrashController) RestorePhoto(c *gin.Context) {
+	param := &dto.PhotoParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	photoDto, err := tc.serv.RestorePhoto(param.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, photoDto)
+}
+
+func (tc *TrashController) DeletePhoto(c *gin.Context) {
+	param := &dto.PhotoParam{}
+	if err := c.BindUri(param); err != nil {
+		return
+	}
+	if err := tc.serv.DeletePhoto(param.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (tc *TrashController) SetHandleMapping(engine *gin.Engine) {
+	engine.GET(TRASH_API_LIST, tc.TrashPage)
+	engine.POST(TRASH_API_RESTORE, tc.RestorePhoto)
+	engine.DELETE(TRASH_API_DELETE, tc.DeletePhoto)
+}
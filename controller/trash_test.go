@@ -0,0 +1,114 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/controller"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/mocks"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TrashAPISuite struct {
+	suite.Suite
+	r    *gin.Engine
+	serv *mocks.TrashService
+}
+
+func TestTrashAPISuite(t *testing.T) {
+	suite.Run(t, &TrashAPISuite{})
+}
+
+func (s *TrashAPISuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	ws, err := appgen.GenWebServer(appComponents)
+	s.Nil(err)
+	s.serv = &mocks.TrashService{}
+
+	ws.InitMiddleware()
+
+	ws.SetRouters(
+		controller.NewTrashController(ctx, s.serv),
+	)
+	ws.InitRouter()
+
+	s.r = ws.GetEngine()
+}
+
+func (s *TrashAPISuite) TestTrashPage() {
+	expectedData := dto.PageResult[dto.TrashPhotoDto]{
+		List:     []dto.TrashPhotoDto{{PhotoDto: dto.PhotoDto{ID: 1}}},
+		PageNum:  1,
+		PageSize: 10,
+		Total:    1,
+	}
+	s.serv.On("TrashPage", mock.Anything).Return(&expectedData, nil)
+	defer s.serv.On("TrashPage").Unset()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/trash?pageNum=1&pageSize=10", nil)
+	s.r.ServeHTTP(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	expectedDataJson, err := json.Marshal(expectedData)
+	s.Nil(err)
+	s.Equal(string(expectedDataJson), w.Body.String())
+}
+
+func (s *TrashAPISuite) TestRestorePhoto() {
+	scenarios := []struct {
+		uri          string
+		err          error
+		expectedCode int
+	}{
+		{"/trash/1/restore", nil, http.StatusOK},
+		{"/trash/1/restore", application.ErrDataNotFound, http.StatusNotFound},
+		{"/trash/1/restore", application.NewAppError(http.StatusConflict, "conflict"), http.StatusConflict},
+		{"/trash/a/restore", nil, http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		var photoDto *dto.PhotoDto
+		if scenario.err == nil {
+			photoDto = &dto.PhotoDto{ID: 1}
+		}
+		s.serv.On("RestorePhoto", mock.Anything).Return(photoDto, scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", scenario.uri, nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("RestorePhoto").Unset()
+	}
+}
+
+func (s *TrashAPISuite) TestDeletePhoto() {
+	scenarios := []struct {
+		err          error
+		expectedCode int
+	}{
+		{nil, http.StatusNoContent},
+		{application.ErrDataNotFound, http.StatusNotFound},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On("DeletePhoto", mock.Anything).Return(scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/trash/1", nil)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("DeletePhoto").Unset()
+	}
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	UPLOAD_API_CREATE  string = "/upload"
+	UPLOAD_API_GETBYID        = UPLOAD_API_CREATE + "/:id"
+)
+
+// tus 1.0 断点续传协议 https://tus.io/protocols/resumable-upload
+const (
+	TUS_VERSION      = "1.0.0"
+	TUS_EXTENSIONS   = "creation,termination,expiration"
+	TUS_CONTENT_TYPE = "application/offset+octet-stream"
+)
+
+type UploadController struct {
+	logger.AppLogger
+	ctx  *application.AppContext
+	serv service.UploadService
+}
+
+func NewUploadController(ctx *application.AppContext, service service.UploadService) *UploadController {
+	return &UploadController{ctx: ctx, serv: service, AppLogger: *ctx.GetLogger()}
+}
+
+// parseUploadMetadata 解析 Upload-Metadata，格式为逗号分隔的 key 和 base64 编码的 value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for pair := range strings.SplitSeq(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("key 不能为空")
+		}
+		if _, ok := metadata[key]; ok {
+			return nil, fmt.Errorf("key [ %s ] 重复", key)
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key [ %s ] 的值不是 base64 格式", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func formatUploadMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func parseUploadHeader(c *gin.Context, name string) (int64, error) {
+	value, err := strconv.ParseInt(c.GetHeader(name), 10, 64)
+	if err != nil || value < 0 {
+		return 0, application.NewAppError(http.StatusBadRequest, "请求头 %s 格式错误", name)
+	}
+	return value, nil
+}
+
+// checkTusResumable 除了 OPTIONS 请求，都需要使用相同的协议版本
+func (uc *UploadController) checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", TUS_VERSION)
+	if version := c.GetHeader("Tus-Resumable"); version != TUS_VERSION {
+		c.Header("Tus-Version", TUS_VERSION)
+		c.Error(application.NewAppError(http.StatusPreconditionFailed, "不支持的 tus 版本 [ %s ]", version))
+		return false
+	}
+	return true
+}
+
+func setUploadHeaders(c *gin.Context, upload *dto.UploadDto) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+func (uc *UploadController) UploadOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", TUS_VERSION)
+	c.Header("Tus-Version", TUS_VERSION)
+	c.Header("Tus-Extension", TUS_EXTENSIONS)
+	c.Header("Tus-Max-Size", strconv.FormatInt(service.UPLOAD_MAX_SIZE, 10))
+	c.Status(http.StatusNoContent)
+}
+
+func (uc *UploadController) CreateUpload(c *gin.Context) {
+	if !uc.checkTusResumable(c) {
+		return
+	}
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.Error(application.NewAppError(http.StatusBadRequest, "不支持 Upload-Defer-Length，需要指定 Upload-Length"))
+		return
+	}
+	length, err := parseUploadHeader(c, "Upload-Length")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.Error(application.NewAppError(http.StatusBadRequest, "请求头 Upload-Metadata 格式错误: %v", err))
+		return
+	}
+	upload, err := uc.serv.CreateUpload(dto.UploadParam{Length: length, Metadata: metadata})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", UPLOAD_API_CREATE+"/"+upload.ID)
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusCreated)
+}
+
+func (uc *UploadController) GetUpload(c *gin.Context) {
+	if !uc.checkTusResumable(c) {
+		return
+	}
+	upload, err := uc.serv.GetUpload(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	setUploadHeaders(c, upload)
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		c.Header("Upload-Metadata", formatUploadMetadata(upload.Metadata))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+func (uc *UploadController) WriteUpload(c *gin.Context) {
+	if !uc.checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != TUS_CONTENT_TYPE {
+		c.Error(application.NewAppError(http.StatusUnsupportedMediaType, "Content-Type 必须为 %s", TUS_CONTENT_TYPE))
+		return
+	}
+	offset, err := parseUploadHeader(c, "Upload-Offset")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	upload, err := uc.serv.WriteUpload(c.Param("id"), offset, c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+func (uc *UploadController) DeleteUpload(c *gin.Context) {
+	if !uc.checkTusResumable(c) {
+		return
+	}
+	if err := uc.serv.DeleteUpload(c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (uc *UploadController) SetHandleMapping(engine *gin.Engine) {
+	engine.OPTIONS(UPLOAD_API_CREATE, uc.UploadOptions)
+	engine.POST(UPLOAD_API_CREATE, uc.CreateUpload)
+	engine.HEAD(UPLOAD_API_GETBYID, uc.GetUpload)
+	engine.PATCH(UPLOAD_API_GETBYID, uc.WriteUpload)
+	engine.DELETE(UPLOAD_API_GETBYID, uc.DeleteUpload)
+}
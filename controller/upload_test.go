@@ -0,0 +1,202 @@
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/controller"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/mocks"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type UploadAPISuite struct {
+	suite.Suite
+	r    *gin.Engine
+	serv *mocks.UploadService
+}
+
+func TestUploadAPISuite(t *testing.T) {
+	suite.Run(t, &UploadAPISuite{})
+}
+
+func (s *UploadAPISuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	ws, err := appgen.GenWebServer(appComponents)
+	s.Nil(err)
+	s.serv = &mocks.UploadService{}
+
+	ws.InitMiddleware()
+
+	ws.SetRouters(
+		controller.NewUploadController(ctx, s.serv),
+	)
+	ws.InitRouter()
+
+	s.r = ws.GetEngine()
+}
+
+func (s *UploadAPISuite) upload() *dto.UploadDto {
+	return &dto.UploadDto{
+		ID:        "id",
+		Length:    10,
+		Offset:    4,
+		Metadata:  map[string]string{"filename": "a.png"},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func (s *UploadAPISuite) TestUploadOptions() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/upload", nil)
+	s.r.ServeHTTP(w, req)
+	s.Equal(http.StatusNoContent, w.Code)
+	s.Equal(controller.TUS_VERSION, w.Header().Get("Tus-Version"))
+	s.Contains(w.Header().Get("Tus-Extension"), "creation")
+}
+
+func (s *UploadAPISuite) TestCreateUpload() {
+	scenarios := []struct {
+		headers       map[string]string
+		expectedParam dto.UploadParam
+		expectedCode  int
+	}{
+		{
+			map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "10", "Upload-Metadata": "filename YS5wbmc=,empty"},
+			dto.UploadParam{Length: 10, Metadata: map[string]string{"filename": "a.png", "empty": ""}},
+			http.StatusCreated,
+		},
+		{map[string]string{"Upload-Length": "10"}, dto.UploadParam{}, http.StatusPreconditionFailed},
+		{map[string]string{"Tus-Resumable": "0.2.2", "Upload-Length": "10"}, dto.UploadParam{}, http.StatusPreconditionFailed},
+		{map[string]string{"Tus-Resumable": "1.0.0"}, dto.UploadParam{}, http.StatusBadRequest},
+		{map[string]string{"Tus-Resumable": "1.0.0", "Upload-Defer-Length": "1"}, dto.UploadParam{}, http.StatusBadRequest},
+		{map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "-1"}, dto.UploadParam{}, http.StatusBadRequest},
+		{
+			map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "10", "Upload-Metadata": "filename !!!"},
+			dto.UploadParam{},
+			http.StatusBadRequest,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On("CreateUpload", mock.Anything).Return(s.upload(), nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", nil)
+		for key, value := range scenario.headers {
+			req.Header.Set(key, value)
+		}
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario.headers)
+		s.Equal(controller.TUS_VERSION, w.Header().Get("Tus-Resumable"))
+		if scenario.expectedCode == http.StatusCreated {
+			s.Equal("/upload/id", w.Header().Get("Location"))
+			s.NotEmpty(w.Header().Get("Upload-Expires"))
+			s.serv.AssertCalled(s.T(), "CreateUpload", scenario.expectedParam)
+		}
+
+		s.serv.On("CreateUpload").Unset()
+	}
+}
+
+func (s *UploadAPISuite) TestGetUpload() {
+	scenarios := []struct {
+		err          error
+		expectedCode int
+	}{
+		{nil, http.StatusOK},
+		{application.ErrDataNotFound, http.StatusNotFound},
+	}
+
+	for _, scenario := range scenarios {
+		if scenario.err != nil {
+			s.serv.On("GetUpload", mock.Anything).Return(nil, scenario.err)
+		} else {
+			s.serv.On("GetUpload", mock.Anything).Return(s.upload(), nil)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("HEAD", "/upload/id", nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+		if scenario.err == nil {
+			s.Equal("4", w.Header().Get("Upload-Offset"))
+			s.Equal("10", w.Header().Get("Upload-Length"))
+			s.Equal("filename YS5wbmc=", w.Header().Get("Upload-Metadata"))
+			s.Equal("no-store", w.Header().Get("Cache-Control"))
+		}
+
+		s.serv.On("GetUpload").Unset()
+	}
+}
+
+func (s *UploadAPISuite) TestWriteUpload() {
+	scenarios := []struct {
+		contentType  string
+		offset       string
+		err          error
+		expectedCode int
+	}{
+		{controller.TUS_CONTENT_TYPE, "4", nil, http.StatusNoContent},
+		{controller.TUS_CONTENT_TYPE, "0", application.NewAppError(http.StatusConflict, "conflict"), http.StatusConflict},
+		{controller.TUS_CONTENT_TYPE, "4", application.ErrDataNotFound, http.StatusNotFound},
+		{"application/octet-stream", "4", nil, http.StatusUnsupportedMediaType},
+		{controller.TUS_CONTENT_TYPE, "a", nil, http.StatusBadRequest},
+		{controller.TUS_CONTENT_TYPE, "", nil, http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		if scenario.err != nil {
+			s.serv.On("WriteUpload", mock.Anything, mock.Anything, mock.Anything).Return(nil, scenario.err)
+		} else {
+			s.serv.On("WriteUpload", mock.Anything, mock.Anything, mock.Anything).Return(s.upload(), nil)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/upload/id", strings.NewReader("data"))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Content-Type", scenario.contentType)
+		req.Header.Set("Upload-Offset", scenario.offset)
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code, scenario)
+		if scenario.expectedCode == http.StatusNoContent {
+			s.Equal("4", w.Header().Get("Upload-Offset"))
+			s.serv.AssertCalled(s.T(), "WriteUpload", "id", int64(4), mock.Anything)
+		}
+
+		s.serv.On("WriteUpload").Unset()
+	}
+}
+
+func (s *UploadAPISuite) TestDeleteUpload() {
+	scenarios := []struct {
+		err          error
+		expectedCode int
+	}{
+		{nil, http.StatusNoContent},
+		{application.ErrDataNotFound, http.StatusNotFound},
+		{application.NewAppError(http.StatusLocked, "locked"), http.StatusLocked},
+	}
+
+	for _, scenario := range scenarios {
+		s.serv.On("DeleteUpload", mock.Anything).Return(scenario.err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/upload/id", nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		s.r.ServeHTTP(w, req)
+		s.Equal(scenario.expectedCode, w.Code)
+
+		s.serv.On("DeleteUpload").Unset()
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 全文搜索使用 fts5 的 trigram 分词，支持中文和任意位置的子串，需要使用 sqlite_fts5 标签编译
+// 没有 fts5 时使用 LIKE 查询
+const (
+	FTS_TABLE = "photos_fts"
+	// trigram 分词的最小长度，更短的关键字无法使用全文索引
+	FTS_MIN_TOKEN_LENGTH = 3
+)
+
+// 图片标签的内容，多个标签用空格分隔
+const ftsTagsSql = `COALESCE((SELECT group_concat(tags.name, ' ') FROM photo_tags
+	JOIN tags ON tags.id = photo_tags.tag_id WHERE photo_tags.photo_id = %s), '')`
+
+// 通过触发器同步图片的描述、文件名和标签，回收站内的图片也保留索引
+var ftsTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS photos_fts_insert AFTER INSERT ON photos BEGIN
+		INSERT INTO photos_fts(rowid, "desc", file_name, tags) VALUES (new.id, new."desc", new.file_name, '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS photos_fts_update AFTER UPDATE OF "desc", file_name ON photos BEGIN
+		UPDATE photos_fts SET "desc" = new."desc", file_name = new.file_name WHERE rowid = new.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS photos_fts_delete AFTER DELETE ON photos BEGIN
+		DELETE FROM photos_fts WHERE rowid = old.id;
+	END`,
+	fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_tag_insert AFTER INSERT ON photo_tags BEGIN
+		UPDATE photos_fts SET tags = %s WHERE rowid = new.photo_id;
+	END`, fmt.Sprintf(ftsTagsSql, "new.photo_id")),
+	fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_tag_delete AFTER DELETE ON photo_tags BEGIN
+		UPDATE photos_fts SET tags = %s WHERE rowid = old.photo_id;
+	END`, fmt.Sprintf(ftsTagsSql, "old.photo_id")),
+}
+
+// FTSEnabled sqlite 是否支持 fts5
+func (d *SqliteDB) FTSEnabled() bool {
+	d.ftsOnce.Do(func() {
+		var used int
+		if result := d.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used); result.Error != nil {
+			d.Logger.Logger.Warnf("detect fts5 error: %v", result.Error)
+			return
+		}
+		d.ftsEnabled = used == 1
+	})
+	return d.ftsEnabled
+}
+
+// InitFullTextSearch 创建全文索引和同步数据的触发器，新建索引时导入已有的数据
+func (d *SqliteDB) InitFullTextSearch() error {
+	if !d.FTSEnabled() {
+		d.Logger.Logger.Info("sqlite fts5 is not enabled, use LIKE to search")
+		return nil
+	}
+	exists := d.Migrator().HasTable(FTS_TABLE)
+	if !exists {
+		sql := `CREATE VIRTUAL TABLE photos_fts USING fts5("desc", file_name, tags, tokenize = 'trigram')`
+		if err := d.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	for _, trigger := range ftsTriggers {
+		if err := d.Exec(trigger).Error; err != nil {
+			return err
+		}
+	}
+	if exists {
+		return nil
+	}
+	_, err := d.RebuildFullTextSearch()
+	return err
+}
+
+// RebuildFullTextSearch 重新导入所有图片的全文索引，返回导入的数量
+func (d *SqliteDB) RebuildFullTextSearch() (int64, error) {
+	if !d.FTSEnabled() {
+		return 0, fmt.Errorf("sqlite fts5 is not enabled, build with -tags sqlite_fts5")
+	}
+	var count int64
+	err := d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM photos_fts").Error; err != nil {
+			return err
+		}
+		result := tx.Exec(fmt.Sprintf(`INSERT INTO photos_fts(rowid, "desc", file_name, tags)
+			SELECT photos.id, photos."desc", photos.file_name, %s FROM photos`, fmt.Sprintf(ftsTagsSql, "photos.id")))
+		count = result.RowsAffected
+		return result.Error
+	})
+	return count, err
+}
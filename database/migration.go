@@ -1,10 +1,19 @@
 package database
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/follow1123/photos/imagemanager"
 	"github.com/follow1123/photos/model"
+	"gorm.io/gorm"
 )
 
-const VERSION = 0
+// VERSION 当前数据库的版本，保存在 sqlite 的 user_version 内
+const VERSION = 3
+
+const MIGRATION_BATCH_SIZE = 100
 
 type DBMigrator struct {
 	DB *SqliteDB
@@ -14,8 +23,121 @@ func NewDBMigrator(db *SqliteDB) *DBMigrator {
 	return &DBMigrator{DB: db}
 }
 
-func (dm *DBMigrator) InitOrMigrate() {
+// migrations 下标 i 的操作将数据库从版本 i 升级到 i+1
+var migrations = []func(*DBMigrator) error{
+	(*DBMigrator).migrateContentAddressed,
+	(*DBMigrator).migrateSortIndexes,
+	(*DBMigrator).migrateRatingIndexes,
+}
+
+func (dm *DBMigrator) InitOrMigrate() error {
 	dm.DB.Logger.Logger.Info("DATABASE MIGRATION START")
 	defer dm.DB.Logger.Logger.Info("DATABASE MIGRATION END")
-	dm.DB.AutoMigrate(&model.Photo{})
+	if err := dm.DB.AutoMigrate(
+		&model.Photo{}, &model.Album{}, &model.AlbumPhoto{}, &model.Tag{}, &model.PhotoTag{},
+		&model.DateShift{}, &model.DateShiftItem{},
+	); err != nil {
+		return err
+	}
+
+	if err := dm.DB.InitFullTextSearch(); err != nil {
+		return err
+	}
+
+	version, err := dm.GetVersion()
+	if err != nil {
+		return err
+	}
+	for ; version < VERSION; version++ {
+		dm.DB.Logger.Logger.Infof("migrate database version %d to %d", version, version+1)
+		if err := migrations[version](dm); err != nil {
+			return fmt.Errorf("migrate database version %d to %d error: %w", version, version+1, err)
+		}
+		if err := dm.setVersion(version + 1); err != nil {
+			return err
+		}
+	}
+	// 更新查询计划使用的统计信息
+	return dm.DB.Exec("PRAGMA optimize").Error
+}
+
+func (dm *DBMigrator) GetVersion() (int, error) {
+	var version int
+	if result := dm.DB.Raw("PRAGMA user_version").Scan(&version); result.Error != nil {
+		return 0, result.Error
+	}
+	return version, nil
+}
+
+func (dm *DBMigrator) setVersion(version int) error {
+	return dm.DB.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)).Error
+}
+
+// migrateContentAddressed 使用 sha256 重新计算摘要，并将本地文件移动到按摘要保存的路径
+// 先链接文件，保存新的 uri 后再删除原来的文件，中断后可以重新执行
+func (dm *DBMigrator) migrateContentAddressed() error {
+	conf := dm.DB.Config
+	var photos []model.Photo
+	result := dm.DB.Unscoped().
+		Where("uri LIKE ?", imagemanager.LOCAL_FILE+"%").
+		FindInBatches(&photos, MIGRATION_BATCH_SIZE, func(tx *gorm.DB, batch int) error {
+			for _, photo := range photos {
+				uri, sum, err := imagemanager.RelocateLocalFile(conf.GetFilesPath(), photo.Uri)
+				if errors.Is(err, os.ErrNotExist) {
+					// 原图已经不存在，保留原来的数据
+					dm.DB.Logger.Logger.Warnf("photo %d original file %s not exists, skip", photo.ID, photo.Uri)
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("relocate photo %d file %s error: %w", photo.ID, photo.Uri, err)
+				}
+				if uri == photo.Uri && sum == photo.Sum {
+					continue
+				}
+				legacyUri := photo.Uri
+				result := dm.DB.Unscoped().Model(&photo).UpdateColumns(map[string]any{"uri": uri, "sum": sum})
+				if result.Error != nil {
+					return result.Error
+				}
+				if err := imagemanager.RemoveLegacyFiles(conf.GetFilesPath(), conf.GetRenderCachePath(), legacyUri); err != nil {
+					return fmt.Errorf("remove photo %d legacy file %s error: %w", photo.ID, legacyUri, err)
+				}
+			}
+			return nil
+		})
+	return result.Error
+}
+
+// 图片列表排序使用的索引，id 用于相同值时的排序
+var sortIndexes = map[string]string{
+	"idx_photos_sort_photo_date": "photo_date, id",
+	"idx_photos_sort_created_at": "created_at, id",
+	"idx_photos_sort_size":       "size, id",
+	"idx_photos_sort_width":      "width, id",
+}
+
+// 收藏和评分排序使用的索引，列由 AutoMigrate 添加
+var ratingIndexes = map[string]string{
+	"idx_photos_sort_rating":   "rating, id",
+	"idx_photos_sort_favorite": "favorite, id",
+}
+
+// migrateSortIndexes 创建图片列表排序的索引
+func (dm *DBMigrator) migrateSortIndexes() error {
+	return dm.createPhotoIndexes(sortIndexes)
+}
+
+// migrateRatingIndexes 创建收藏和评分排序的索引
+func (dm *DBMigrator) migrateRatingIndexes() error {
+	return dm.createPhotoIndexes(ratingIndexes)
+}
+
+func (dm *DBMigrator) createPhotoIndexes(indexes map[string]string) error {
+	for name, columns := range indexes {
+		if err := dm.DB.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON photos(%s)", name, columns)).Error; err != nil {
+			return err
+		}
+	}
+	// 没有统计信息时 sqlite 会优先使用 deleted_at 的索引，再临时排序
+	return dm.DB.Exec("ANALYZE photos").Error
 }
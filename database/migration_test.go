@@ -0,0 +1,185 @@
+package database_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/model"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationTestSuite struct {
+	suite.Suite
+	db       *database.SqliteDB
+	config   *config.Config
+	migrator *database.DBMigrator
+}
+
+func TestMigrationTestSuite(t *testing.T) {
+	suite.Run(t, &MigrationTestSuite{})
+}
+
+func (s *MigrationTestSuite) SetupTest() {
+	appComponents := &appgen.AppComponents{}
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.db = appComponents.DB
+	s.config = appComponents.Config
+	s.migrator = migrator
+}
+
+func (s *MigrationTestSuite) TearDownTest() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+// createLegacyFile 按照之前的时间路径保存图片
+func (s *MigrationTestSuite) createLegacyFile(name string, data []byte) string {
+	dir := filepath.Join(s.config.GetFilesPath(), "202401", "02", "15")
+	s.Nil(os.MkdirAll(dir, 0755))
+	filePath := filepath.Join(dir, name)
+	s.Nil(os.WriteFile(filePath+"_original", data, 0644))
+	s.Nil(os.WriteFile(filePath+"_compressed", data, 0644))
+	return imagemanager.LOCAL_FILE + "/202401/02/15/" + name
+}
+
+func (s *MigrationTestSuite) TestMigrateContentAddressed() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	sum := sha256.Sum256(buf.Bytes())
+	expectedSum := hex.EncodeToString(sum[:])
+
+	s.Nil(s.db.AutoMigrate(&model.Photo{}))
+	// 内容相同的两张图片，其中一张已经删除
+	photos := []model.Photo{
+		{Uri: s.createLegacyFile("20240102150405_aaa", buf.Bytes()), Sum: "md5"},
+		{Uri: s.createLegacyFile("20240102150406_bbb", buf.Bytes()), Sum: "md5"},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	s.Nil(s.db.Delete(&photos[1]).Error)
+
+	s.Nil(s.migrator.InitOrMigrate())
+
+	version, err := s.migrator.GetVersion()
+	s.Nil(err)
+	s.Equal(database.VERSION, version)
+
+	var migrated []model.Photo
+	s.Nil(s.db.Unscoped().Order("id").Find(&migrated).Error)
+	s.Len(migrated, 2)
+	for _, photo := range migrated {
+		s.Equal(expectedSum, photo.Sum)
+		fileUri := imagemanager.NewFileUri(s.config.GetFilesPath(), photo.Uri)
+		s.Equal(expectedSum, fileUri.GetContentSum())
+
+		data, err := os.ReadFile(fileUri.GetOriginalFilePath())
+		s.Nil(err)
+		s.Equal(buf.Bytes(), data)
+		_, err = os.Stat(fileUri.GetCompressedFilePath())
+		s.Nil(err)
+	}
+	s.Equal(migrated[0].Uri, migrated[1].Uri)
+
+	// 之前的目录已经被删除
+	_, err = os.Stat(filepath.Join(s.config.GetFilesPath(), "202401"))
+	s.True(os.IsNotExist(err))
+
+	// 再次执行不做处理
+	s.Nil(s.migrator.InitOrMigrate())
+}
+
+// 上一次迁移中断后重新执行
+func (s *MigrationTestSuite) TestMigrateContentAddressedResume() {
+	s.Nil(s.db.AutoMigrate(&model.Photo{}))
+	images := make([][]byte, 0, 2)
+	photos := make([]model.Photo, 0, 2)
+	for _, name := range []string{"20240102150405_aaa", "20240102150406_bbb"} {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf)
+		s.Nil(err)
+		images = append(images, buf.Bytes())
+		photos = append(photos, model.Photo{Uri: s.createLegacyFile(name, buf.Bytes()), Sum: "md5"})
+	}
+	s.Nil(s.db.Create(&photos).Error)
+
+	// 第一张图片链接文件后中断，第二张图片保存数据后中断
+	_, _, err := imagemanager.RelocateLocalFile(s.config.GetFilesPath(), photos[0].Uri)
+	s.Nil(err)
+	uri, sum, err := imagemanager.RelocateLocalFile(s.config.GetFilesPath(), photos[1].Uri)
+	s.Nil(err)
+	s.Nil(s.db.Model(&photos[1]).UpdateColumns(map[string]any{"uri": uri, "sum": sum}).Error)
+
+	// 执行两次，第二次从版本 0 开始
+	for range 2 {
+		s.Nil(s.migrator.InitOrMigrate())
+		var migrated []model.Photo
+		s.Nil(s.db.Order("id").Find(&migrated).Error)
+		for i, photo := range migrated {
+			expectedSum := sha256.Sum256(images[i])
+			s.Equal(hex.EncodeToString(expectedSum[:]), photo.Sum)
+			data, err := os.ReadFile(imagemanager.NewFileUri(s.config.GetFilesPath(), photo.Uri).GetOriginalFilePath())
+			s.Nil(err)
+			s.Equal(images[i], data)
+		}
+		s.Nil(s.db.Exec("PRAGMA user_version = 0").Error)
+	}
+
+	// 第一张图片原来的文件已经删除
+	_, err = os.Stat(imagemanager.NewFileUri(s.config.GetFilesPath(), photos[0].Uri).GetOriginalFilePath())
+	s.True(os.IsNotExist(err))
+}
+
+func (s *MigrationTestSuite) TestMigrateMissingFile() {
+	s.Nil(s.db.AutoMigrate(&model.Photo{}))
+	photo := model.Photo{Uri: imagemanager.LOCAL_FILE + "/202401/02/15/20240102150405_ccc", Sum: "md5"}
+	s.Nil(s.db.Create(&photo).Error)
+
+	s.Nil(s.migrator.InitOrMigrate())
+
+	var actual model.Photo
+	s.Nil(s.db.First(&actual, photo.ID).Error)
+	s.Equal(photo.Uri, actual.Uri)
+	s.Equal(photo.Sum, actual.Sum)
+}
+
+func (s *MigrationTestSuite) TestMigrateSortIndexes() {
+	s.Nil(s.db.AutoMigrate(&model.Photo{}))
+	photos := make([]model.Photo, 0, 200)
+	for i := range 200 {
+		photos = append(photos, model.Photo{PhotoDate: time.Now().Add(time.Duration(i) * time.Minute)})
+	}
+	s.Nil(s.db.Create(&photos).Error)
+
+	s.Nil(s.migrator.InitOrMigrate())
+
+	for _, name := range []string{
+		"idx_photos_sort_photo_date", "idx_photos_sort_created_at", "idx_photos_sort_size", "idx_photos_sort_width",
+		"idx_photos_sort_rating", "idx_photos_sort_favorite",
+	} {
+		s.True(s.db.Migrator().HasIndex(&model.Photo{}, name), name)
+	}
+
+	// 排序时使用索引，不需要临时排序
+	var plans []struct {
+		Detail string
+	}
+	s.Nil(s.db.Raw("EXPLAIN QUERY PLAN SELECT * FROM photos WHERE deleted_at IS NULL " +
+		"ORDER BY photo_date DESC, id DESC LIMIT 20").Scan(&plans).Error)
+	s.NotEmpty(plans)
+	for _, plan := range plans {
+		s.NotContains(plan.Detail, "TEMP B-TREE")
+	}
+}
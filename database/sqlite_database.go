@@ -3,6 +3,7 @@ package database
 import (
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/follow1123/photos/config"
 	"github.com/follow1123/photos/logger"
@@ -15,6 +16,9 @@ type SqliteDB struct {
 	Config *config.Config
 	DBFile string
 	Logger *logger.GormLogger
+
+	ftsOnce    sync.Once
+	ftsEnabled bool
 }
 
 func NewDatabase(config *config.Config, gormLogger *logger.GormLogger) (*SqliteDB, error) {
@@ -178,7 +178,14 @@ func GenImageManager(appComponents *AppComponents) (*imagemanager.ImageManager,
 		appComponents.AppLogger = appLogger
 	}
 
-	return imagemanager.NewImageManager(appComponents.Config.GetFilesPath(), appComponents.ImageCache, appComponents.AppLogger), nil
+	return imagemanager.NewImageManager(
+		appComponents.Config.GetFilesPath(),
+		appComponents.ImageCache,
+		appComponents.AppLogger,
+		imagemanager.WithRenderRoot(appComponents.Config.GetRenderCachePath()),
+		imagemanager.WithRenderMemoryBudget(imagemanager.NewMemoryBudget(appComponents.Config.GetMemoryBudget())),
+		imagemanager.WithRemoteCredentials(appComponents.Config.GetRemoteCredentials()),
+	), nil
 }
 
 func GenAppContext(appComponents *AppComponents) (*application.AppContext, error) {
@@ -0,0 +1,242 @@
+package imagegen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	tiffTypeByte     uint16 = 1
+	tiffTypeAscii    uint16 = 2
+	tiffTypeShort    uint16 = 3
+	tiffTypeLong     uint16 = 4
+	tiffTypeRational uint16 = 5
+)
+
+// Exif 生成图片时写入的 exif 信息，零值字段不写入
+type Exif struct {
+	DateTimeOriginal time.Time
+	Make             string
+	Model            string
+	LensModel        string
+	Orientation      uint16
+	ISO              uint16
+	ExposureTime     [2]uint32
+	FNumber          [2]uint32
+	FocalLength      [2]uint32
+	GPS              *GPS
+}
+
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+}
+
+func WithExif(exif *Exif) Option {
+	return optionFunc(func(igo *ImageGenOption) {
+		igo.Exif = exif
+	})
+}
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func asciiEntry(tag uint16, val string) ifdEntry {
+	data := append([]byte(val), 0)
+	return ifdEntry{tag: tag, typ: tiffTypeAscii, count: uint32(len(data)), data: data}
+}
+
+func byteEntry(tag uint16, val byte) ifdEntry {
+	return ifdEntry{tag: tag, typ: tiffTypeByte, count: 1, data: []byte{val}}
+}
+
+func shortEntry(tag uint16, val uint16) ifdEntry {
+	data := binary.LittleEndian.AppendUint16(nil, val)
+	return ifdEntry{tag: tag, typ: tiffTypeShort, count: 1, data: data}
+}
+
+func longEntry(tag uint16, val uint32) ifdEntry {
+	data := binary.LittleEndian.AppendUint32(nil, val)
+	return ifdEntry{tag: tag, typ: tiffTypeLong, count: 1, data: data}
+}
+
+func rationalEntry(tag uint16, vals ...[2]uint32) ifdEntry {
+	var data []byte
+	for _, val := range vals {
+		data = binary.LittleEndian.AppendUint32(data, val[0])
+		data = binary.LittleEndian.AppendUint32(data, val[1])
+	}
+	return ifdEntry{tag: tag, typ: tiffTypeRational, count: uint32(len(vals)), data: data}
+}
+
+// degreesToRationals 将度数转换为 度/分/秒 三个分数
+func degreesToRationals(val float64) [][2]uint32 {
+	val = math.Abs(val)
+	degrees := math.Floor(val)
+	minutes := math.Floor((val - degrees) * 60)
+	seconds := ((val-degrees)*60 - minutes) * 60
+	return [][2]uint32{
+		{uint32(degrees), 1},
+		{uint32(minutes), 1},
+		{uint32(math.Round(seconds * 10000)), 10000},
+	}
+}
+
+func ifdSize(entries []ifdEntry) uint32 {
+	size := uint32(2 + 12*len(entries) + 4)
+	for _, entry := range entries {
+		if len(entry.data) > 4 {
+			size += uint32(len(entry.data))
+		}
+	}
+	return size
+}
+
+// buildIfd 生成 offset 位置的 IFD，大于 4 字节的值放在 IFD 之后
+func buildIfd(entries []ifdEntry, offset uint32) []byte {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	buf := binary.LittleEndian.AppendUint16(nil, uint16(len(entries)))
+	var values []byte
+	valueOffset := offset + uint32(2+12*len(entries)+4)
+	for _, entry := range entries {
+		buf = binary.LittleEndian.AppendUint16(buf, entry.tag)
+		buf = binary.LittleEndian.AppendUint16(buf, entry.typ)
+		buf = binary.LittleEndian.AppendUint32(buf, entry.count)
+		if len(entry.data) > 4 {
+			buf = binary.LittleEndian.AppendUint32(buf, valueOffset+uint32(len(values)))
+			values = append(values, entry.data...)
+		} else {
+			inline := make([]byte, 4)
+			copy(inline, entry.data)
+			buf = append(buf, inline...)
+		}
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	return append(buf, values...)
+}
+
+// BuildExif 生成 tiff 格式的 exif 数据
+func BuildExif(exif *Exif) []byte {
+	var ifd0, exifIfd []ifdEntry
+	if exif.Make != "" {
+		ifd0 = append(ifd0, asciiEntry(0x010F, exif.Make))
+	}
+	if exif.Model != "" {
+		ifd0 = append(ifd0, asciiEntry(0x0110, exif.Model))
+	}
+	if exif.Orientation != 0 {
+		ifd0 = append(ifd0, shortEntry(0x0112, exif.Orientation))
+	}
+
+	if exif.ExposureTime[1] != 0 {
+		exifIfd = append(exifIfd, rationalEntry(0x829A, exif.ExposureTime))
+	}
+	if exif.FNumber[1] != 0 {
+		exifIfd = append(exifIfd, rationalEntry(0x829D, exif.FNumber))
+	}
+	if exif.ISO != 0 {
+		exifIfd = append(exifIfd, shortEntry(0x8827, exif.ISO))
+	}
+	if !exif.DateTimeOriginal.IsZero() {
+		exifIfd = append(exifIfd, asciiEntry(0x9003, exif.DateTimeOriginal.Format("2006:01:02 15:04:05")))
+	}
+	if exif.FocalLength[1] != 0 {
+		exifIfd = append(exifIfd, rationalEntry(0x920A, exif.FocalLength))
+	}
+	if exif.LensModel != "" {
+		exifIfd = append(exifIfd, asciiEntry(0xA434, exif.LensModel))
+	}
+
+	var gpsIfd []ifdEntry
+	if exif.GPS != nil {
+		latRef, lngRef, altRef := "N", "E", byte(0)
+		if exif.GPS.Latitude < 0 {
+			latRef = "S"
+		}
+		if exif.GPS.Longitude < 0 {
+			lngRef = "W"
+		}
+		if exif.GPS.Altitude < 0 {
+			altRef = 1
+		}
+		altitude := uint32(math.Round(math.Abs(exif.GPS.Altitude) * 100))
+		gpsIfd = append(gpsIfd,
+			asciiEntry(0x0001, latRef),
+			rationalEntry(0x0002, degreesToRationals(exif.GPS.Latitude)...),
+			asciiEntry(0x0003, lngRef),
+			rationalEntry(0x0004, degreesToRationals(exif.GPS.Longitude)...),
+			byteEntry(0x0005, altRef),
+			rationalEntry(0x0006, [2]uint32{altitude, 100}),
+		)
+	}
+
+	const headerSize = 8
+	if len(exifIfd) > 0 {
+		ifd0 = append(ifd0, longEntry(0x8769, 0))
+	}
+	if len(gpsIfd) > 0 {
+		ifd0 = append(ifd0, longEntry(0x8825, 0))
+	}
+	exifIfdOffset := headerSize + ifdSize(ifd0)
+	gpsIfdOffset := exifIfdOffset
+	if len(exifIfd) > 0 {
+		gpsIfdOffset += ifdSize(exifIfd)
+	}
+	for i := range ifd0 {
+		switch ifd0[i].tag {
+		case 0x8769:
+			ifd0[i] = longEntry(0x8769, exifIfdOffset)
+		case 0x8825:
+			ifd0[i] = longEntry(0x8825, gpsIfdOffset)
+		}
+	}
+
+	buf := []byte("II*\x00")
+	buf = binary.LittleEndian.AppendUint32(buf, headerSize)
+	buf = append(buf, buildIfd(ifd0, headerSize)...)
+	if len(exifIfd) > 0 {
+		buf = append(buf, buildIfd(exifIfd, exifIfdOffset)...)
+	}
+	if len(gpsIfd) > 0 {
+		buf = append(buf, buildIfd(gpsIfd, gpsIfdOffset)...)
+	}
+	return buf
+}
+
+// insertExif 将 exif 数据写入已编码的图片内
+func insertExif(data []byte, format string, exif *Exif) []byte {
+	tiffData := BuildExif(exif)
+	var buf bytes.Buffer
+	switch format {
+	case FORMAT_JPEG:
+		// SOI 之后插入 APP1
+		payload := append([]byte("Exif\x00\x00"), tiffData...)
+		buf.Write(data[:2])
+		buf.Write([]byte{0xFF, 0xE1})
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2)))
+		buf.Write(payload)
+		buf.Write(data[2:])
+	case FORMAT_PNG:
+		// IHDR 之后插入 eXIf
+		const ihdrEnd = 8 + 25
+		buf.Write(data[:ihdrEnd])
+		chunk := append([]byte("eXIf"), tiffData...)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(tiffData))))
+		buf.Write(chunk)
+		buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(chunk)))
+		buf.Write(data[ihdrEnd:])
+	default:
+		return data
+	}
+	return buf.Bytes()
+}
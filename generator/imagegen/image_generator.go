@@ -1,18 +1,27 @@
 package imagegen
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"math"
 	"math/rand/v2"
+	"slices"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 const (
 	FORMAT_PNG  = "png"
 	FORMAT_JPEG = "jpeg"
+	FORMAT_GIF  = "gif"
+	FORMAT_BMP  = "bmp"
+	FORMAT_TIFF = "tiff"
 
 	IMG_MIN_WIDTH  = 1280
 	IMG_MIN_HEIGHT = 800
@@ -46,6 +55,9 @@ var FixedColors = []color.RGBA{
 
 var FixedFormats = []string{FORMAT_PNG, FORMAT_JPEG}
 
+// AllFormats 可以生成的所有格式，随机生成时只使用 FixedFormats
+var AllFormats = []string{FORMAT_PNG, FORMAT_JPEG, FORMAT_GIF, FORMAT_BMP, FORMAT_TIFF}
+
 var FixedStyles = []Randomize{
 	&StylePureColor{},
 	&StyleCircular{},
@@ -234,6 +246,32 @@ func (slg *StyleLinearGradient) Render(img *image.RGBA, width int, height int) {
 	}
 }
 
+// StyleBlocks 8x8 个随机亮度的灰色方块，Seed 相同时不同尺寸的图片内容相同
+type StyleBlocks struct {
+	Seed uint64
+}
+
+func (sb *StyleBlocks) Randomize() ImageStyle {
+	sb.Seed = rand.Uint64()
+	return sb
+}
+
+func (sb *StyleBlocks) Render(img *image.RGBA, width int, height int) {
+	r := rand.New(rand.NewPCG(sb.Seed, sb.Seed))
+	var blocks [8][8]uint8
+	for y := range 8 {
+		for x := range 8 {
+			blocks[y][x] = uint8(r.IntN(256))
+		}
+	}
+	for x := range width {
+		for y := range height {
+			v := blocks[y*8/height][x*8/width]
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+}
+
 type Option interface {
 	apply(*ImageGenOption)
 }
@@ -258,7 +296,7 @@ func WithSize(width int, height int) Option {
 }
 
 func WithFormat(format string) Option {
-	if format != FORMAT_PNG && format != FORMAT_JPEG {
+	if !slices.Contains(AllFormats, format) {
 		panic("invalid image format")
 	}
 	return optionFunc(func(igo *ImageGenOption) {
@@ -277,6 +315,7 @@ type ImageGenOption struct {
 	Style  ImageStyle
 	Width  int
 	Height int
+	Exif   *Exif
 }
 
 func RandomWidth() int {
@@ -338,16 +377,33 @@ func GenImage(writer io.Writer, opts ...Option) (*ImageInfo, error) {
 
 	style.Render(img, width, height)
 
-	var err error
+	var (
+		err error
+		buf bytes.Buffer
+	)
 	switch format {
 	case FORMAT_PNG:
-		err = png.Encode(writer, img)
+		err = png.Encode(&buf, img)
 	case FORMAT_JPEG:
-		err = jpeg.Encode(writer, img, nil)
+		err = jpeg.Encode(&buf, img, nil)
+	case FORMAT_GIF:
+		err = gif.Encode(&buf, img, nil)
+	case FORMAT_BMP:
+		err = bmp.Encode(&buf, img)
+	case FORMAT_TIFF:
+		err = tiff.Encode(&buf, img, nil)
 	}
 	if err != nil {
 		return nil, err
 	}
+
+	data := buf.Bytes()
+	if genOption.Exif != nil {
+		data = insertExif(data, format, genOption.Exif)
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
 	return &ImageInfo{
 		Format: format,
 		Width:  width,
@@ -0,0 +1,108 @@
+package imagemanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func fileExists(filePath string) (bool, error) {
+	_, err := os.Stat(filePath)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// linkFile 将文件链接到新的路径，不支持硬链接时复制，源文件保留
+// 目标文件已经存在时说明内容相同，不做处理
+func linkFile(src string, dst string) error {
+	exists, err := fileExists(dst)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	// 先复制到临时文件再重命名，中断时不会留下不完整的目标文件
+	tmp := dst + ".tmp"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// removeEmptyDirs 从 dir 开始向上删除空目录，直到 root
+func removeEmptyDirs(dir string, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// RelocateLocalFile 将按时间保存的本地文件链接到按内容摘要保存的路径，原来的文件保留
+// 返回新的 uri 和文件的 sha256，已经是新路径的文件不做处理
+// 保存新的 uri 后再使用 RemoveLegacyFiles 删除原来的文件，中断后重新执行时原来的文件仍然存在
+func RelocateLocalFile(filesRoot string, uri string) (string, string, error) {
+	oldUri := NewFileUri(filesRoot, uri)
+	if !oldUri.Is(LOCAL_FILE) {
+		return uri, "", ErrUnsupportedRemoteFiles
+	}
+	if hexSum := oldUri.GetContentSum(); hexSum != "" {
+		return uri, hexSum, nil
+	}
+
+	hexSum, err := HashFile(oldUri.GetOriginalFilePath())
+	if err != nil {
+		return "", "", err
+	}
+	newUri := CreateLocalFileUri(filesRoot, hexSum)
+	if err := newUri.CreateFilePath(); err != nil {
+		return "", "", err
+	}
+	if err := linkFile(oldUri.GetOriginalFilePath(), newUri.GetOriginalFilePath()); err != nil {
+		return "", "", err
+	}
+	for _, rendition := range Renditions {
+		// 之前上传的图片只有 compressed 文件
+		err := linkFile(oldUri.GetRenditionFilePath(rendition), newUri.GetRenditionFilePath(rendition))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+	}
+	return newUri.String(), hexSum, nil
+}
+
+// RemoveLegacyFiles 删除 RelocateLocalFile 之前按时间保存的文件和渲染缓存
+func RemoveLegacyFiles(filesRoot string, renderRoot string, uri string) error {
+	oldUri := NewFileUri(filesRoot, uri)
+	if !oldUri.Is(LOCAL_FILE) || oldUri.GetContentSum() != "" {
+		return nil
+	}
+	fileNames := []string{oldUri.GetOriginalFilePath()}
+	for _, rendition := range Renditions {
+		fileNames = append(fileNames, oldUri.GetRenditionFilePath(rendition))
+	}
+	for _, fileName := range fileNames {
+		if err := os.Remove(fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if renderRoot != "" {
+		if err := os.RemoveAll(RenderCacheDir(renderRoot, oldUri.String())); err != nil {
+			return err
+		}
+	}
+	removeEmptyDirs(filepath.Dir(oldUri.filePath), filesRoot)
+	return nil
+}
@@ -0,0 +1,31 @@
+package imagemanager
+
+import "sync"
+
+// ContentLock 按内容摘要加锁，相同内容的图片使用同一个文件
+// 保存文件到保存数据期间，其他上传或者删除相同文件的操作需要等待
+type ContentLock struct {
+	// 值为 chan struct{}，解锁时关闭
+	locked sync.Map
+}
+
+func NewContentLock() *ContentLock {
+	return &ContentLock{}
+}
+
+// Lock 锁定内容摘要，已经被锁定时等待解锁
+func (cl *ContentLock) Lock(hexSum string) {
+	for {
+		unlocked, loaded := cl.locked.LoadOrStore(hexSum, make(chan struct{}))
+		if !loaded {
+			return
+		}
+		<-unlocked.(chan struct{})
+	}
+}
+
+func (cl *ContentLock) Unlock(hexSum string) {
+	if unlocked, ok := cl.locked.LoadAndDelete(hexSum); ok {
+		close(unlocked.(chan struct{}))
+	}
+}
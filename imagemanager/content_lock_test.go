@@ -0,0 +1,45 @@
+package imagemanager_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentLockTestSuite struct {
+	suite.Suite
+}
+
+func TestContentLockTestSuite(t *testing.T) {
+	suite.Run(t, &ContentLockTestSuite{})
+}
+
+func (s *ContentLockTestSuite) TestLockWait() {
+	lock := imagemanager.NewContentLock()
+	lock.Lock("a")
+	// 不同的摘要不等待
+	lock.Lock("b")
+	lock.Unlock("b")
+
+	locked := make(chan struct{})
+	go func() {
+		lock.Lock("a")
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		s.Fail("lock should wait for unlock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lock.Unlock("a")
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		s.Fail("lock not released")
+	}
+	lock.Unlock("a")
+}
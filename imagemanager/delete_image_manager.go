@@ -1,28 +1,39 @@
 package imagemanager
 
-import "os"
+import (
+	"errors"
+	"os"
+)
 
 type DeleteImageManager struct {
-	uri FileUri
+	uri        FileUri
+	renderRoot string
 }
 
-func NewDeleteImageManager(filesRoot string, uri string) *DeleteImageManager {
+func NewDeleteImageManager(filesRoot string, uri string, renderRoot string) *DeleteImageManager {
 	return &DeleteImageManager{
-		uri: *NewFileUri(filesRoot, uri),
+		uri:        *NewFileUri(filesRoot, uri),
+		renderRoot: renderRoot,
 	}
 }
 
 func (dim *DeleteImageManager) Delete() error {
 	originalFilePath := dim.uri.GetOriginalFilePath()
-	compressedFilePath := dim.uri.GetCompressedFilePath()
 
 	if dim.uri.Is(LOCAL_FILE) {
-		if err := os.Remove(originalFilePath); err != nil {
+		if err := os.Remove(originalFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return err
 		}
 	}
-	if err := os.Remove(compressedFilePath); err != nil {
-		return err
+	for _, rendition := range Renditions {
+		// 之前上传的图片只有 compressed 文件
+		err := os.Remove(dim.uri.GetRenditionFilePath(rendition))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if dim.renderRoot != "" {
+		return os.RemoveAll(RenderCacheDir(dim.renderRoot, dim.uri.String()))
 	}
 	return nil
 }
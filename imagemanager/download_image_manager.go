@@ -1,16 +1,23 @@
 package imagemanager
 
 import (
+	"bytes"
+	"errors"
+	"image"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/follow1123/photos/logger"
 )
 
 type DownloadImageManager struct {
 	logger.AppLogger
-	uri   FileUri
-	cache *ImageCache
+	uri        FileUri
+	cache      *ImageCache
+	renderRoot string
+	budget     *MemoryBudget
+	remote     *RemoteOpener
 }
 
 func NewDownloadImageManager(
@@ -18,11 +25,17 @@ func NewDownloadImageManager(
 	uri string,
 	logger *logger.AppLogger,
 	cache *ImageCache,
+	renderRoot string,
+	budget *MemoryBudget,
+	remote *RemoteOpener,
 ) *DownloadImageManager {
 	return &DownloadImageManager{
-		uri:       *NewFileUri(filesRoot, uri),
-		cache:     cache,
-		AppLogger: *logger,
+		uri:        *NewFileUri(filesRoot, uri),
+		cache:      cache,
+		renderRoot: renderRoot,
+		budget:     budget,
+		remote:     remote,
+		AppLogger:  *logger,
 	}
 }
 
@@ -37,22 +50,98 @@ func (dim *DownloadImageManager) OpenOriginal() (io.ReadCloser, error) {
 		}
 		return file, nil
 	} else {
-		panic("TODO: implement read remote file path")
+		reader, err := dim.remote.Open(originalFilePath)
+		if err != nil {
+			dim.Debug("download manager open remote file %s error: %v", originalFilePath, err)
+			return nil, err
+		}
+		return reader, nil
 	}
 }
 
 func (dim *DownloadImageManager) GetCompressed() ([]byte, error) {
-	data, ok := dim.cache.Get(dim.uri.String())
+	return dim.GetRendition(PreviewRendition())
+}
+
+func (dim *DownloadImageManager) GetRendition(rendition Rendition) ([]byte, error) {
+	cacheKey := dim.uri.GetRenditionCacheKey(rendition)
+	data, ok := dim.cache.Get(cacheKey)
 	if ok {
-		dim.Debug("get compressed image from cache")
+		dim.Debug("get %s rendition from cache", rendition.Name)
 		return data, nil
 	}
-	dim.Debug("get compressed image from local file")
-	compressedFilePath := dim.uri.GetCompressedFilePath()
-	imageData, err := os.ReadFile(compressedFilePath)
+	dim.Debug("get %s rendition from local file", rendition.Name)
+	imageData, err := os.ReadFile(dim.uri.GetRenditionFilePath(rendition))
 	if err != nil {
-		return nil, err
+		// 之前上传的图片只有 compressed 文件
+		if !errors.Is(err, os.ErrNotExist) || rendition.Name == RENDITION_PREVIEW {
+			return nil, err
+		}
+		dim.Debug("%s rendition not exists, fallback to preview", rendition.Name)
+		return dim.GetRendition(PreviewRendition())
 	}
-	dim.cache.Set(dim.uri.String(), imageData, 1)
+	dim.cache.Set(cacheKey, imageData, 1)
 	return imageData, nil
 }
+
+// GetPerceptualHash 使用已保存的 thumb 文件计算感知哈希
+func (dim *DownloadImageManager) GetPerceptualHash() (uint64, error) {
+	thumb, _ := GetRendition(RENDITION_THUMB)
+	data, err := dim.GetRendition(thumb)
+	if err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	// 之前上传的图片只有 preview 文件
+	return DHash(Resize(img, thumb.MaxSize)), nil
+}
+
+// Render 从原图生成指定尺寸和格式的图片，结果缓存在磁盘上
+// opt 需要先调用 Normalize 填充默认值
+func (dim *DownloadImageManager) Render(opt RenderOption) (io.ReadCloser, *ImageInfo, error) {
+	if dim.renderRoot == "" {
+		return nil, nil, ErrRenderDisabled
+	}
+	cacheFile := filepath.Join(RenderCacheDir(dim.renderRoot, dim.uri.String()), opt.CacheName())
+
+	file, err := os.Open(cacheFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, err
+		}
+		dim.Debug("render cache not exists, render from original: %s", cacheFile)
+		var processor *ImageProcessor
+		if dim.uri.Is(LOCAL_FILE) {
+			processor = NewImageFileProcessor(dim.uri.GetOriginalFilePath(), &dim.AppLogger, WithMemoryBudget(dim.budget))
+		} else {
+			original, err := dim.OpenOriginal()
+			if err != nil {
+				return nil, nil, err
+			}
+			processor = NewImageProcessor(original, &dim.AppLogger, WithMemoryBudget(dim.budget))
+		}
+		defer processor.Close()
+		img, err := processor.decode()
+		if err != nil {
+			return nil, nil, err
+		}
+		img = Transform(img, opt.Width, opt.Height, opt.Fit)
+		if err := writeRenderCache(cacheFile, img, opt); err != nil {
+			dim.Error("write render cache error: %v", err)
+			return nil, nil, err
+		}
+		if file, err = os.Open(cacheFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, &ImageInfo{Size: info.Size(), Format: opt.Format}, nil
+}
@@ -0,0 +1,86 @@
+package imagemanager_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type DownloadImageManagerTestSuite struct {
+	suite.Suite
+	conf   *config.Config
+	logger *logger.AppLogger
+	cache  *imagemanager.ImageCache
+}
+
+func TestDownloadImageManagerTestSuite(t *testing.T) {
+	suite.Run(t, &DownloadImageManagerTestSuite{})
+}
+
+func (s *DownloadImageManagerTestSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	conf, err := appgen.GenConfig(appComponents)
+	s.Nil(err)
+	conf.CreatePath()
+	appLogger, err := appgen.GenAppLogger(appComponents)
+	s.Nil(err)
+	imageCache, err := appgen.GenImageCache(appComponents)
+	s.Nil(err)
+
+	s.conf = conf
+	s.logger = appLogger
+	s.cache = imageCache
+}
+
+func (s *DownloadImageManagerTestSuite) TearDownSuite() {
+	s.cache.Close()
+	s.conf.DeletePath()
+}
+
+func (s *DownloadImageManagerTestSuite) TestRenderMemoryBudget() {
+	budget := imagemanager.NewMemoryBudget(1 << 30)
+	imageManager := imagemanager.NewImageManager(
+		s.conf.GetFilesPath(),
+		s.cache,
+		s.logger,
+		imagemanager.WithRenderRoot(s.conf.GetRenderCachePath()),
+		imagemanager.WithRenderMemoryBudget(budget),
+	)
+
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	uploadMgr := imageManager.NewUploadManager(imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "render"))
+	uri, err := uploadMgr.Save()
+	s.Nil(err)
+	s.Nil(uploadMgr.Close())
+
+	// 预算被占满时等待其他渲染完成
+	acquired := budget.Acquire(1 << 30)
+	done := make(chan error)
+	go func() {
+		rc, _, err := imageManager.NewDownloadManager(uri).Render(imagemanager.RenderOption{Width: 100}.Normalize("png"))
+		if err == nil {
+			_, err = io.ReadAll(rc)
+			rc.Close()
+		}
+		done <- err
+	}()
+	select {
+	case <-done:
+		s.Fail("render should wait for memory budget")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	budget.Release(acquired)
+	s.Nil(<-done)
+	s.Equal(int64(0), budget.InUse())
+}
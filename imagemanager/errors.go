@@ -5,3 +5,8 @@ import "errors"
 var ErrUnsupportedImageFormat = errors.New("Unsupported image format")
 var ErrInvalidFileType = errors.New("invalid file type")
 var ErrUnsupportedRemoteFiles = errors.New("unsupported remote file")
+var ErrUnsupportedRendition = errors.New("unsupported rendition")
+var ErrRenderDisabled = errors.New("render cache path not configured")
+var ErrImageProcessorClosed = errors.New("image processor closed")
+var ErrImageTooLarge = errors.New("image too large")
+var ErrRemoteUriCredentials = errors.New("remote uri must not contain credentials")
@@ -0,0 +1,252 @@
+package imagemanager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+const (
+	EXIF_TIME_LAYOUT = "2006:01:02 15:04:05"
+
+	// png、webp 内 exif 块的最大长度
+	EXIF_MAX_CHUNK_SIZE = 4 << 20
+	// tiff 文件解析 exif 时最多读取的长度，exif 位于超出部分时忽略
+	EXIF_MAX_TIFF_SIZE = 16 << 20
+)
+
+var ErrExifNotFound = errors.New("exif not found")
+
+type ExifInfo struct {
+	DateTimeOriginal time.Time
+	CameraMake       string
+	CameraModel      string
+	LensModel        string
+	ExposureTime     string
+	FNumber          float64
+	ISO              int64
+	FocalLength      float64
+	Orientation      int64
+	Latitude         *float64
+	Longitude        *float64
+	Altitude         *float64
+}
+
+// ParseExif 解析图片内的 exif 信息，支持 jpeg、tiff、png（eXIf 块）和 webp（EXIF 块）
+func ParseExif(data []byte, format string) (*ExifInfo, error) {
+	return ParseExifReader(bytes.NewReader(data), format)
+}
+
+// ParseExifReader 从文件内解析 exif 信息，只读取 exif 所在的数据块
+func ParseExifReader(rs io.ReadSeeker, format string) (*ExifInfo, error) {
+	var r io.Reader
+	switch format {
+	case "jpeg":
+		// jpeg 的 exif 在文件头部的 APP1 段内，解析时会边读边查找
+		r = bufio.NewReader(rs)
+	case "tiff":
+		// tiff 解析时会读取全部数据，限制读取的大小
+		r = io.LimitReader(rs, EXIF_MAX_TIFF_SIZE)
+	case "png":
+		chunk, err := findPngExifChunk(rs)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(chunk)
+	case "webp":
+		chunk, err := findWebpExifChunk(rs)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(chunk)
+	default:
+		return nil, ErrExifNotFound
+	}
+
+	x, err := exif.Decode(r)
+	if err != nil {
+		if x == nil || exif.IsCriticalError(err) {
+			return nil, err
+		}
+	}
+
+	info := &ExifInfo{
+		CameraMake:   exifString(x, exif.Make),
+		CameraModel:  exifString(x, exif.Model),
+		LensModel:    exifString(x, exif.LensModel),
+		ExposureTime: exifRatString(x, exif.ExposureTime),
+		FNumber:      exifFloat(x, exif.FNumber),
+		ISO:          exifInt(x, exif.ISOSpeedRatings),
+		FocalLength:  exifFloat(x, exif.FocalLength),
+		Orientation:  exifInt(x, exif.Orientation),
+	}
+	info.DateTimeOriginal = exifDateTime(x)
+	if lat, lng, err := x.LatLong(); err == nil && validCoordinate(lat, lng) {
+		info.Latitude = &lat
+		info.Longitude = &lng
+		info.Altitude = exifAltitude(x)
+	}
+	return info, nil
+}
+
+// readExifChunk 读取当前位置长度为 length 的 exif 数据块
+func readExifChunk(r io.Reader, length uint32) ([]byte, error) {
+	if length > EXIF_MAX_CHUNK_SIZE {
+		return nil, ErrExifNotFound
+	}
+	chunk := make([]byte, length)
+	if _, err := io.ReadFull(r, chunk); err != nil {
+		return nil, ErrExifNotFound
+	}
+	return chunk, nil
+}
+
+// findPngExifChunk 在 png 数据内查找 eXIf 块，跳过其他块的数据
+func findPngExifChunk(rs io.ReadSeeker) ([]byte, error) {
+	const pngHeader = "\x89PNG\r\n\x1a\n"
+	header := make([]byte, len(pngHeader))
+	if _, err := io.ReadFull(rs, header); err != nil || string(header) != pngHeader {
+		return nil, ErrExifNotFound
+	}
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(rs, chunkHeader); err != nil {
+			return nil, ErrExifNotFound
+		}
+		length := binary.BigEndian.Uint32(chunkHeader[:4])
+		switch string(chunkHeader[4:8]) {
+		case "eXIf":
+			return readExifChunk(rs, length)
+		case "IDAT", "IEND":
+			// eXIf 块必须在 IDAT 之前
+			return nil, ErrExifNotFound
+		}
+		// 跳过块数据和 crc
+		if _, err := rs.Seek(int64(length)+4, io.SeekCurrent); err != nil {
+			return nil, ErrExifNotFound
+		}
+	}
+}
+
+// findWebpExifChunk 在 webp 数据内查找 EXIF 块，跳过其他块的数据
+func findWebpExifChunk(rs io.ReadSeeker) ([]byte, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(rs, header); err != nil || string(header[:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return nil, ErrExifNotFound
+	}
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(rs, chunkHeader); err != nil {
+			return nil, ErrExifNotFound
+		}
+		length := binary.LittleEndian.Uint32(chunkHeader[4:8])
+		if string(chunkHeader[:4]) == "EXIF" {
+			return readExifChunk(rs, length)
+		}
+		// 块的长度为奇数时有一个字节的填充
+		if _, err := rs.Seek(int64(length)+int64(length%2), io.SeekCurrent); err != nil {
+			return nil, ErrExifNotFound
+		}
+	}
+}
+
+func exifTag(x *exif.Exif, name exif.FieldName) *tiff.Tag {
+	tag, err := x.Get(name)
+	if err != nil {
+		return nil
+	}
+	return tag
+}
+
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag := exifTag(x, name)
+	if tag == nil || tag.Format() != tiff.StringVal {
+		return ""
+	}
+	val, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(val, "\x00"))
+}
+
+func exifInt(x *exif.Exif, name exif.FieldName) int64 {
+	tag := exifTag(x, name)
+	if tag == nil || tag.Format() != tiff.IntVal {
+		return 0
+	}
+	val, err := tag.Int64(0)
+	if err != nil {
+		return 0
+	}
+	return val
+}
+
+func exifFloat(x *exif.Exif, name exif.FieldName) float64 {
+	tag := exifTag(x, name)
+	if tag == nil || tag.Format() != tiff.RatVal {
+		return 0
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// exifRatString 以分数形式返回，如曝光时间 1/125
+func exifRatString(x *exif.Exif, name exif.FieldName) string {
+	tag := exifTag(x, name)
+	if tag == nil || tag.Format() != tiff.RatVal {
+		return ""
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return ""
+	}
+	if num >= den {
+		return fmt.Sprintf("%g", float64(num)/float64(den))
+	}
+	if num != 0 && den%num == 0 {
+		return fmt.Sprintf("1/%d", den/num)
+	}
+	return fmt.Sprintf("%d/%d", num, den)
+}
+
+func exifDateTime(x *exif.Exif) time.Time {
+	for _, name := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTimeDigitized, exif.DateTime} {
+		dateStr := exifString(x, name)
+		if dateStr == "" {
+			continue
+		}
+		t, err := time.ParseInLocation(EXIF_TIME_LAYOUT, dateStr, time.Local)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func exifAltitude(x *exif.Exif) *float64 {
+	if exifTag(x, exif.GPSAltitude) == nil {
+		return nil
+	}
+	altitude := exifFloat(x, exif.GPSAltitude)
+	// 1 表示海平面以下
+	if exifInt(x, exif.GPSAltitudeRef) == 1 {
+		altitude = -altitude
+	}
+	return &altitude
+}
+
+func validCoordinate(lat float64, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
@@ -0,0 +1,80 @@
+package imagemanager_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExifTestSuite struct {
+	suite.Suite
+}
+
+func TestExifTestSuite(t *testing.T) {
+	suite.Run(t, &ExifTestSuite{})
+}
+
+func (s *ExifTestSuite) TestParseExifSuccess() {
+	expectedExif := &imagegen.Exif{
+		DateTimeOriginal: time.Date(2021, 6, 18, 9, 30, 15, 0, time.Local),
+		Make:             "FUJIFILM",
+		Model:            "X100V",
+		LensModel:        "23mm F2",
+		Orientation:      6,
+		ISO:              400,
+		ExposureTime:     [2]uint32{1, 250},
+		FNumber:          [2]uint32{28, 10},
+		FocalLength:      [2]uint32{23, 1},
+	}
+
+	for _, format := range imagegen.FixedFormats {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf, imagegen.WithFormat(format), imagegen.WithExif(expectedExif))
+		s.Nil(err)
+
+		exifInfo, err := imagemanager.ParseExif(buf.Bytes(), format)
+		s.Nil(err)
+		s.True(expectedExif.DateTimeOriginal.Equal(exifInfo.DateTimeOriginal))
+		s.Equal(expectedExif.Make, exifInfo.CameraMake)
+		s.Equal(expectedExif.Model, exifInfo.CameraModel)
+		s.Equal(expectedExif.LensModel, exifInfo.LensModel)
+		s.Equal(int64(expectedExif.Orientation), exifInfo.Orientation)
+		s.Equal(int64(expectedExif.ISO), exifInfo.ISO)
+		s.Equal("1/250", exifInfo.ExposureTime)
+		s.InDelta(2.8, exifInfo.FNumber, 0.001)
+		s.InDelta(23.0, exifInfo.FocalLength, 0.001)
+	}
+}
+
+func (s *ExifTestSuite) TestParseExifFailure() {
+	for _, format := range imagegen.FixedFormats {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf, imagegen.WithFormat(format))
+		s.Nil(err)
+
+		exifInfo, err := imagemanager.ParseExif(buf.Bytes(), format)
+		s.Nil(exifInfo)
+		s.NotNil(err)
+	}
+}
+
+func (s *ExifTestSuite) TestParseExifGPS() {
+	expectedGPS := &imagegen.GPS{Latitude: -33.8568, Longitude: 151.2153, Altitude: -12.5}
+
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf, imagegen.WithFormat(imagegen.FORMAT_JPEG), imagegen.WithExif(&imagegen.Exif{GPS: expectedGPS}))
+	s.Nil(err)
+
+	exifInfo, err := imagemanager.ParseExif(buf.Bytes(), imagegen.FORMAT_JPEG)
+	s.Nil(err)
+	s.NotNil(exifInfo.Latitude)
+	s.NotNil(exifInfo.Longitude)
+	s.NotNil(exifInfo.Altitude)
+	s.InDelta(expectedGPS.Latitude, *exifInfo.Latitude, 0.0001)
+	s.InDelta(expectedGPS.Longitude, *exifInfo.Longitude, 0.0001)
+	s.InDelta(expectedGPS.Altitude, *exifInfo.Altitude, 0.01)
+}
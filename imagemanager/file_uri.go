@@ -1,14 +1,14 @@
 package imagemanager
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 const (
@@ -42,14 +42,11 @@ func NewFileUri(filesRoot string, uri string) *FileUri {
 		filesRoot: filesRoot,
 	}
 }
-func CreateLocalFileUri(filesRoot string) *FileUri {
+
+// CreateLocalFileUri 按照文件内容的 sha256 生成路径 ab/cd/<sha256>，相同的文件只保存一份
+func CreateLocalFileUri(filesRoot string, hexSum string) *FileUri {
 	var fileType = LOCAL_FILE
-	t := time.Now()
-	id := strings.ReplaceAll(uuid.New().String(), "-", "")
-	dir := filepath.Join(filesRoot, t.Format("200601/02/15"))
-	timestamp := t.Format("20060102150405")
-	fileName := fmt.Sprintf("%s_%s", timestamp, id)
-	filePath := filepath.Join(dir, fileName)
+	filePath := filepath.Join(filesRoot, hexSum[:2], hexSum[2:4], hexSum)
 
 	return &FileUri{
 		uri:       strings.Replace(filePath, filesRoot, fileType, 1),
@@ -70,7 +67,7 @@ func CreateRemoteFileUri(filesRoot string, remoteUri string) (*FileUri, error) {
 	}
 
 	t := time.Now()
-	encodedUri := base64.StdEncoding.EncodeToString([]byte(remoteUri))
+	encodedUri := base64.RawURLEncoding.EncodeToString([]byte(remoteUri))
 	dir := filepath.Join(filesRoot, t.Format("200601/02/15"))
 	timestamp := t.Format("20060102150405")
 	fileName := fmt.Sprintf("%s_%s", timestamp, encodedUri)
@@ -100,6 +97,26 @@ func (fu *FileUri) CreateFilePath() error {
 	return nil
 }
 
+// GetContentSum 获取路径内的 sha256，之前按时间保存的文件返回空字符串
+func (fu *FileUri) GetContentSum() string {
+	if fu.fileType != LOCAL_FILE {
+		return ""
+	}
+	relPath := strings.TrimPrefix(strings.TrimPrefix(fu.uri, fu.fileType), "/")
+	parts := strings.Split(relPath, "/")
+	if len(parts) != 3 {
+		return ""
+	}
+	hexSum := parts[2]
+	if len(hexSum) != sha256.Size*2 || parts[0] != hexSum[:2] || parts[1] != hexSum[2:4] {
+		return ""
+	}
+	if _, err := hex.DecodeString(hexSum); err != nil {
+		return ""
+	}
+	return hexSum
+}
+
 func (fu *FileUri) GetOriginalFilePath() string {
 	if fu.fileType == LOCAL_FILE {
 		return fmt.Sprintf("%s_original", fu.filePath)
@@ -107,7 +124,7 @@ func (fu *FileUri) GetOriginalFilePath() string {
 		baseName := filepath.Base(fu.filePath)
 		idx := strings.Index(baseName, "_") + 1
 		encodedUri := baseName[idx:]
-		uri, err := base64.StdEncoding.DecodeString(encodedUri)
+		uri, err := base64.RawURLEncoding.DecodeString(encodedUri)
 		if err != nil {
 			panic(err)
 		}
@@ -118,3 +135,11 @@ func (fu *FileUri) GetOriginalFilePath() string {
 func (fu *FileUri) GetCompressedFilePath() string {
 	return fmt.Sprintf("%s_compressed", fu.filePath)
 }
+
+func (fu *FileUri) GetRenditionFilePath(rendition Rendition) string {
+	return fmt.Sprintf("%s_%s", fu.filePath, rendition.Suffix)
+}
+
+func (fu *FileUri) GetRenditionCacheKey(rendition Rendition) string {
+	return fmt.Sprintf("%s#%s", fu.uri, rendition.Name)
+}
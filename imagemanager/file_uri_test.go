@@ -88,9 +88,12 @@ func (s *FileUriTestSuite) TestCreateLocalFileUriStructure() {
 	var filesRoot = "/a/b/c"
 	var originalSuffix = "_original"
 	var compressedSuffix = "_compressed"
-	fileUri := CreateLocalFileUri(filesRoot)
+	var hexSum = "ab" + strings.Repeat("cd", 31)
+	fileUri := CreateLocalFileUri(filesRoot, hexSum)
 
 	s.Equal(LOCAL_FILE, fileUri.fileType)
+	s.Equal(LOCAL_FILE+"/ab/cd/"+hexSum, fileUri.String())
+	s.Equal(hexSum, fileUri.GetContentSum())
 	s.Equal(filesRoot, fileUri.filesRoot)
 	s.True(strings.HasPrefix(fileUri.filePath, filesRoot))
 	s.True(strings.HasPrefix(fileUri.uri, fileUri.fileType))
@@ -127,7 +130,7 @@ func (s *FileUriTestSuite) TestCreateRemoteFileUriStructureSuccess() {
 func (s *FileUriTestSuite) TestNewFileUriStructure() {
 	var filesRoot = "/a/b/c"
 
-	localFileUri := CreateLocalFileUri(filesRoot)
+	localFileUri := CreateLocalFileUri(filesRoot, strings.Repeat("ab", 32))
 	remoteFileUri, err := CreateRemoteFileUri(filesRoot, "scp://za@localhost:5678/a/b/c")
 	s.NotNil(remoteFileUri)
 	s.Nil(err)
@@ -152,3 +155,24 @@ func (s *FileUriTestSuite) TestNewFileUriStructure() {
 		s.Equal(scenario.expectedCompressedFilePath, fileUri.GetCompressedFilePath())
 	}
 }
+
+func (s *FileUriTestSuite) TestGetContentSum() {
+	var filesRoot = "/a/b/c"
+	var hexSum = strings.Repeat("0f", 32)
+
+	scenarios := []struct {
+		uri         string
+		expectedSum string
+	}{
+		{"local:///0f/0f/" + hexSum, hexSum},
+		{"local://0f/0f/" + hexSum, hexSum},
+		{"local://0f/0e/" + hexSum, ""},
+		{"local://202401/02/15/20240102150405_abc", ""},
+		{"local://0f/0f/" + strings.Repeat("zz", 32), ""},
+		{"ftp://0f/0f/" + hexSum, ""},
+	}
+
+	for _, scenario := range scenarios {
+		s.Equal(scenario.expectedSum, NewFileUri(filesRoot, scenario.uri).GetContentSum())
+	}
+}
@@ -1,35 +1,81 @@
 package imagemanager
 
 import (
+	"github.com/follow1123/photos/common"
+	"github.com/follow1123/photos/config"
 	"github.com/follow1123/photos/logger"
 )
 
+func WithRenderRoot(renderRoot string) common.Option[ImageManager] {
+	return common.OptionFunc[ImageManager](func(im *ImageManager) {
+		im.renderRoot = renderRoot
+	})
+}
+
+// WithRemoteCredentials 读取 ftp、scp 文件时使用的账号
+func WithRemoteCredentials(credentials []config.RemoteCredential) common.Option[ImageManager] {
+	return common.OptionFunc[ImageManager](func(im *ImageManager) {
+		im.remote = NewRemoteOpener(credentials)
+	})
+}
+
+// WithRenderMemoryBudget 所有渲染请求解码原图时共用的内存预算
+func WithRenderMemoryBudget(budget *MemoryBudget) common.Option[ImageManager] {
+	return common.OptionFunc[ImageManager](func(im *ImageManager) {
+		im.renderBudget = budget
+	})
+}
+
 type ImageManager struct {
-	logger    *logger.AppLogger
-	filesRoot string
-	cache     *ImageCache
+	logger       *logger.AppLogger
+	filesRoot    string
+	renderRoot   string
+	renderBudget *MemoryBudget
+	cache        *ImageCache
+	remote       *RemoteOpener
+	contentLock  *ContentLock
 }
 
 func (im *ImageManager) Deinit() {
 	im.cache.Close()
 }
 
-func NewImageManager(filesRoot string, cache *ImageCache, logger *logger.AppLogger) *ImageManager {
-	return &ImageManager{
-		filesRoot: filesRoot,
-		logger:    logger,
-		cache:     cache,
+func NewImageManager(
+	filesRoot string,
+	cache *ImageCache,
+	logger *logger.AppLogger,
+	opts ...common.Option[ImageManager],
+) *ImageManager {
+	im := &ImageManager{
+		filesRoot:   filesRoot,
+		logger:      logger,
+		cache:       cache,
+		remote:      NewRemoteOpener(nil),
+		contentLock: NewContentLock(),
 	}
+	for _, opt := range opts {
+		opt.Apply(im)
+	}
+	return im
 }
 
-func (im *ImageManager) NewUploadManager(source ImageSource) *UploadImageManager {
-	return NewUploadImageManager(im.filesRoot, source, im.logger, im.cache)
+func (im *ImageManager) NewUploadManager(source ImageSource, opts ...common.Option[UploadImageManager]) *UploadImageManager {
+	return NewUploadImageManager(im.filesRoot, source, im.logger, im.cache, opts...)
 }
 
 func (im *ImageManager) NewDownloadManager(uri string) *DownloadImageManager {
-	return NewDownloadImageManager(im.filesRoot, uri, im.logger, im.cache)
+	return NewDownloadImageManager(im.filesRoot, uri, im.logger, im.cache, im.renderRoot, im.renderBudget, im.remote)
+}
+
+func (im *ImageManager) NewRemoteSource(uri string) ImageSource {
+	return NewRemoteUriSource(uri, im.remote)
+}
+
+// GetContentLock 上传和删除文件共用的锁
+func (im *ImageManager) GetContentLock() *ContentLock {
+	return im.contentLock
 }
 
 func (im *ImageManager) NewDeleteManager(uri string) *DeleteImageManager {
-	return NewDeleteImageManager(im.filesRoot, uri)
+	return NewDeleteImageManager(im.filesRoot, uri, im.renderRoot)
 }
@@ -1,34 +1,89 @@
 package imagemanager
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"image"
-	"image/jpeg"
+	_ "image/gif"
+	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"os"
 	"slices"
 
+	"github.com/follow1123/photos/common"
 	"github.com/follow1123/photos/logger"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
-var SupportedFormats = [...]string{"jpeg", "png"}
+// SupportedFormats gif 只使用第一帧生成缩略图，原图保留动画
+var SupportedFormats = [...]string{"jpeg", "png", "gif", "bmp", "tiff", "webp"}
+
+// 上传的图片先写入该临时目录，需要和图片保存目录在同一个文件系统内
+const TEMP_DIR = ".tmp"
+
+// 图片的最大像素数，文件很小的图片解码后也可能占用大量内存，超过时不解码
+const MAX_IMAGE_PIXELS = 100_000_000
 
 type ImageInfo struct {
 	Size   int64
 	Format string
 	Width  int64
 	Height int64
+	Exif   *ExifInfo
+}
+
+// WithTempDir 临时文件保存的目录，默认使用系统临时目录
+func WithTempDir(tempDir string) common.Option[ImageProcessor] {
+	return common.OptionFunc[ImageProcessor](func(ip *ImageProcessor) {
+		ip.tempDir = tempDir
+	})
 }
 
+// WithMemoryBudget 解码图片时申请内存预算
+func WithMemoryBudget(budget *MemoryBudget) common.Option[ImageProcessor] {
+	return common.OptionFunc[ImageProcessor](func(ip *ImageProcessor) {
+		ip.budget = budget
+	})
+}
+
+// ImageProcessor 图片数据只读取一次，读取时计算摘要并写入临时文件
+// 之后获取图片信息、解码都从临时文件读取，只有解码时才会将像素加载到内存
 type ImageProcessor struct {
 	logger    *logger.AppLogger
-	data      []byte
 	reader    io.ReadCloser
+	tempDir   string
+	budget    *MemoryBudget
+	loaded    bool
+	err       error
+	filePath  string
+	temporary bool
+	hexSum    string
 	imageInfo *ImageInfo
+	image     image.Image
+	resized   []image.Image
+	acquired  int64
+}
+
+func NewImageProcessor(rc io.ReadCloser, logger *logger.AppLogger, opts ...common.Option[ImageProcessor]) *ImageProcessor {
+	ip := &ImageProcessor{reader: rc, logger: logger}
+	for _, opt := range opts {
+		opt.Apply(ip)
+	}
+	return ip
 }
 
-func NewImageProcessor(rc io.ReadCloser, logger *logger.AppLogger) *ImageProcessor {
-	return &ImageProcessor{reader: rc, logger: logger}
+// NewImageFileProcessor 处理已经保存在本地的图片，不会复制文件
+func NewImageFileProcessor(filePath string, logger *logger.AppLogger, opts ...common.Option[ImageProcessor]) *ImageProcessor {
+	ip := &ImageProcessor{filePath: filePath, logger: logger}
+	for _, opt := range opts {
+		opt.Apply(ip)
+	}
+	return ip
 }
 
 func (_ *ImageProcessor) checkFormat(format string) error {
@@ -38,76 +93,280 @@ func (_ *ImageProcessor) checkFormat(format string) error {
 	return nil
 }
 
+func (ip *ImageProcessor) load() error {
+	if ip.loaded {
+		return ip.err
+	}
+	ip.loaded = true
+	if ip.filePath == "" {
+		ip.err = ip.spool()
+	}
+	if ip.err == nil {
+		ip.err = ip.parse()
+	}
+	if ip.err != nil {
+		ip.removeTemp()
+	}
+	return ip.err
+}
+
+// spool 将图片数据写入临时文件，同时计算摘要
+func (ip *ImageProcessor) spool() error {
+	defer ip.reader.Close()
+
+	if ip.tempDir != "" {
+		if err := os.MkdirAll(ip.tempDir, 0755); err != nil {
+			ip.logger.Error("create temp dir error: %v", err)
+			return err
+		}
+	}
+	file, err := os.CreateTemp(ip.tempDir, "upload_*")
+	if err != nil {
+		ip.logger.Error("create temp file error: %v", err)
+		return err
+	}
+	ip.filePath = file.Name()
+	ip.temporary = true
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), ip.reader); err != nil {
+		file.Close()
+		ip.logger.Error("read image error: %v", err)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		ip.logger.Error("close temp file error: %v", err)
+		return err
+	}
+	ip.hexSum = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// parse 从文件内读取图片格式、宽高和 exif 信息
+func (ip *ImageProcessor) parse() error {
+	file, err := os.Open(ip.filePath)
+	if err != nil {
+		ip.logger.Error("open image file error: %v", err)
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	imgConfig, format, err := image.DecodeConfig(bufio.NewReader(file))
+	if err != nil {
+		ip.logger.Error("decode image config error: %v", err)
+		return err
+	}
+	if err := ip.checkFormat(format); err != nil {
+		ip.logger.Error("check image format error: %v", err)
+		return err
+	}
+	if int64(imgConfig.Width)*int64(imgConfig.Height) > MAX_IMAGE_PIXELS {
+		ip.logger.Error("image size %dx%d exceeds max pixels", imgConfig.Width, imgConfig.Height)
+		return ErrImageTooLarge
+	}
+
+	// exif 信息不是必须的，解析失败不影响上传
+	var exifInfo *ExifInfo
+	if _, err = file.Seek(0, io.SeekStart); err == nil {
+		exifInfo, err = ParseExifReader(file, format)
+	}
+	if err != nil {
+		ip.logger.Debug("parse image exif error: %v", err)
+	}
+
+	ip.imageInfo = &ImageInfo{
+		Size:   stat.Size(),
+		Format: format,
+		Width:  int64(imgConfig.Width),
+		Height: int64(imgConfig.Height),
+		Exif:   exifInfo,
+	}
+	// 宽高按照显示的方向保存
+	if IsOrientationSwapped(exifOrientation(exifInfo)) {
+		ip.imageInfo.Width, ip.imageInfo.Height = ip.imageInfo.Height, ip.imageInfo.Width
+	}
+	return nil
+}
+
+// GetData 读取完整的图片数据，大图片应该使用 MoveTo 保存
 func (ip *ImageProcessor) GetData() ([]byte, error) {
-	if ip.data == nil {
-		data, err := io.ReadAll(ip.reader)
+	if err := ip.load(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(ip.filePath)
+}
+
+func (ip *ImageProcessor) GetHexSum() (string, error) {
+	if err := ip.load(); err != nil {
+		return "", err
+	}
+	if ip.hexSum == "" {
+		hexSum, err := HashFile(ip.filePath)
 		if err != nil {
-			ip.logger.Error("read image error: %v", err)
-			return nil, err
+			return "", err
 		}
+		ip.hexSum = hexSum
+	}
+	return ip.hexSum, nil
+}
 
-		if err = ip.reader.Close(); err != nil {
-			ip.logger.Error("close image error: %v", err)
-			return nil, err
+func (ip *ImageProcessor) GetImageInfo() (*ImageInfo, error) {
+	if err := ip.load(); err != nil {
+		return nil, err
+	}
+	return ip.imageInfo, nil
+}
+
+// MoveTo 将临时文件移动到 filePath，之后从新的位置读取
+// 不是临时文件时复制一份
+func (ip *ImageProcessor) MoveTo(filePath string) error {
+	if err := ip.load(); err != nil {
+		return err
+	}
+	if ip.temporary {
+		if err := os.Rename(ip.filePath, filePath); err != nil {
+			ip.logger.Error("move image file error: %v", err)
+			return err
 		}
+		ip.filePath = filePath
+		ip.temporary = false
+		return nil
+	}
+	return copyFile(ip.filePath, filePath)
+}
 
-		// 获取图片其他信息
-		imgConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+// decode 解码图片并按 exif 方向旋转，解码后的图片会被缓存
+// 解码前申请内存预算，Close 时释放
+func (ip *ImageProcessor) decode() (image.Image, error) {
+	if ip.image == nil {
+		imgInfo, err := ip.GetImageInfo()
 		if err != nil {
-			ip.logger.Error("decode image config error: %v", err)
 			return nil, err
 		}
-		if err := ip.checkFormat(format); err != nil {
-			ip.logger.Error("check image format error: %v", err)
+		if ip.acquired == 0 {
+			ip.acquired = ip.budget.Acquire(EstimateDecodeCost(imgInfo.Width, imgInfo.Height))
+		}
+		file, err := os.Open(ip.filePath)
+		if err != nil {
+			ip.logger.Error("open image file error: %v", err)
+			return nil, err
+		}
+		defer file.Close()
+		img, _, err := image.Decode(bufio.NewReader(file))
+		if err != nil {
+			ip.logger.Error("decode image error: %v", err)
 			return nil, err
 		}
+		// 重新编码会丢失 exif，需要按方向旋转图片
+		ip.image = ApplyOrientation(img, exifOrientation(imgInfo.Exif))
+	}
+	return ip.image, nil
+}
+
+// GetRenditionData 生成指定尺寸的缩略图
+func (ip *ImageProcessor) GetRenditionData(rendition Rendition) ([]byte, error) {
+	img, err := ip.decode()
+	if err != nil {
+		return nil, err
+	}
 
-		ip.imageInfo = &ImageInfo{
-			Size:   int64(len(data)),
-			Format: format,
-			Width:  int64(imgConfig.Width),
-			Height: int64(imgConfig.Height),
+	// 优先使用已生成的较大缩略图作为来源，减少缩放的计算量
+	source := img
+	for _, resized := range ip.resized {
+		bounds := resized.Bounds()
+		if max(bounds.Dx(), bounds.Dy()) >= rendition.MaxSize && bounds.Dx()*bounds.Dy() < source.Bounds().Dx()*source.Bounds().Dy() {
+			source = resized
 		}
-		ip.data = data
 	}
-	return ip.data, nil
+	resized := Resize(source, rendition.MaxSize)
+	ip.resized = append(ip.resized, resized)
+
+	var buf bytes.Buffer
+	if _, err := EncodeRendition(&buf, resized); err != nil {
+		ip.logger.Error("encode %s rendition error: %v", rendition.Name, err)
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
-func (ip *ImageProcessor) GetImageInfo() (*ImageInfo, error) {
-	if ip.imageInfo == nil {
-		_, err := ip.GetData()
-		if err != nil {
-			return nil, err
+// GetPerceptualHash 使用 thumb 尺寸的图片计算感知哈希，和已保存的 thumb 文件计算的结果一致
+func (ip *ImageProcessor) GetPerceptualHash() (uint64, error) {
+	img, err := ip.decode()
+	if err != nil {
+		return 0, err
+	}
+	thumb, _ := GetRendition(RENDITION_THUMB)
+	for _, resized := range ip.resized {
+		bounds := resized.Bounds()
+		if max(bounds.Dx(), bounds.Dy()) <= thumb.MaxSize {
+			return DHash(resized), nil
 		}
 	}
-	return ip.imageInfo, nil
+	return DHash(Resize(img, thumb.MaxSize)), nil
 }
 
 func (ip *ImageProcessor) GetCompressedData() ([]byte, error) {
-	imgInfo, err := ip.GetImageInfo()
+	return ip.GetRenditionData(PreviewRendition())
+}
+
+// Close 释放解码的图片和内存预算，删除未保存的临时文件
+func (ip *ImageProcessor) Close() error {
+	if !ip.loaded && ip.reader != nil {
+		ip.loaded = true
+		ip.err = ErrImageProcessorClosed
+		ip.reader.Close()
+	}
+	ip.image = nil
+	ip.resized = nil
+	ip.budget.Release(ip.acquired)
+	ip.acquired = 0
+	return ip.removeTemp()
+}
+
+func (ip *ImageProcessor) removeTemp() error {
+	if !ip.temporary {
+		return nil
+	}
+	ip.temporary = false
+	if err := os.Remove(ip.filePath); err != nil && !os.IsNotExist(err) {
+		ip.logger.Error("remove temp file error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer srcFile.Close()
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
 
-	var buf bytes.Buffer
-	switch imgInfo.Format {
-	case "jpeg":
-		// 压缩并保存
-		img, err := jpeg.Decode(bytes.NewReader(ip.data))
-		if err != nil {
-			ip.logger.Error("decode image error: %v", err)
-			return nil, err
-		}
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 30})
-		if err != nil {
-			ip.logger.Error("compresse image error: %v", err)
-			return nil, err
-		}
-		return buf.Bytes(), nil
-	case "png":
-		ip.logger.Warn("compressed png format image to be implemented")
-		// TODO: compressed png image
-		return ip.data, nil
+// HashFile 计算文件内容的 sha256
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
 	}
-	return nil, ErrUnsupportedImageFormat
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
@@ -2,9 +2,20 @@ package imagemanager_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/follow1123/photos/config"
 	"github.com/follow1123/photos/generator/appgen"
 	"github.com/follow1123/photos/generator/imagegen"
 	"github.com/follow1123/photos/imagemanager"
@@ -69,3 +80,160 @@ func (s *ImageProcessorTestSuite) TestGetCompressedData() {
 	s.Nil(err)
 	s.NotEqual(buf.Bytes(), data)
 }
+
+func (s *ImageProcessorTestSuite) TestOtherFormats() {
+	for _, format := range []string{imagegen.FORMAT_GIF, imagegen.FORMAT_BMP, imagegen.FORMAT_TIFF} {
+		buf := new(bytes.Buffer)
+		expectedImgInfo, err := imagegen.GenImage(buf, imagegen.WithFormat(format))
+		s.Nil(err)
+
+		ip := imagemanager.NewImageProcessor(io.NopCloser(bytes.NewReader(buf.Bytes())), s.logger)
+		imgInfo, err := ip.GetImageInfo()
+		s.Nil(err)
+		s.Equal(format, imgInfo.Format)
+		s.Equal(int64(expectedImgInfo.Width), imgInfo.Width)
+		s.Equal(int64(expectedImgInfo.Height), imgInfo.Height)
+
+		// 缩略图只使用浏览器都支持的格式
+		data, err := ip.GetCompressedData()
+		s.Nil(err)
+		previewFormat, err := imagemanager.DetectFormat(data)
+		s.Nil(err)
+		s.Contains([]string{"jpeg", "png"}, previewFormat)
+	}
+}
+
+func (s *ImageProcessorTestSuite) TestWebpFormat() {
+	// 1x1 的无损 webp 图片
+	data, err := base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+	s.Nil(err)
+
+	ip := imagemanager.NewImageProcessor(io.NopCloser(bytes.NewReader(data)), s.logger)
+	imgInfo, err := ip.GetImageInfo()
+	s.Nil(err)
+	s.Equal("webp", imgInfo.Format)
+	s.Equal(int64(1), imgInfo.Width)
+	s.Equal(int64(1), imgInfo.Height)
+
+	preview, err := ip.GetCompressedData()
+	s.Nil(err)
+	previewFormat, err := imagemanager.DetectFormat(preview)
+	s.Nil(err)
+	s.Contains([]string{"jpeg", "png"}, previewFormat)
+}
+
+func (s *ImageProcessorTestSuite) TestTempFile() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	tempDir := filepath.Join(s.T().TempDir(), imagemanager.TEMP_DIR)
+	budget := imagemanager.NewMemoryBudget(1 << 30)
+
+	ip := imagemanager.NewImageProcessor(
+		io.NopCloser(bytes.NewReader(buf.Bytes())),
+		s.logger,
+		imagemanager.WithTempDir(tempDir),
+		imagemanager.WithMemoryBudget(budget),
+	)
+	sum, err := ip.GetHexSum()
+	s.Nil(err)
+	expectedSum := sha256.Sum256(buf.Bytes())
+	s.Equal(hex.EncodeToString(expectedSum[:]), sum)
+
+	// 读取一次后数据保存在临时文件内
+	entries, err := os.ReadDir(tempDir)
+	s.Nil(err)
+	s.Len(entries, 1)
+
+	// 解码时占用内存预算
+	_, err = ip.GetCompressedData()
+	s.Nil(err)
+	s.Greater(budget.InUse(), int64(0))
+
+	s.Nil(ip.Close())
+	s.Equal(int64(0), budget.InUse())
+	entries, err = os.ReadDir(tempDir)
+	s.Nil(err)
+	s.Empty(entries)
+}
+
+func (s *ImageProcessorTestSuite) TestMoveTo() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	dir := s.T().TempDir()
+	tempDir := filepath.Join(dir, imagemanager.TEMP_DIR)
+
+	ip := imagemanager.NewImageProcessor(
+		io.NopCloser(bytes.NewReader(buf.Bytes())),
+		s.logger,
+		imagemanager.WithTempDir(tempDir),
+	)
+	target := filepath.Join(dir, "original")
+	s.Nil(ip.MoveTo(target))
+	s.Nil(ip.Close())
+
+	data, err := os.ReadFile(target)
+	s.Nil(err)
+	s.Equal(buf.Bytes(), data)
+	entries, err := os.ReadDir(tempDir)
+	s.Nil(err)
+	s.Empty(entries)
+}
+
+func (s *ImageProcessorTestSuite) TestInvalidDataRemoveTempFile() {
+	tempDir := filepath.Join(s.T().TempDir(), imagemanager.TEMP_DIR)
+	ip := imagemanager.NewImageProcessor(
+		io.NopCloser(bytes.NewReader([]byte("24123423"))),
+		s.logger,
+		imagemanager.WithTempDir(tempDir),
+	)
+	_, err := ip.GetImageInfo()
+	s.NotNil(err)
+
+	entries, err := os.ReadDir(tempDir)
+	s.Nil(err)
+	s.Empty(entries)
+}
+
+// genPNGBomb 生成文件很小但是宽高很大的 png，只修改 IHDR 内的宽高
+func (s *ImageProcessorTestSuite) genPNGBomb(width uint32, height uint32) []byte {
+	buf := new(bytes.Buffer)
+	s.Nil(png.Encode(buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+	// 8 字节文件头，IHDR 块的长度和类型占 8 字节，之后是宽高
+	binary.BigEndian.PutUint32(data[16:20], width)
+	binary.BigEndian.PutUint32(data[20:24], height)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func (s *ImageProcessorTestSuite) TestImageTooLarge() {
+	budget := imagemanager.NewMemoryBudget(config.DEFAULT_MEMORY_BUDGET)
+	scenarios := []struct {
+		width    uint32
+		height   uint32
+		tooLarge bool
+	}{
+		// 超过最大像素数
+		{50000, 50000, true},
+		// 解码需要的内存超过总预算时按总预算申请，不拒绝
+		{10000, 8000, false},
+		{10000, 10000, false},
+	}
+
+	for _, scenario := range scenarios {
+		ip := imagemanager.NewImageProcessor(
+			io.NopCloser(bytes.NewReader(s.genPNGBomb(scenario.width, scenario.height))),
+			s.logger,
+			imagemanager.WithTempDir(s.T().TempDir()),
+			imagemanager.WithMemoryBudget(budget),
+		)
+		_, err := ip.GetCompressedData()
+		// 生成的图片只有文件头，解码失败
+		s.NotNil(err)
+		s.Equal(scenario.tooLarge, errors.Is(err, imagemanager.ErrImageTooLarge), scenario)
+		s.Nil(ip.Close())
+		s.Equal(int64(0), budget.InUse())
+	}
+}
@@ -0,0 +1,145 @@
+package imagemanager
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	FIT_COVER   = "cover"
+	FIT_CONTAIN = "contain"
+
+	RENDER_FORMAT_JPEG = "jpeg"
+	RENDER_FORMAT_PNG  = "png"
+
+	RENDER_DEFAULT_QUALITY = 85
+)
+
+type RenderOption struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// Normalize 填充默认值，未指定格式时 jpeg 原图输出 jpeg，其他格式输出 png
+func (ro RenderOption) Normalize(originalFormat string) RenderOption {
+	if ro.Fit == "" {
+		ro.Fit = FIT_CONTAIN
+	}
+	if ro.Format == "" {
+		if originalFormat == RENDER_FORMAT_JPEG {
+			ro.Format = RENDER_FORMAT_JPEG
+		} else {
+			ro.Format = RENDER_FORMAT_PNG
+		}
+	}
+	if ro.Format != RENDER_FORMAT_JPEG {
+		ro.Quality = 0
+	} else if ro.Quality == 0 {
+		ro.Quality = RENDER_DEFAULT_QUALITY
+	}
+	return ro
+}
+
+func (ro RenderOption) CacheName() string {
+	return fmt.Sprintf("%dx%d_%s_q%d.%s", ro.Width, ro.Height, ro.Fit, ro.Quality, ro.Format)
+}
+
+// RenderCacheDir 图片所有渲染结果的缓存目录
+func RenderCacheDir(renderRoot string, uri string) string {
+	sum := sha1.Sum([]byte(uri))
+	hexSum := hex.EncodeToString(sum[:])
+	return filepath.Join(renderRoot, hexSum[:2], hexSum)
+}
+
+// Transform 按照指定的宽高缩放或裁剪图片，不会放大图片
+// contain: 图片完整显示在宽高范围内
+// cover: 图片铺满宽高范围，超出的部分居中裁剪
+func Transform(img image.Image, width int, height int, fit string) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := float64(bounds.Dx()), float64(bounds.Dy())
+	if width <= 0 && height <= 0 {
+		return img
+	}
+
+	scaleX, scaleY := math.Inf(1), math.Inf(1)
+	if width > 0 {
+		scaleX = float64(width) / srcWidth
+	}
+	if height > 0 {
+		scaleY = float64(height) / srcHeight
+	}
+
+	var scale float64
+	if fit == FIT_COVER && width > 0 && height > 0 {
+		scale = math.Max(scaleX, scaleY)
+	} else {
+		scale = math.Min(scaleX, scaleY)
+	}
+	scale = math.Min(scale, 1)
+
+	scaledWidth := max(1, int(math.Round(srcWidth*scale)))
+	scaledHeight := max(1, int(math.Round(srcHeight*scale)))
+
+	// 需要裁剪的区域（按原图坐标计算）
+	cropRect := bounds
+	dstWidth, dstHeight := scaledWidth, scaledHeight
+	if fit == FIT_COVER && width > 0 && height > 0 {
+		dstWidth, dstHeight = min(width, scaledWidth), min(height, scaledHeight)
+		cropWidth := int(math.Round(float64(dstWidth) / scale))
+		cropHeight := int(math.Round(float64(dstHeight) / scale))
+		x0 := bounds.Min.X + (bounds.Dx()-cropWidth)/2
+		y0 := bounds.Min.Y + (bounds.Dy()-cropHeight)/2
+		cropRect = image.Rect(x0, y0, x0+cropWidth, y0+cropHeight)
+	}
+
+	if dstWidth == bounds.Dx() && dstHeight == bounds.Dy() {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, cropRect, draw.Src, nil)
+	return dst
+}
+
+func encodeRender(w io.Writer, img image.Image, opt RenderOption) error {
+	switch opt.Format {
+	case RENDER_FORMAT_JPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: opt.Quality})
+	case RENDER_FORMAT_PNG:
+		return png.Encode(w, img)
+	}
+	return ErrUnsupportedImageFormat
+}
+
+// writeRenderCache 先写入临时文件再重命名，防止读取到写了一半的文件
+func writeRenderCache(filePath string, img image.Image, opt RenderOption) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".render_*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := encodeRender(tmpFile, img, opt); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
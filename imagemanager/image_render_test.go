@@ -0,0 +1,71 @@
+package imagemanager_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/stretchr/testify/suite"
+)
+
+type ImageRenderTestSuite struct {
+	suite.Suite
+}
+
+func TestImageRenderTestSuite(t *testing.T) {
+	suite.Run(t, &ImageRenderTestSuite{})
+}
+
+func (s *ImageRenderTestSuite) TestTransform() {
+	img := image.NewRGBA(image.Rect(0, 0, 1600, 900))
+
+	scenarios := []struct {
+		width          int
+		height         int
+		fit            string
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{0, 0, imagemanager.FIT_CONTAIN, 1600, 900},
+		{800, 0, imagemanager.FIT_CONTAIN, 800, 450},
+		{0, 450, imagemanager.FIT_COVER, 800, 450},
+		{400, 400, imagemanager.FIT_CONTAIN, 400, 225},
+		{400, 400, imagemanager.FIT_COVER, 400, 400},
+		{3200, 3200, imagemanager.FIT_CONTAIN, 1600, 900},
+		{3200, 600, imagemanager.FIT_COVER, 1600, 600},
+	}
+
+	for _, scenario := range scenarios {
+		result := imagemanager.Transform(img, scenario.width, scenario.height, scenario.fit)
+		s.Equal(scenario.expectedWidth, result.Bounds().Dx())
+		s.Equal(scenario.expectedHeight, result.Bounds().Dy())
+	}
+}
+
+func (s *ImageRenderTestSuite) TestNormalize() {
+	scenarios := []struct {
+		option         imagemanager.RenderOption
+		originalFormat string
+		expected       imagemanager.RenderOption
+	}{
+		{
+			imagemanager.RenderOption{Width: 100},
+			"jpeg",
+			imagemanager.RenderOption{Width: 100, Fit: imagemanager.FIT_CONTAIN, Format: "jpeg", Quality: imagemanager.RENDER_DEFAULT_QUALITY},
+		},
+		{
+			imagemanager.RenderOption{Width: 100, Quality: 50},
+			"png",
+			imagemanager.RenderOption{Width: 100, Fit: imagemanager.FIT_CONTAIN, Format: "png"},
+		},
+		{
+			imagemanager.RenderOption{Height: 100, Fit: imagemanager.FIT_COVER, Format: "jpeg", Quality: 50},
+			"png",
+			imagemanager.RenderOption{Height: 100, Fit: imagemanager.FIT_COVER, Format: "jpeg", Quality: 50},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		s.Equal(scenario.expected, scenario.option.Normalize(scenario.originalFormat))
+	}
+}
@@ -3,6 +3,9 @@ package imagemanager
 import (
 	"io"
 	"mime/multipart"
+	"net/url"
+	"os"
+	"path"
 )
 
 type ImageSource interface {
@@ -30,9 +33,30 @@ func (ms *MultipartSource) GetName() string {
 	return ms.FileHeader.Filename
 }
 
-func NewRemoteUriSource(uri string) ImageSource {
-	// TODO
-	return nil
+// RemoteUriSource ftp、scp 上的文件，只保存缩略图，原图每次从远程读取
+type RemoteUriSource struct {
+	uri    string
+	opener *RemoteOpener
+}
+
+func NewRemoteUriSource(uri string, opener *RemoteOpener) ImageSource {
+	return &RemoteUriSource{uri: uri, opener: opener}
+}
+
+func (rus *RemoteUriSource) GetReader() (io.ReadCloser, error) {
+	return rus.opener.Open(rus.uri)
+}
+
+func (rus *RemoteUriSource) GetName() string {
+	u, err := url.Parse(rus.uri)
+	if err != nil {
+		return path.Base(rus.uri)
+	}
+	return path.Base(u.Path)
+}
+
+func (rus *RemoteUriSource) GetUri() string {
+	return rus.uri
 }
 
 type ReaderSource struct {
@@ -51,3 +75,21 @@ func (rs *ReaderSource) GetReader() (io.ReadCloser, error) {
 func (rs *ReaderSource) GetName() string {
 	return rs.name
 }
+
+// FileSource 本地文件，如断点续传完成的文件
+type FileSource struct {
+	path string
+	name string
+}
+
+func NewFileSource(path string, name string) ImageSource {
+	return &FileSource{path: path, name: name}
+}
+
+func (fs *FileSource) GetReader() (io.ReadCloser, error) {
+	return os.Open(fs.path)
+}
+
+func (fs *FileSource) GetName() string {
+	return fs.name
+}
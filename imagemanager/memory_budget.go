@@ -0,0 +1,64 @@
+package imagemanager
+
+import "sync"
+
+// 解码后每个像素占用的字节数，包含旋转和缩放时额外的 RGBA 缓冲区
+const DECODE_BYTES_PER_PIXEL = 8
+
+// MemoryBudget 限制同时解码图片占用的内存，超出预算时等待其他图片处理完成
+// nil 表示不限制
+type MemoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	size  int64
+	inUse int64
+}
+
+func NewMemoryBudget(size int64) *MemoryBudget {
+	if size <= 0 {
+		return nil
+	}
+	mb := &MemoryBudget{size: size}
+	mb.cond = sync.NewCond(&mb.mu)
+	return mb
+}
+
+// EstimateDecodeCost 估算解码图片需要的内存
+func EstimateDecodeCost(width int64, height int64) int64 {
+	return width * height * DECODE_BYTES_PER_PIXEL
+}
+
+// Acquire 申请 n 字节的内存，返回实际申请的大小
+// 超过总预算的图片按总预算计算，保证单张大图也能处理
+func (mb *MemoryBudget) Acquire(n int64) int64 {
+	if mb == nil || n <= 0 {
+		return 0
+	}
+	n = min(n, mb.size)
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for mb.inUse+n > mb.size {
+		mb.cond.Wait()
+	}
+	mb.inUse += n
+	return n
+}
+
+func (mb *MemoryBudget) Release(n int64) {
+	if mb == nil || n <= 0 {
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.inUse -= n
+	mb.cond.Broadcast()
+}
+
+func (mb *MemoryBudget) InUse() int64 {
+	if mb == nil {
+		return 0
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return mb.inUse
+}
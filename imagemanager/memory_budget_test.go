@@ -0,0 +1,58 @@
+package imagemanager_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/stretchr/testify/suite"
+)
+
+type MemoryBudgetTestSuite struct {
+	suite.Suite
+}
+
+func TestMemoryBudgetTestSuite(t *testing.T) {
+	suite.Run(t, &MemoryBudgetTestSuite{})
+}
+
+func (s *MemoryBudgetTestSuite) TestAcquireWait() {
+	budget := imagemanager.NewMemoryBudget(100)
+	s.Equal(int64(60), budget.Acquire(60))
+
+	acquired := make(chan int64)
+	go func() {
+		acquired <- budget.Acquire(60)
+	}()
+
+	select {
+	case <-acquired:
+		s.Fail("acquire should wait for release")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	budget.Release(60)
+	select {
+	case n := <-acquired:
+		s.Equal(int64(60), n)
+	case <-time.After(time.Second):
+		s.Fail("acquire not released")
+	}
+	s.Equal(int64(60), budget.InUse())
+}
+
+func (s *MemoryBudgetTestSuite) TestAcquireLargerThanBudget() {
+	budget := imagemanager.NewMemoryBudget(100)
+	n := budget.Acquire(1000)
+	s.Equal(int64(100), n)
+	budget.Release(n)
+	s.Equal(int64(0), budget.InUse())
+}
+
+func (s *MemoryBudgetTestSuite) TestNilBudget() {
+	budget := imagemanager.NewMemoryBudget(0)
+	s.Nil(budget)
+	s.Equal(int64(0), budget.Acquire(1000))
+	budget.Release(1000)
+	s.Equal(int64(0), budget.InUse())
+}
@@ -0,0 +1,83 @@
+package imagemanager
+
+import (
+	"image"
+	"image/draw"
+)
+
+const (
+	ORIENTATION_NORMAL     int64 = 1
+	ORIENTATION_FLIP_H           = 2
+	ORIENTATION_ROTATE_180       = 3
+	ORIENTATION_FLIP_V           = 4
+	ORIENTATION_TRANSPOSE        = 5
+	ORIENTATION_ROTATE_90        = 6
+	ORIENTATION_TRANSVERSE       = 7
+	ORIENTATION_ROTATE_270       = 8
+)
+
+// IsOrientationSwapped 方向值为 5-8 时，显示的宽高和存储的宽高相反
+func IsOrientationSwapped(orientation int64) bool {
+	return orientation >= ORIENTATION_TRANSPOSE && orientation <= ORIENTATION_ROTATE_270
+}
+
+func needsOrientation(orientation int64) bool {
+	return orientation > ORIENTATION_NORMAL && orientation <= ORIENTATION_ROTATE_270
+}
+
+func exifOrientation(exifInfo *ExifInfo) int64 {
+	if exifInfo == nil {
+		return ORIENTATION_NORMAL
+	}
+	return exifInfo.Orientation
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// ApplyOrientation 根据 exif 方向值旋转/翻转图片，返回按显示方向排列的图片
+func ApplyOrientation(img image.Image, orientation int64) image.Image {
+	if !needsOrientation(orientation) {
+		return img
+	}
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if IsOrientationSwapped(orientation) {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case ORIENTATION_FLIP_H:
+				dx, dy = w-1-x, y
+			case ORIENTATION_ROTATE_180:
+				dx, dy = w-1-x, h-1-y
+			case ORIENTATION_FLIP_V:
+				dx, dy = x, h-1-y
+			case ORIENTATION_TRANSPOSE:
+				dx, dy = y, x
+			case ORIENTATION_ROTATE_90:
+				dx, dy = h-1-y, x
+			case ORIENTATION_TRANSVERSE:
+				dx, dy = h-1-y, w-1-x
+			case ORIENTATION_ROTATE_270:
+				dx, dy = y, w-1-x
+			}
+			si := y*src.Stride + x*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,86 @@
+package imagemanager_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type OrientationTestSuite struct {
+	suite.Suite
+	logger *logger.AppLogger
+}
+
+func TestOrientationTestSuite(t *testing.T) {
+	suite.Run(t, &OrientationTestSuite{})
+}
+
+func (s *OrientationTestSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	appLogger, err := appgen.GenAppLogger(appComponents)
+	s.Nil(err)
+	s.logger = appLogger
+}
+
+func (s *OrientationTestSuite) TestApplyOrientation() {
+	// 3x2 的图片，左上角为红色
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, imagegen.COLOR_RED)
+
+	scenarios := []struct {
+		orientation    int64
+		expectedWidth  int
+		expectedHeight int
+		expectedRedX   int
+		expectedRedY   int
+	}{
+		{imagemanager.ORIENTATION_NORMAL, 3, 2, 0, 0},
+		{imagemanager.ORIENTATION_FLIP_H, 3, 2, 2, 0},
+		{imagemanager.ORIENTATION_ROTATE_180, 3, 2, 2, 1},
+		{imagemanager.ORIENTATION_FLIP_V, 3, 2, 0, 1},
+		{imagemanager.ORIENTATION_TRANSPOSE, 2, 3, 0, 0},
+		{imagemanager.ORIENTATION_ROTATE_90, 2, 3, 1, 0},
+		{imagemanager.ORIENTATION_TRANSVERSE, 2, 3, 1, 2},
+		{imagemanager.ORIENTATION_ROTATE_270, 2, 3, 0, 2},
+	}
+
+	for _, scenario := range scenarios {
+		img := imagemanager.ApplyOrientation(src, scenario.orientation)
+		s.Equal(scenario.expectedWidth, img.Bounds().Dx())
+		s.Equal(scenario.expectedHeight, img.Bounds().Dy())
+		s.Equal(color.RGBAModel.Convert(imagegen.COLOR_RED), color.RGBAModel.Convert(img.At(scenario.expectedRedX, scenario.expectedRedY)))
+	}
+}
+
+func (s *OrientationTestSuite) TestCompressedDataOrientation() {
+	for _, format := range imagegen.FixedFormats {
+		buf := new(bytes.Buffer)
+		imgInfo, err := imagegen.GenImage(
+			buf,
+			imagegen.WithFormat(format),
+			imagegen.WithExif(&imagegen.Exif{Orientation: uint16(imagemanager.ORIENTATION_ROTATE_90)}),
+		)
+		s.Nil(err)
+
+		ip := imagemanager.NewImageProcessor(io.NopCloser(bytes.NewReader(buf.Bytes())), s.logger)
+		info, err := ip.GetImageInfo()
+		s.Nil(err)
+		s.Equal(int64(imgInfo.Height), info.Width)
+		s.Equal(int64(imgInfo.Width), info.Height)
+
+		data, err := ip.GetCompressedData()
+		s.Nil(err)
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		s.Nil(err)
+		// 生成的图片宽大于高，旋转后高大于宽
+		s.Less(config.Width, config.Height)
+	}
+}
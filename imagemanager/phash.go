@@ -0,0 +1,133 @@
+package imagemanager
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// dHash 比较 9x8 灰度图内相邻像素的亮度，得到 64 位的哈希
+const (
+	DHASH_WIDTH  = 9
+	DHASH_HEIGHT = 8
+)
+
+// DHash 计算图片的差异哈希，缩放、重新编码后的图片哈希值相近
+func DHash(img image.Image) uint64 {
+	gray := image.NewGray(image.Rect(0, 0, DHASH_WIDTH, DHASH_HEIGHT))
+	draw.CatmullRom.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := range DHASH_HEIGHT {
+		for x := range DHASH_WIDTH - 1 {
+			hash <<= 1
+			if gray.GrayAt(x, y).Y < gray.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatHash 哈希值以 16 位十六进制保存，sqlite 不支持最高位为 1 的 uint64
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// bkNode BK 树的节点，子节点按照和当前节点的距离保存
+type bkNode struct {
+	hash     uint64
+	indexes  []int
+	children map[int]*bkNode
+}
+
+func (n *bkNode) add(hash uint64, index int) {
+	for {
+		distance := HammingDistance(n.hash, hash)
+		if distance == 0 {
+			n.indexes = append(n.indexes, index)
+			return
+		}
+		child, ok := n.children[distance]
+		if !ok {
+			n.children[distance] = &bkNode{hash: hash, indexes: []int{index}, children: map[int]*bkNode{}}
+			return
+		}
+		n = child
+	}
+}
+
+// search 查找距离在 maxDistance 以内的所有哈希
+func (n *bkNode) search(hash uint64, maxDistance int, found func(index int)) {
+	distance := HammingDistance(n.hash, hash)
+	if distance <= maxDistance {
+		for _, index := range n.indexes {
+			found(index)
+		}
+	}
+	for childDistance, child := range n.children {
+		if childDistance >= distance-maxDistance && childDistance <= distance+maxDistance {
+			child.search(hash, maxDistance, found)
+		}
+	}
+}
+
+// ClusterHashes 将距离在 maxDistance 以内的哈希分为一组，返回每组哈希的下标
+// 分组具有传递性，只返回数量大于 1 的分组
+func ClusterHashes(hashes []uint64, maxDistance int) [][]int {
+	if len(hashes) == 0 {
+		return nil
+	}
+	root := &bkNode{hash: hashes[0], indexes: []int{0}, children: map[int]*bkNode{}}
+	for i := 1; i < len(hashes); i++ {
+		root.add(hashes[i], i)
+	}
+
+	// 并查集合并相似的哈希
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, hash := range hashes {
+		root.search(hash, maxDistance, func(j int) {
+			if ri, rj := find(i), find(j); ri != rj {
+				parent[max(ri, rj)] = min(ri, rj)
+			}
+		})
+	}
+
+	groups := make(map[int][]int)
+	var order []int
+	for i := range hashes {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			order = append(order, r)
+		}
+		groups[r] = append(groups[r], i)
+	}
+	var clusters [][]int
+	for _, r := range order {
+		if len(groups[r]) > 1 {
+			clusters = append(clusters, groups[r])
+		}
+	}
+	return clusters
+}
@@ -0,0 +1,70 @@
+package imagemanager_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/stretchr/testify/suite"
+)
+
+type PHashTestSuite struct {
+	suite.Suite
+}
+
+func TestPHashTestSuite(t *testing.T) {
+	suite.Run(t, &PHashTestSuite{})
+}
+
+func genBlockImage(seed uint64, width int, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	(&imagegen.StyleBlocks{Seed: seed}).Render(img, width, height)
+	return img
+}
+
+func (s *PHashTestSuite) TestDHashSimilar() {
+	original := genBlockImage(1, 1024, 768)
+
+	// 缩小并重新编码后的图片
+	var buf bytes.Buffer
+	s.Nil(jpeg.Encode(&buf, imagemanager.Resize(original, 300), &jpeg.Options{Quality: 60}))
+	reencoded, err := jpeg.Decode(&buf)
+	s.Nil(err)
+
+	hash := imagemanager.DHash(original)
+	s.LessOrEqual(imagemanager.HammingDistance(hash, imagemanager.DHash(reencoded)), 4)
+	s.Greater(imagemanager.HammingDistance(hash, imagemanager.DHash(genBlockImage(2, 1024, 768))), 10)
+}
+
+func (s *PHashTestSuite) TestFormatHash() {
+	for _, hash := range []uint64{0, 1, 0xffffffffffffffff, 0x8000000000000001} {
+		hashStr := imagemanager.FormatHash(hash)
+		s.Len(hashStr, 16)
+		actual, err := imagemanager.ParseHash(hashStr)
+		s.Nil(err)
+		s.Equal(hash, actual)
+	}
+}
+
+func (s *PHashTestSuite) TestClusterHashes() {
+	scenarios := []struct {
+		hashes      []uint64
+		distance    int
+		expectedIdx [][]int
+	}{
+		{nil, 6, nil},
+		{[]uint64{0b0, 0b1, 0xff00}, 1, [][]int{{0, 1}}},
+		// 相似关系可以传递
+		{[]uint64{0b0, 0b11, 0b1111, 0xffff0000}, 2, [][]int{{0, 1, 2}}},
+		{[]uint64{0b0, 0b11, 0b1111, 0xffff0000}, 1, nil},
+		{[]uint64{0xf0, 0xff00, 0xf0, 0xff01}, 0, [][]int{{0, 2}}},
+		{[]uint64{0xf0, 0xff00, 0xf0, 0xff01}, 1, [][]int{{0, 2}, {1, 3}}},
+	}
+
+	for _, scenario := range scenarios {
+		s.Equal(scenario.expectedIdx, imagemanager.ClusterHashes(scenario.hashes, scenario.distance))
+	}
+}
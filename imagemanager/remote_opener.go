@@ -0,0 +1,242 @@
+package imagemanager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/follow1123/photos/config"
+	"github.com/jlaffaye/ftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// 连接远程服务器的超时时间
+	REMOTE_DIAL_TIMEOUT = 30 * time.Second
+
+	FTP_DEFAULT_PORT = "21"
+	SCP_DEFAULT_PORT = "22"
+)
+
+// RemoteOpener 使用配置的账号读取 ftp、scp 上的文件
+type RemoteOpener struct {
+	credentials []config.RemoteCredential
+	timeout     time.Duration
+}
+
+func NewRemoteOpener(credentials []config.RemoteCredential) *RemoteOpener {
+	return &RemoteOpener{credentials: credentials, timeout: REMOTE_DIAL_TIMEOUT}
+}
+
+// credential 先匹配包含端口的配置，再匹配只有主机的配置
+func (ro *RemoteOpener) credential(u *url.URL) config.RemoteCredential {
+	for _, server := range []string{u.Scheme + "://" + u.Host, u.Scheme + "://" + u.Hostname()} {
+		if i := ro.findCredential(server); i >= 0 {
+			return ro.credentials[i]
+		}
+	}
+	return config.RemoteCredential{}
+}
+
+func (ro *RemoteOpener) findCredential(server string) int {
+	for i, credential := range ro.credentials {
+		if strings.TrimSuffix(credential.Server, "/") == server {
+			return i
+		}
+	}
+	return -1
+}
+
+// CheckRemoteUri 检查远程文件的 uri，uri 会保存到数据库和文件名内，不能包含用户名和密码
+func CheckRemoteUri(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if u.User != nil {
+		return ErrRemoteUriCredentials
+	}
+	return nil
+}
+
+// Open 打开远程文件，调用方需要关闭返回的 reader 来断开连接
+func (ro *RemoteOpener) Open(uri string) (io.ReadCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	// 账号只使用配置文件内的账号
+	if u.User != nil {
+		return nil, ErrRemoteUriCredentials
+	}
+	switch u.Scheme + "://" {
+	case FTP_FILE:
+		return ro.openFTP(u)
+	case SCP_FILE:
+		return ro.openSCP(u)
+	default:
+		return nil, ErrUnsupportedRemoteFiles
+	}
+}
+
+func remoteAddr(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// ftpReader 读取完成后关闭数据连接和控制连接
+type ftpReader struct {
+	*ftp.Response
+	conn *ftp.ServerConn
+}
+
+func (fr *ftpReader) Close() error {
+	return errors.Join(fr.Response.Close(), fr.conn.Quit())
+}
+
+func (ro *RemoteOpener) openFTP(u *url.URL) (io.ReadCloser, error) {
+	conn, err := ftp.Dial(remoteAddr(u, FTP_DEFAULT_PORT), ftp.DialWithTimeout(ro.timeout))
+	if err != nil {
+		return nil, err
+	}
+	credential := ro.credential(u)
+	if credential.Username == "" {
+		// 没有配置账号时匿名登录
+		credential.Username, credential.Password = "anonymous", "anonymous"
+	}
+	if err := conn.Login(credential.Username, credential.Password); err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("ftp login %s error: %w", u.Host, err)
+	}
+	resp, err := conn.Retr(u.Path)
+	if err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("ftp retrieve %s error: %w", u.Path, err)
+	}
+	return &ftpReader{Response: resp, conn: conn}, nil
+}
+
+// scpReader 读取 scp 传输的文件内容，关闭时断开 ssh 连接
+type scpReader struct {
+	io.Reader
+	session *ssh.Session
+	client  *ssh.Client
+}
+
+func (sr *scpReader) Close() error {
+	sr.session.Close()
+	return sr.client.Close()
+}
+
+func (ro *RemoteOpener) sshConfig(credential config.RemoteCredential) (*ssh.ClientConfig, error) {
+	if credential.Username == "" {
+		return nil, errors.New("scp username not configured")
+	}
+	var auth []ssh.AuthMethod
+	if credential.PrivateKeyFile != "" {
+		key, err := os.ReadFile(credential.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if credential.Password != "" {
+		auth = append(auth, ssh.Password(credential.Password))
+	}
+
+	knownHostsFile := credential.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            credential.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         ro.timeout,
+	}, nil
+}
+
+// openSCP 在远程执行 scp -f 发送文件
+// 发送 \0 后服务器返回 C<权限> <大小> <文件名>\n，再次发送 \0 后开始传输文件内容
+func (ro *RemoteOpener) openSCP(u *url.URL) (rc io.ReadCloser, err error) {
+	sshConfig, err := ro.sshConfig(ro.credential(u))
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", remoteAddr(u, SCP_DEFAULT_PORT), sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			client.Close()
+		}
+	}()
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Start("scp -f " + shellQuote(u.Path)); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(stdout)
+	if _, err := stdin.Write([]byte{0}); err != nil {
+		return nil, err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("scp read file header error: %w", err)
+	}
+	if line[0] != 'C' {
+		// 1、2 开头的是错误信息
+		return nil, fmt.Errorf("scp retrieve %s error: %s", u.Path, strings.TrimSpace(line[1:]))
+	}
+	fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("scp invalid file header: %q", line)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("scp invalid file size: %q", line)
+	}
+	if _, err := stdin.Write([]byte{0}); err != nil {
+		return nil, err
+	}
+	return &scpReader{Reader: io.LimitReader(reader, size), session: session, client: client}, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
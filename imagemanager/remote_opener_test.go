@@ -0,0 +1,385 @@
+package imagemanager_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/logger"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	REMOTE_USER     = "photos"
+	REMOTE_PASSWORD = "secret"
+)
+
+type RemoteOpenerTestSuite struct {
+	suite.Suite
+	conf   *config.Config
+	logger *logger.AppLogger
+	cache  *imagemanager.ImageCache
+
+	files       map[string][]byte
+	ftpAddr     string
+	scpAddr     string
+	credentials []config.RemoteCredential
+	listeners   []net.Listener
+}
+
+func TestRemoteOpenerTestSuite(t *testing.T) {
+	suite.Run(t, &RemoteOpenerTestSuite{})
+}
+
+func (s *RemoteOpenerTestSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	conf, err := appgen.GenConfig(appComponents)
+	s.Nil(err)
+	conf.CreatePath()
+	appLogger, err := appgen.GenAppLogger(appComponents)
+	s.Nil(err)
+	imageCache, err := appgen.GenImageCache(appComponents)
+	s.Nil(err)
+	s.conf = conf
+	s.logger = appLogger
+	s.cache = imageCache
+
+	buf := new(bytes.Buffer)
+	_, err = imagegen.GenImage(buf)
+	s.Nil(err)
+	s.files = map[string][]byte{
+		"/photos/a.png":     buf.Bytes(),
+		"/photos/a b's.txt": []byte("hello world"),
+	}
+
+	s.ftpAddr = s.startFTPServer()
+	privateKeyFile, knownHostsFile := s.startSSHServer()
+	s.credentials = []config.RemoteCredential{
+		{Server: "ftp://127.0.0.1", Username: REMOTE_USER, Password: REMOTE_PASSWORD},
+		{Server: "scp://" + s.scpAddr, Username: REMOTE_USER, PrivateKeyFile: privateKeyFile, KnownHostsFile: knownHostsFile},
+	}
+}
+
+func (s *RemoteOpenerTestSuite) TearDownSuite() {
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	s.cache.Close()
+	s.conf.DeletePath()
+}
+
+func (s *RemoteOpenerTestSuite) listen() net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Nil(err)
+	s.listeners = append(s.listeners, listener)
+	return listener
+}
+
+// startFTPServer 启动只支持登录和下载文件的 ftp 服务器
+func (s *RemoteOpenerTestSuite) startFTPServer() string {
+	listener := s.listen()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveFTP(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (s *RemoteOpenerTestSuite) serveFTP(conn net.Conn) {
+	defer conn.Close()
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var user string
+	var dataListener net.Listener
+	reader := bufio.NewReader(conn)
+	reply("220 ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch cmd {
+		case "USER":
+			user = arg
+			reply("331 password required")
+		case "PASS":
+			if user == REMOTE_USER && arg == REMOTE_PASSWORD {
+				reply("230 logged in")
+			} else {
+				reply("530 login incorrect")
+			}
+		case "TYPE":
+			reply("200 ok")
+		case "EPSV":
+			dataListener, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 can't open data connection")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", dataListener.Addr().(*net.TCPAddr).Port)
+		case "RETR":
+			dataConn, err := dataListener.Accept()
+			dataListener.Close()
+			if err != nil {
+				reply("425 can't open data connection")
+				continue
+			}
+			data, ok := s.files[arg]
+			if !ok {
+				dataConn.Close()
+				reply("550 file not found")
+				continue
+			}
+			reply("150 opening data connection")
+			dataConn.Write(data)
+			dataConn.Close()
+			reply("226 transfer complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// startSSHServer 启动只支持 scp -f 的 ssh 服务器，返回客户端的私钥和 known_hosts 文件
+func (s *RemoteOpenerTestSuite) startSSHServer() (string, string) {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().Nil(err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	s.Require().Nil(err)
+	clientPub, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().Nil(err)
+	authorizedKey, err := ssh.NewPublicKey(clientPub)
+	s.Require().Nil(err)
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == REMOTE_USER && bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key for %s", meta.User())
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener := s.listen()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveSSH(conn, serverConfig)
+		}
+	}()
+	s.scpAddr = listener.Addr().String()
+
+	dir := s.T().TempDir()
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	s.Require().Nil(err)
+	privateKeyFile := filepath.Join(dir, "id_ed25519")
+	s.Require().Nil(os.WriteFile(privateKeyFile, pem.EncodeToMemory(block), 0600))
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.scpAddr)}, hostSigner.PublicKey())
+	s.Require().Nil(os.WriteFile(knownHostsFile, []byte(line+"\n"), 0600))
+	return privateKeyFile, knownHostsFile
+}
+
+func (s *RemoteOpenerTestSuite) serveSSH(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+				status := s.serveSCP(channel, payload.Command)
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+// serveSCP 按 scp 协议发送单个文件
+func (s *RemoteOpenerTestSuite) serveSCP(channel ssh.Channel, command string) uint32 {
+	ack := make([]byte, 1)
+	if _, err := io.ReadFull(channel, ack); err != nil {
+		return 1
+	}
+	quoted, ok := strings.CutPrefix(command, "scp -f ")
+	if !ok {
+		fmt.Fprintf(channel, "\x01scp: unsupported command\n")
+		return 1
+	}
+	name := strings.ReplaceAll(strings.Trim(quoted, "'"), `'\''`, "'")
+	data, ok := s.files[name]
+	if !ok {
+		fmt.Fprintf(channel, "\x01scp: %s: No such file or directory\n", name)
+		return 1
+	}
+	fmt.Fprintf(channel, "C0644 %d %s\n", len(data), filepath.Base(name))
+	if _, err := io.ReadFull(channel, ack); err != nil {
+		return 1
+	}
+	channel.Write(data)
+	channel.Write([]byte{0})
+	return 0
+}
+
+func (s *RemoteOpenerTestSuite) readAll(opener *imagemanager.RemoteOpener, uri string) ([]byte, error) {
+	reader, err := opener.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { s.Nil(reader.Close()) }()
+	return io.ReadAll(reader)
+}
+
+func (s *RemoteOpenerTestSuite) TestOpenFTP() {
+	ftpPort := strings.Split(s.ftpAddr, ":")[1]
+	scenarios := []struct {
+		credentials []config.RemoteCredential
+		uri         string
+		expected    []byte
+	}{
+		{s.credentials, "ftp://" + s.ftpAddr + "/photos/a b's.txt", s.files["/photos/a b's.txt"]},
+		// 包含端口的配置优先
+		{
+			[]config.RemoteCredential{
+				{Server: "ftp://127.0.0.1", Username: REMOTE_USER, Password: "wrong"},
+				{Server: "ftp://127.0.0.1:" + ftpPort, Username: REMOTE_USER, Password: REMOTE_PASSWORD},
+			},
+			"ftp://" + s.ftpAddr + "/photos/a.png",
+			s.files["/photos/a.png"],
+		},
+		{nil, "ftp://" + s.ftpAddr + "/photos/a.png", nil},
+		// uri 内不能包含账号
+		{nil, "ftp://" + REMOTE_USER + ":" + REMOTE_PASSWORD + "@" + s.ftpAddr + "/photos/a.png", nil},
+		{s.credentials, "ftp://" + REMOTE_USER + "@" + s.ftpAddr + "/photos/a.png", nil},
+		{s.credentials, "ftp://" + s.ftpAddr + "/photos/none.png", nil},
+	}
+
+	for _, scenario := range scenarios {
+		data, err := s.readAll(imagemanager.NewRemoteOpener(scenario.credentials), scenario.uri)
+		if scenario.expected == nil {
+			s.NotNil(err, scenario.uri)
+			continue
+		}
+		s.Nil(err, scenario.uri)
+		s.Equal(scenario.expected, data)
+	}
+}
+
+func (s *RemoteOpenerTestSuite) TestOpenSCP() {
+	otherKnownHosts := filepath.Join(s.T().TempDir(), "known_hosts")
+	s.Nil(os.WriteFile(otherKnownHosts, nil, 0600))
+	untrusted := s.credentials[1]
+	untrusted.KnownHostsFile = otherKnownHosts
+
+	scenarios := []struct {
+		credentials []config.RemoteCredential
+		uri         string
+		expected    []byte
+	}{
+		{s.credentials, "scp://" + s.scpAddr + "/photos/a.png", s.files["/photos/a.png"]},
+		{s.credentials, "scp://" + s.scpAddr + "/photos/a%20b's.txt", s.files["/photos/a b's.txt"]},
+		{s.credentials, "scp://" + s.scpAddr + "/photos/none.png", nil},
+		// 没有配置账号
+		{nil, "scp://" + s.scpAddr + "/photos/a.png", nil},
+		// 主机不在 known_hosts 内
+		{[]config.RemoteCredential{untrusted}, "scp://" + s.scpAddr + "/photos/a.png", nil},
+	}
+
+	for _, scenario := range scenarios {
+		data, err := s.readAll(imagemanager.NewRemoteOpener(scenario.credentials), scenario.uri)
+		if scenario.expected == nil {
+			s.NotNil(err, scenario.uri)
+			continue
+		}
+		s.Nil(err, scenario.uri)
+		s.Equal(scenario.expected, data)
+	}
+}
+
+func (s *RemoteOpenerTestSuite) TestOpenUnsupported() {
+	_, err := imagemanager.NewRemoteOpener(s.credentials).Open("http://127.0.0.1/a.png")
+	s.Equal(imagemanager.ErrUnsupportedRemoteFiles, err)
+}
+
+func (s *RemoteOpenerTestSuite) TestSaveRemoteSource() {
+	imageManager := imagemanager.NewImageManager(
+		s.conf.GetFilesPath(),
+		s.cache,
+		s.logger,
+		imagemanager.WithRemoteCredentials(s.credentials),
+	)
+
+	for _, remoteUri := range []string{"ftp://" + s.ftpAddr + "/photos/a.png", "scp://" + s.scpAddr + "/photos/a.png"} {
+		source := imageManager.NewRemoteSource(remoteUri)
+		s.Equal("a.png", source.GetName())
+
+		uploadMgr := imageManager.NewUploadManager(source)
+		uri, err := uploadMgr.Save()
+		s.Nil(err)
+		s.Nil(uploadMgr.Close())
+		s.True(strings.HasPrefix(uri, remoteUri[:6]), uri)
+
+		// 只保存缩略图，原图从远程读取
+		fileUri := imagemanager.NewFileUri(s.conf.GetFilesPath(), uri)
+		s.Equal(remoteUri, fileUri.GetOriginalFilePath())
+		_, err = os.Stat(fileUri.GetRenditionFilePath(imagemanager.PreviewRendition()))
+		s.Nil(err)
+
+		downloadMgr := imageManager.NewDownloadManager(uri)
+		original, err := downloadMgr.OpenOriginal()
+		s.Nil(err)
+		data, err := io.ReadAll(original)
+		s.Nil(err)
+		s.Nil(original.Close())
+		s.Equal(s.files["/photos/a.png"], data)
+
+		s.Nil(imageManager.NewDeleteManager(uri).Delete())
+	}
+}
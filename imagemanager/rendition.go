@@ -0,0 +1,95 @@
+package imagemanager
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	RENDITION_THUMB   = "thumb"
+	RENDITION_PREVIEW = "preview"
+	RENDITION_SCREEN  = "screen"
+
+	RENDITION_QUALITY = 85
+)
+
+type Rendition struct {
+	// 接口内 size 参数的值
+	Name string
+	// 保存时的文件后缀
+	Suffix string
+	// 长边的最大像素
+	MaxSize int
+}
+
+// Renditions 上传时生成的缩略图，按尺寸从大到小排列
+// preview 使用 compressed 后缀，兼容之前保存的压缩文件
+var Renditions = [...]Rendition{
+	{Name: RENDITION_SCREEN, Suffix: "screen", MaxSize: 2048},
+	{Name: RENDITION_PREVIEW, Suffix: "compressed", MaxSize: 1024},
+	{Name: RENDITION_THUMB, Suffix: "thumb", MaxSize: 256},
+}
+
+func GetRendition(name string) (Rendition, error) {
+	for _, rendition := range Renditions {
+		if rendition.Name == name {
+			return rendition, nil
+		}
+	}
+	return Rendition{}, ErrUnsupportedRendition
+}
+
+func PreviewRendition() Rendition {
+	rendition, _ := GetRendition(RENDITION_PREVIEW)
+	return rendition
+}
+
+// Resize 按长边等比缩小图片，图片小于 maxSize 时不做处理
+func Resize(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// isOpaque 判断图片是否不透明，透明的图片使用 png 保存
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
+
+// EncodeRendition 不透明的图片编码为 jpeg，否则编码为 png，返回编码后的格式
+func EncodeRendition(w io.Writer, img image.Image) (string, error) {
+	if isOpaque(img) {
+		return "jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: RENDITION_QUALITY})
+	}
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	return "png", encoder.Encode(w, img)
+}
+
+// DetectFormat 获取图片数据的格式
+func DetectFormat(data []byte) (string, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return format, nil
+}
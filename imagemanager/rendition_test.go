@@ -0,0 +1,76 @@
+package imagemanager_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenditionTestSuite struct {
+	suite.Suite
+}
+
+func TestRenditionTestSuite(t *testing.T) {
+	suite.Run(t, &RenditionTestSuite{})
+}
+
+func (s *RenditionTestSuite) TestGetRendition() {
+	for _, name := range []string{imagemanager.RENDITION_THUMB, imagemanager.RENDITION_PREVIEW, imagemanager.RENDITION_SCREEN} {
+		rendition, err := imagemanager.GetRendition(name)
+		s.Nil(err)
+		s.Equal(name, rendition.Name)
+	}
+	_, err := imagemanager.GetRendition("original")
+	s.Equal(imagemanager.ErrUnsupportedRendition, err)
+}
+
+func (s *RenditionTestSuite) TestResize() {
+	scenarios := []struct {
+		width          int
+		height         int
+		maxSize        int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{1920, 1080, 256, 256, 144},
+		{1080, 1920, 256, 144, 256},
+		{1000, 10, 256, 256, 2},
+		{200, 100, 256, 200, 100},
+	}
+
+	for _, scenario := range scenarios {
+		img := image.NewRGBA(image.Rect(0, 0, scenario.width, scenario.height))
+		resized := imagemanager.Resize(img, scenario.maxSize)
+		s.Equal(scenario.expectedWidth, resized.Bounds().Dx())
+		s.Equal(scenario.expectedHeight, resized.Bounds().Dy())
+	}
+}
+
+func (s *RenditionTestSuite) TestEncodeRendition() {
+	opaque := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+	transparent := image.NewRGBA(image.Rect(0, 0, 10, 10))
+
+	scenarios := []struct {
+		img            image.Image
+		expectedFormat string
+	}{
+		{opaque, "jpeg"},
+		{transparent, "png"},
+	}
+
+	for _, scenario := range scenarios {
+		buf := new(bytes.Buffer)
+		format, err := imagemanager.EncodeRendition(buf, scenario.img)
+		s.Nil(err)
+		s.Equal(scenario.expectedFormat, format)
+		detected, err := imagemanager.DetectFormat(buf.Bytes())
+		s.Nil(err)
+		s.Equal(scenario.expectedFormat, detected)
+	}
+}
@@ -1,19 +1,32 @@
 package imagemanager
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"os"
+	"path/filepath"
 
+	"github.com/follow1123/photos/common"
 	"github.com/follow1123/photos/logger"
 )
 
+// WithUploadMemoryBudget 多个图片同时上传时共用的内存预算
+func WithUploadMemoryBudget(budget *MemoryBudget) common.Option[UploadImageManager] {
+	return common.OptionFunc[UploadImageManager](func(uim *UploadImageManager) {
+		uim.budget = budget
+	})
+}
+
 type UploadImageManager struct {
 	logger    *logger.AppLogger
 	filesRoot string
 	source    ImageSource
 	processor *ImageProcessor
 	cache     *ImageCache
+	budget    *MemoryBudget
+
+	// Save 时新写入的文件和缓存，保存图片数据失败时删除
+	createdFiles []string
+	cacheKeys    []string
 }
 
 func NewUploadImageManager(
@@ -21,35 +34,71 @@ func NewUploadImageManager(
 	imageSource ImageSource,
 	logger *logger.AppLogger,
 	cache *ImageCache,
+	opts ...common.Option[UploadImageManager],
 ) *UploadImageManager {
-	return &UploadImageManager{
+	uim := &UploadImageManager{
 		filesRoot: filesRoot,
 		source:    imageSource,
 		cache:     cache,
 		logger:    logger,
 	}
+	for _, opt := range opts {
+		opt.Apply(uim)
+	}
+	return uim
 }
+
 func (uim *UploadImageManager) initImageProcessor() error {
 	if uim.processor == nil {
 		rc, err := uim.source.GetReader()
 		if err != nil {
 			return err
 		}
-		uim.processor = NewImageProcessor(rc, uim.logger)
+		uim.processor = NewImageProcessor(
+			rc,
+			uim.logger,
+			WithTempDir(filepath.Join(uim.filesRoot, TEMP_DIR)),
+			WithMemoryBudget(uim.budget),
+		)
 	}
 	return nil
 }
 
-func (uim *UploadImageManager) Save() (string, error) {
-	err := uim.initImageProcessor()
+// Close 释放图片处理占用的资源，未保存的临时文件会被删除
+func (uim *UploadImageManager) Close() error {
+	if uim.processor == nil {
+		return nil
+	}
+	return uim.processor.Close()
+}
+
+// Save 保存原图和缩略图，返回文件的 uri，保存失败时删除已经写入的文件
+func (uim *UploadImageManager) Save() (uri string, err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+		if discardErr := uim.Discard(); discardErr != nil {
+			uim.logger.Error("discard saved files error: %v", discardErr)
+		}
+	}()
+
+	if err := uim.initImageProcessor(); err != nil {
+		return "", err
+	}
+	hexSum, err := uim.processor.GetHexSum()
 	if err != nil {
 		return "", err
 	}
-	// TODO check other file type
-	fileUri := CreateLocalFileUri(uim.filesRoot)
+	fileUri := CreateLocalFileUri(uim.filesRoot, hexSum)
+	// 远程文件不保存原图，只记录原图的 uri
+	if remoteSource, ok := uim.source.(*RemoteUriSource); ok {
+		if fileUri, err = CreateRemoteFileUri(uim.filesRoot, remoteSource.GetUri()); err != nil {
+			return "", err
+		}
+	}
 
 	originalFileName := fileUri.GetOriginalFilePath()
-	compressedFileName := fileUri.GetCompressedFilePath()
 
 	err = fileUri.CreateFilePath()
 	if err != nil {
@@ -57,23 +106,65 @@ func (uim *UploadImageManager) Save() (string, error) {
 	}
 
 	if fileUri.Is(LOCAL_FILE) {
-		data, err := uim.processor.GetData()
+		// 相同内容的文件已经保存过时不再重复保存
+		exists, err := fileExists(originalFileName)
 		if err != nil {
 			return "", err
 		}
-		os.WriteFile(originalFileName, data, 0666)
+		if !exists {
+			// 复制文件失败时可能有部分内容，先记录
+			uim.createdFiles = append(uim.createdFiles, originalFileName)
+			if err := uim.processor.MoveTo(originalFileName); err != nil {
+				return "", err
+			}
+		}
 	}
 
-	data, err := uim.processor.GetCompressedData()
-	uim.cache.Set(fileUri.String(), data, 1)
-	if err != nil {
-		return "", err
+	// 生成各个尺寸的缩略图
+	for _, rendition := range Renditions {
+		renditionFileName := fileUri.GetRenditionFilePath(rendition)
+		exists, err := fileExists(renditionFileName)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			continue
+		}
+		data, err := uim.processor.GetRenditionData(rendition)
+		if err != nil {
+			return "", err
+		}
+		uim.createdFiles = append(uim.createdFiles, renditionFileName)
+		if err := os.WriteFile(renditionFileName, data, 0666); err != nil {
+			uim.logger.Error("write %s rendition error: %v", rendition.Name, err)
+			return "", err
+		}
+		if rendition.Name == RENDITION_PREVIEW {
+			cacheKey := fileUri.GetRenditionCacheKey(rendition)
+			uim.cache.Set(cacheKey, data, 1)
+			uim.cacheKeys = append(uim.cacheKeys, cacheKey)
+		}
 	}
-	os.WriteFile(compressedFileName, data, 0666)
 
 	return fileUri.String(), nil
 }
 
+// Discard 删除 Save 新写入的文件和缓存，之前已经存在的相同内容的文件不删除
+func (uim *UploadImageManager) Discard() error {
+	var errs []error
+	for _, fileName := range uim.createdFiles {
+		if err := os.Remove(fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	for _, cacheKey := range uim.cacheKeys {
+		uim.cache.Del(cacheKey)
+	}
+	uim.createdFiles = nil
+	uim.cacheKeys = nil
+	return errors.Join(errs...)
+}
+
 func (uim *UploadImageManager) GetImageName() string {
 	return uim.source.GetName()
 }
@@ -84,13 +175,16 @@ func (uim *UploadImageManager) GetHexSum() (string, error) {
 		return "", err
 	}
 
-	data, err := uim.processor.GetData()
+	return uim.processor.GetHexSum()
+}
+
+func (uim *UploadImageManager) GetPerceptualHash() (uint64, error) {
+	err := uim.initImageProcessor()
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
-	sum := md5.Sum(data)
-	return hex.EncodeToString(sum[:]), nil
+	return uim.processor.GetPerceptualHash()
 }
 
 func (uim *UploadImageManager) GetImageInfo() (*ImageInfo, error) {
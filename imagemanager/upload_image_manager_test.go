@@ -2,7 +2,7 @@ package imagemanager_test
 
 import (
 	"bytes"
-	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"image"
 	"os"
@@ -78,7 +78,7 @@ func (s *UploadImageManagerTestSuite) TestGetHexSumSuccess() {
 		s.Nil(err)
 
 		source := imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), name)
-		sum := md5.Sum(buf.Bytes())
+		sum := sha256.Sum256(buf.Bytes())
 		return Scenario{
 			source:         source,
 			expectedHexSum: hex.EncodeToString(sum[:]),
@@ -229,3 +229,99 @@ func (s *UploadImageManagerTestSuite) TestSaveSuccess() {
 		})
 	}
 }
+
+func (s *UploadImageManagerTestSuite) TestSaveRenditions() {
+	filesRoot := s.conf.GetFilesPath()
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+
+	uploadMgr := imagemanager.NewUploadImageManager(
+		filesRoot,
+		imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "aaa"),
+		s.logger,
+		s.cache,
+	)
+	uri, err := uploadMgr.Save()
+	s.Nil(err)
+
+	fileUri := imagemanager.NewFileUri(filesRoot, uri)
+	for _, rendition := range imagemanager.Renditions {
+		data, err := os.ReadFile(fileUri.GetRenditionFilePath(rendition))
+		s.Nil(err)
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		s.Nil(err)
+		s.LessOrEqual(max(config.Width, config.Height), rendition.MaxSize)
+	}
+}
+
+func (s *UploadImageManagerTestSuite) TestSaveSameContent() {
+	filesRoot := s.conf.GetFilesPath()
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	sum := sha256.Sum256(buf.Bytes())
+	hexSum := hex.EncodeToString(sum[:])
+
+	var uris []string
+	for range 2 {
+		uploadMgr := imagemanager.NewUploadImageManager(
+			filesRoot,
+			imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "aaa"),
+			s.logger,
+			s.cache,
+		)
+		uri, err := uploadMgr.Save()
+		s.Nil(err)
+		s.Nil(uploadMgr.Close())
+		uris = append(uris, uri)
+	}
+
+	// 相同内容的文件保存在同一个路径
+	s.Equal(uris[0], uris[1])
+	fileUri := imagemanager.NewFileUri(filesRoot, uris[0])
+	s.Equal(hexSum, fileUri.GetContentSum())
+	actualSum, err := imagemanager.HashFile(fileUri.GetOriginalFilePath())
+	s.Nil(err)
+	s.Equal(hexSum, actualSum)
+}
+
+func (s *UploadImageManagerTestSuite) TestDiscard() {
+	filesRoot := s.conf.GetFilesPath()
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+
+	uploadMgrs := make([]*imagemanager.UploadImageManager, 0, 2)
+	var uri string
+	for range 2 {
+		uploadMgr := imagemanager.NewUploadImageManager(
+			filesRoot,
+			imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "aaa"),
+			s.logger,
+			s.cache,
+		)
+		uri, err = uploadMgr.Save()
+		s.Nil(err)
+		s.Nil(uploadMgr.Close())
+		uploadMgrs = append(uploadMgrs, uploadMgr)
+	}
+	fileUri := imagemanager.NewFileUri(filesRoot, uri)
+	files := []string{fileUri.GetOriginalFilePath()}
+	for _, rendition := range imagemanager.Renditions {
+		files = append(files, fileUri.GetRenditionFilePath(rendition))
+	}
+
+	// 第二次保存时文件已经存在，不删除
+	s.Nil(uploadMgrs[1].Discard())
+	for _, file := range files {
+		_, err := os.Stat(file)
+		s.Nil(err, file)
+	}
+
+	s.Nil(uploadMgrs[0].Discard())
+	for _, file := range files {
+		_, err := os.Stat(file)
+		s.True(os.IsNotExist(err), file)
+	}
+}
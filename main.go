@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/follow1123/photos/application"
 	"github.com/follow1123/photos/config"
@@ -14,6 +16,9 @@ import (
 )
 
 func main() {
+	rebuildFts := flag.Bool("rebuild-fts", false, "rebuild the full text search index and exit")
+	flag.Parse()
+
 	// logger
 	baseLogger, err := logger.NewBaseLogger()
 	if err != nil {
@@ -30,6 +35,10 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("cannot create config path: %s, error: %v", conf.GetPrefixPath(), err))
 	}
+	err = conf.LoadFile()
+	if err != nil {
+		panic(fmt.Sprintf("load config file: %s, error: %v", conf.GetConfigFilePath(), err))
+	}
 
 	// database
 	db, err := database.NewDatabase(conf, gormLogger)
@@ -39,7 +48,19 @@ func main() {
 
 	// migration
 	dbMigrator := database.NewDBMigrator(db)
-	dbMigrator.InitOrMigrate()
+	err = dbMigrator.InitOrMigrate()
+	if err != nil {
+		panic(fmt.Sprintf("migrate database error: %v", err))
+	}
+
+	if *rebuildFts {
+		count, err := db.RebuildFullTextSearch()
+		if err != nil {
+			panic(fmt.Sprintf("rebuild full text search index error: %v", err))
+		}
+		appLogger.Info("rebuild full text search index of %d photos", count)
+		return
+	}
 
 	// image manager
 	imageCache, err := imagemanager.NewImageCache()
@@ -47,7 +68,14 @@ func main() {
 		panic(fmt.Sprintf("init image cache error: %v", err))
 	}
 
-	imageManager := imagemanager.NewImageManager(conf.GetFilesPath(), imageCache, appLogger)
+	imageManager := imagemanager.NewImageManager(
+		conf.GetFilesPath(),
+		imageCache,
+		appLogger,
+		imagemanager.WithRenderRoot(conf.GetRenderCachePath()),
+		imagemanager.WithRenderMemoryBudget(imagemanager.NewMemoryBudget(conf.GetMemoryBudget())),
+		imagemanager.WithRemoteCredentials(conf.GetRemoteCredentials()),
+	)
 
 	// application context
 	appCtx := application.NewAppContext(conf, imageManager, appLogger)
@@ -62,8 +90,63 @@ func main() {
 	// router
 	photoServ := service.NewPhotoService(appCtx, db)
 
+	// 后台为之前上传的图片计算感知哈希
+	go func() {
+		updated, err := photoServ.BackfillPerceptualHash()
+		if err != nil {
+			appLogger.Error("backfill perceptual hash error: %v", err)
+			return
+		}
+		appLogger.Info("backfill perceptual hash of %d photos", updated)
+	}()
+
+	trashServ := service.NewTrashService(appCtx, db)
+
+	// 定时清理回收站内过期的图片
+	go func() {
+		ticker := time.NewTicker(service.TRASH_PURGE_INTERVAL)
+		defer ticker.Stop()
+		for {
+			purged, err := trashServ.PurgeExpired()
+			if err != nil {
+				appLogger.Error("purge trash error: %v", err)
+			} else if purged > 0 {
+				appLogger.Info("purge %d photos from trash", purged)
+			}
+			<-ticker.C
+		}
+	}()
+
+	albumServ := service.NewAlbumService(appCtx, db)
+	tagServ := service.NewTagService(appCtx, db)
+	dateShiftServ := service.NewDateShiftService(appCtx, db)
+
+	uploadServ := service.NewUploadService(appCtx, photoServ)
+	jobServ := service.NewJobService(appCtx, photoServ)
+
+	// 定时清理过期的断点续传文件
+	go func() {
+		ticker := time.NewTicker(service.UPLOAD_PURGE_INTERVAL)
+		defer ticker.Stop()
+		for {
+			purged, err := uploadServ.PurgeExpired()
+			if err != nil {
+				appLogger.Error("purge uploads error: %v", err)
+			} else if purged > 0 {
+				appLogger.Info("purge %d expired uploads", purged)
+			}
+			<-ticker.C
+		}
+	}()
+
 	ws.SetRouters(
-		controller.NewPhotoController(appCtx, photoServ),
+		controller.NewPhotoController(appCtx, photoServ, controller.WithJobService(jobServ)),
+		controller.NewTrashController(appCtx, trashServ),
+		controller.NewAlbumController(appCtx, albumServ),
+		controller.NewTagController(appCtx, tagServ),
+		controller.NewDateShiftController(appCtx, dateShiftServ),
+		controller.NewUploadController(appCtx, uploadServ),
+		controller.NewJobController(appCtx, jobServ),
 	)
 
 	ws.InitRouter()
@@ -0,0 +1,78 @@
+package mocks
+
+import (
+	"github.com/follow1123/photos/model/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+type AlbumService struct {
+	mock.Mock
+}
+
+func (m *AlbumService) GetAlbumById(id uint) (*dto.AlbumDto, error) {
+	ret := m.Called(id)
+
+	var r0 *dto.AlbumDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.AlbumDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *AlbumService) AlbumPage(param dto.PageParam[struct{}]) (*dto.PageResult[dto.AlbumDto], error) {
+	ret := m.Called(param)
+
+	var r0 *dto.PageResult[dto.AlbumDto]
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.PageResult[dto.AlbumDto])
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *AlbumService) CreateAlbum(param dto.CreateAlbumParam) (*dto.AlbumDto, error) {
+	ret := m.Called(param)
+
+	var r0 *dto.AlbumDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.AlbumDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *AlbumService) UpdateAlbum(param dto.AlbumParam) (*dto.AlbumDto, error) {
+	ret := m.Called(param)
+
+	var r0 *dto.AlbumDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.AlbumDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *AlbumService) DeleteAlbum(id uint) error {
+	ret := m.Called(id)
+	return ret.Error(0)
+}
+
+func (m *AlbumService) AddPhotos(albumID uint, photoIDs []uint) error {
+	ret := m.Called(albumID, photoIDs)
+	return ret.Error(0)
+}
+
+func (m *AlbumService) RemovePhotos(albumID uint, photoIDs []uint) error {
+	ret := m.Called(albumID, photoIDs)
+	return ret.Error(0)
+}
+
+func (m *AlbumService) SortPhotos(albumID uint, photoIDs []uint) error {
+	ret := m.Called(albumID, photoIDs)
+	return ret.Error(0)
+}
@@ -0,0 +1,46 @@
+package mocks
+
+import (
+	"github.com/follow1123/photos/model/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+type DateShiftService struct {
+	mock.Mock
+}
+
+func (m *DateShiftService) ShiftDates(param dto.DateShiftParam) (*dto.DateShiftDto, error) {
+	ret := m.Called(param)
+
+	var r0 *dto.DateShiftDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.DateShiftDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *DateShiftService) GetDateShift(id uint) (*dto.DateShiftDto, error) {
+	ret := m.Called(id)
+
+	var r0 *dto.DateShiftDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.DateShiftDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *DateShiftService) UndoDateShift(id uint) (*dto.DateShiftDto, error) {
+	ret := m.Called(id)
+
+	var r0 *dto.DateShiftDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.DateShiftDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
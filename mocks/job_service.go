@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"github.com/follow1123/photos/model/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+type JobService struct {
+	mock.Mock
+}
+
+func (m *JobService) EnqueueImport(params []dto.CreatePhotoParam) (*dto.JobDto, error) {
+	ret := m.Called(params)
+
+	var r0 *dto.JobDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.JobDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *JobService) GetJob(id string) (*dto.JobDto, error) {
+	ret := m.Called(id)
+
+	var r0 *dto.JobDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.JobDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *JobService) SubscribeJob(id string) (<-chan dto.JobEvent, func(), error) {
+	ret := m.Called(id)
+
+	var r0 <-chan dto.JobEvent
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(<-chan dto.JobEvent)
+	}
+
+	var r1 func()
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(func())
+	}
+
+	r2 := ret.Error(2)
+	return r0, r1, r2
+}
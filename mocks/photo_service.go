@@ -5,6 +5,7 @@ import (
 
 	"github.com/follow1123/photos/imagemanager"
 	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -36,6 +37,64 @@ func (m *PhotoService) PhotoPage(param dto.PageParam[dto.PhotoPageParam]) (*dto.
 	return r0, r1
 }
 
+func (m *PhotoService) PhotoCursorPage(param dto.CursorParam[dto.PhotoPageParam]) (*dto.CursorResult[dto.PhotoDto], error) {
+	ret := m.Called(param)
+
+	var r0 *dto.CursorResult[dto.PhotoDto]
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.CursorResult[dto.PhotoDto])
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *PhotoService) PhotoTimeline(param dto.TimelineParam) ([]dto.TimelineBucket, error) {
+	ret := m.Called(param)
+
+	var r0 []dto.TimelineBucket
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]dto.TimelineBucket)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *PhotoService) PhotoMemories(param dto.MemoryParam) ([]dto.MemoryGroup, error) {
+	ret := m.Called(param)
+
+	var r0 []dto.MemoryGroup
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]dto.MemoryGroup)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *PhotoService) SetFavorite(param dto.PhotoFavoriteParam) error {
+	ret := m.Called(param)
+	return ret.Error(0)
+}
+
+func (m *PhotoService) SetRating(param dto.PhotoRatingParam) error {
+	ret := m.Called(param)
+	return ret.Error(0)
+}
+
+func (m *PhotoService) BatchPhotos(param dto.BatchParam) ([]dto.BatchResult, error) {
+	ret := m.Called(param)
+
+	var r0 []dto.BatchResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]dto.BatchResult)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
 func (m *PhotoService) CreatePhoto(params []dto.CreatePhotoParam) []dto.CreatePhotoFailedResult {
 	ret := m.Called(params)
 
@@ -46,6 +105,18 @@ func (m *PhotoService) CreatePhoto(params []dto.CreatePhotoParam) []dto.CreatePh
 	return r0
 }
 
+func (m *PhotoService) CreatePhotoWithProgress(
+	params []dto.CreatePhotoParam, progress service.PhotoProgress,
+) []dto.CreatePhotoFailedResult {
+	ret := m.Called(params, progress)
+
+	var r0 []dto.CreatePhotoFailedResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]dto.CreatePhotoFailedResult)
+	}
+	return r0
+}
+
 func (m *PhotoService) UpdatePhoto(param dto.PhotoParam) (*dto.PhotoDto, error) {
 	ret := m.Called(param)
 
@@ -79,3 +150,71 @@ func (m *PhotoService) GetPhotoFile(id uint, original bool) (io.ReadCloser, *ima
 	r2 := ret.Error(2)
 	return r0, r1, r2
 }
+
+func (m *PhotoService) GeoPhotos(param dto.GeoParam) (*dto.GeoResult, error) {
+	ret := m.Called(param)
+
+	var r0 *dto.GeoResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.GeoResult)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *PhotoService) GetPhotoRendition(id uint, size string) (io.ReadCloser, *imagemanager.ImageInfo, error) {
+	ret := m.Called(id, size)
+
+	var r0 io.ReadCloser
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(io.ReadCloser)
+	}
+
+	var r1 *imagemanager.ImageInfo
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(*imagemanager.ImageInfo)
+	}
+
+	r2 := ret.Error(2)
+	return r0, r1, r2
+}
+
+func (m *PhotoService) RenderPhoto(id uint, param dto.RenderParam) (io.ReadCloser, *imagemanager.ImageInfo, error) {
+	ret := m.Called(id, param)
+
+	var r0 io.ReadCloser
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(io.ReadCloser)
+	}
+
+	var r1 *imagemanager.ImageInfo
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(*imagemanager.ImageInfo)
+	}
+
+	r2 := ret.Error(2)
+	return r0, r1, r2
+}
+
+func (m *PhotoService) RenderETag(id uint, param dto.RenderParam) (string, error) {
+	ret := m.Called(id, param)
+	return ret.String(0), ret.Error(1)
+}
+
+func (m *PhotoService) DuplicatePhotos(param dto.DuplicateParam) ([]dto.DuplicateCluster, error) {
+	ret := m.Called(param)
+
+	var r0 []dto.DuplicateCluster
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]dto.DuplicateCluster)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *PhotoService) BackfillPerceptualHash() (int, error) {
+	ret := m.Called()
+	return ret.Int(0), ret.Error(1)
+}
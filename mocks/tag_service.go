@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"github.com/follow1123/photos/model/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+type TagService struct {
+	mock.Mock
+}
+
+func (m *TagService) SearchTags(param dto.TagSearchParam) ([]dto.TagDto, error) {
+	ret := m.Called(param)
+
+	var r0 []dto.TagDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]dto.TagDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *TagService) AddTags(param dto.PhotoTagsParam) error {
+	ret := m.Called(param)
+	return ret.Error(0)
+}
+
+func (m *TagService) RemoveTags(param dto.PhotoTagsParam) error {
+	ret := m.Called(param)
+	return ret.Error(0)
+}
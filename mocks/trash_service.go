@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"github.com/follow1123/photos/model/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+type TrashService struct {
+	mock.Mock
+}
+
+func (m *TrashService) TrashPage(param dto.PageParam[struct{}]) (*dto.PageResult[dto.TrashPhotoDto], error) {
+	ret := m.Called(param)
+
+	var r0 *dto.PageResult[dto.TrashPhotoDto]
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.PageResult[dto.TrashPhotoDto])
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *TrashService) RestorePhoto(id uint) (*dto.PhotoDto, error) {
+	ret := m.Called(id)
+
+	var r0 *dto.PhotoDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.PhotoDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *TrashService) DeletePhoto(id uint) error {
+	ret := m.Called(id)
+	return ret.Error(0)
+}
+
+func (m *TrashService) PurgeExpired() (int, error) {
+	ret := m.Called()
+	return ret.Int(0), ret.Error(1)
+}
@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"io"
+
+	"github.com/follow1123/photos/model/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+type UploadService struct {
+	mock.Mock
+}
+
+func (m *UploadService) CreateUpload(param dto.UploadParam) (*dto.UploadDto, error) {
+	ret := m.Called(param)
+
+	var r0 *dto.UploadDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.UploadDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *UploadService) GetUpload(id string) (*dto.UploadDto, error) {
+	ret := m.Called(id)
+
+	var r0 *dto.UploadDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.UploadDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *UploadService) WriteUpload(id string, offset int64, r io.Reader) (*dto.UploadDto, error) {
+	ret := m.Called(id, offset, r)
+
+	var r0 *dto.UploadDto
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*dto.UploadDto)
+	}
+
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *UploadService) DeleteUpload(id string) error {
+	ret := m.Called(id)
+	return ret.Error(0)
+}
+
+func (m *UploadService) PurgeExpired() (int, error) {
+	ret := m.Called()
+	return ret.Int(0), ret.Error(1)
+}
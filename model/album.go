@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Album struct {
+	gorm.Model
+	Name string
+	Desc string
+	// 封面图片，为 NULL 时使用排序第一的图片
+	CoverPhotoID *uint
+	// 相册的时间范围，为 NULL 时使用相册内图片的拍摄时间
+	StartDate *time.Time
+	EndDate   *time.Time
+}
+
+// AlbumPhoto 相册和图片的关联，一张图片可以属于多个相册
+type AlbumPhoto struct {
+	AlbumID   uint `gorm:"primaryKey"`
+	PhotoID   uint `gorm:"primaryKey;index"`
+	SortOrder int64
+	CreatedAt time.Time
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// DateShift 批量修改拍摄时间的记录，用于撤销
+type DateShift struct {
+	ID uint `gorm:"primarykey"`
+	// 修改方式的说明，如偏移 -24h0m0s、时区 UTC -> Asia/Shanghai
+	Description string
+	CreatedAt   time.Time
+	// 撤销的时间，没有撤销时为 NULL
+	UndoneAt *time.Time
+	Items    []DateShiftItem
+}
+
+type DateShiftItem struct {
+	DateShiftID uint `gorm:"primaryKey"`
+	PhotoID     uint `gorm:"primaryKey;index"`
+	OldDate     time.Time
+	NewDate     time.Time
+}
@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/follow1123/photos/model"
+)
+
+type AlbumIdParam struct {
+	ID uint `json:"id" uri:"id" binding:"required"`
+}
+
+type CreateAlbumParam struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Desc      string     `json:"desc"`
+	StartDate *time.Time `json:"startDate" time_format:"2006-01-02 15:04:05"`
+	EndDate   *time.Time `json:"endDate" time_format:"2006-01-02 15:04:05"`
+}
+
+func (cp *CreateAlbumParam) ToModel() *model.Album {
+	return &model.Album{
+		Name:      cp.Name,
+		Desc:      cp.Desc,
+		StartDate: cp.StartDate,
+		EndDate:   cp.EndDate,
+	}
+}
+
+// AlbumParam 修改相册，没有填写的字段不修改，coverPhotoId 为 0 时取消设置的封面
+type AlbumParam struct {
+	ID           uint       `json:"id" binding:"required"`
+	Name         string     `json:"name" binding:"max=100"`
+	Desc         *string    `json:"desc"`
+	CoverPhotoID *uint      `json:"coverPhotoId"`
+	StartDate    *time.Time `json:"startDate" time_format:"2006-01-02 15:04:05"`
+	EndDate      *time.Time `json:"endDate" time_format:"2006-01-02 15:04:05"`
+}
+
+// AlbumPhotosParam 添加、移除相册内的图片或者调整图片的顺序
+type AlbumPhotosParam struct {
+	PhotoIDs []uint `json:"photoIds" binding:"required,min=1,dive,required"`
+}
+
+type AlbumDto struct {
+	ID           uint       `json:"id"`
+	Name         string     `json:"name"`
+	Desc         string     `json:"desc"`
+	CoverPhotoID *uint      `json:"coverPhotoId"`
+	StartDate    *time.Time `json:"startDate" time_format:"2006-01-02 15:04:05"`
+	EndDate      *time.Time `json:"endDate" time_format:"2006-01-02 15:04:05"`
+	PhotoCount   int64      `json:"photoCount"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (a *AlbumDto) Update(album *model.Album) {
+	a.ID = album.ID
+	a.Name = album.Name
+	a.Desc = album.Desc
+	a.CoverPhotoID = album.CoverPhotoID
+	a.StartDate = album.StartDate
+	a.EndDate = album.EndDate
+	a.CreatedAt = album.CreatedAt
+	a.UpdatedAt = album.UpdatedAt
+}
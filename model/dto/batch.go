@@ -0,0 +1,22 @@
+package dto
+
+// BatchParam 批量操作的参数，photoIds 和 query 至少填写一个，同时填写时只处理满足查询语句的图片
+type BatchParam struct {
+	PhotoIDs []uint `json:"photoIds" binding:"omitempty,dive,required"`
+	Query    string `json:"query"`
+	Op       string `json:"op" binding:"required,oneof=shiftDate setDesc delete addTag addToAlbum"`
+	// shiftDate 拍摄时间的偏移，如 1h30m、-24h
+	Offset string `json:"offset"`
+	// setDesc 修改后的描述，空字符串表示清空描述
+	Desc *string `json:"desc"`
+	// addTag 添加的标签
+	Tags []string `json:"tags" binding:"omitempty,dive,required,max=32"`
+	// addToAlbum 添加到的相册
+	AlbumID uint `json:"albumId"`
+}
+
+type BatchResult struct {
+	PhotoID uint   `json:"photoId"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
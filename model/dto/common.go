@@ -12,3 +12,20 @@ type PageResult[T any] struct {
 	PageSize int   `json:"pageSize"`
 	Total    int64 `json:"total"`
 }
+
+// CursorParam 基于 cursor 的分页参数，cursor 为空时查询第一页
+type CursorParam[T any] struct {
+	Params T      `json:"params" form:"params"`
+	Cursor string `json:"cursor" form:"cursor"`
+	Limit  int    `json:"limit" form:"limit" binding:"omitempty,min=1,max=200"`
+	// 是否查询总数，查询总数需要扫描所有符合条件的数据
+	WithTotal bool `json:"withTotal" form:"withTotal"`
+}
+
+// CursorResult 基于 cursor 的分页结果，没有下一页或上一页时对应的 cursor 为空
+type CursorResult[T any] struct {
+	List       []T    `json:"list"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
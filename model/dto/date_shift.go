@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/follow1123/photos/model"
+)
+
+// DateShiftParam 修改拍摄时间，photoIds 和 query 至少填写一个
+// offset 和 fromZone、toZone 只能使用一种方式
+type DateShiftParam struct {
+	PhotoIDs []uint `json:"photoIds" binding:"omitempty,dive,required"`
+	Query    string `json:"query"`
+	// 偏移，如 1h30m、-24h
+	Offset string `json:"offset"`
+	// 把按 fromZone 记录的拍摄时间转换为 toZone 的时间，如相机使用 UTC，拍摄地为 Asia/Shanghai
+	FromZone string `json:"fromZone" binding:"required_with=ToZone"`
+	ToZone   string `json:"toZone" binding:"required_with=FromZone"`
+	// 只返回修改前后的时间，不修改
+	DryRun bool `json:"dryRun"`
+}
+
+type DateShiftIdParam struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+type DateShiftItemDto struct {
+	PhotoID uint      `json:"photoId"`
+	OldDate time.Time `json:"oldDate"`
+	NewDate time.Time `json:"newDate"`
+	// 撤销时拍摄时间已经被再次修改或者图片已经彻底删除，没有撤销
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+type DateShiftDto struct {
+	// 预览时为 0
+	ID          uint               `json:"id"`
+	Description string             `json:"description"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UndoneAt    *time.Time         `json:"undoneAt"`
+	Items       []DateShiftItemDto `json:"items"`
+}
+
+func (d *DateShiftDto) Update(dateShift *model.DateShift) {
+	d.ID = dateShift.ID
+	d.Description = dateShift.Description
+	d.CreatedAt = dateShift.CreatedAt
+	d.UndoneAt = dateShift.UndoneAt
+	d.Items = make([]DateShiftItemDto, 0, len(dateShift.Items))
+	for _, item := range dateShift.Items {
+		d.Items = append(d.Items, DateShiftItemDto{PhotoID: item.PhotoID, OldDate: item.OldDate, NewDate: item.NewDate})
+	}
+}
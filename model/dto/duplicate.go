@@ -0,0 +1,10 @@
+package dto
+
+type DuplicateParam struct {
+	// 汉明距离小于等于该值的图片认为是相似的图片
+	Distance *int `json:"distance" form:"distance" binding:"omitempty,min=0,max=32"`
+}
+
+type DuplicateCluster struct {
+	Photos []PhotoDto `json:"photos"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+type GeoParam struct {
+	MinLat *float64 `json:"minLat" form:"minLat" binding:"required,min=-90,max=90"`
+	MinLng *float64 `json:"minLng" form:"minLng" binding:"required,min=-180,max=180"`
+	MaxLat *float64 `json:"maxLat" form:"maxLat" binding:"required,min=-90,max=90"`
+	MaxLng *float64 `json:"maxLng" form:"maxLng" binding:"required,min=-180,max=180"`
+	Zoom   *int     `json:"zoom" form:"zoom" binding:"omitempty,min=0,max=22"`
+	Limit  int      `json:"limit" form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+type GeoCluster struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Count     int64   `json:"count"`
+	PhotoID   uint    `json:"photoId"`
+}
+
+type GeoResult struct {
+	Photos   []PhotoDto   `json:"photos"`
+	Clusters []GeoCluster `json:"clusters"`
+}
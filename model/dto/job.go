@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// JobDto 异步导入图片的任务
+type JobDto struct {
+	ID         string       `json:"id"`
+	Status     string       `json:"status"`
+	Files      []JobFileDto `json:"files"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+}
+
+// JobFileDto 任务内每个文件的状态，保存成功时返回图片的 id
+type JobFileDto struct {
+	UploadID uint   `json:"uploadId"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	PhotoID  uint   `json:"photoId,omitempty"`
+}
+
+// JobEvent 推送给订阅者的任务进度，event 为 file 时 data 为 JobFileDto，其他时候为 JobDto
+type JobEvent struct {
+	Event string
+	Data  any
+}
+
+type JobIdParam struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+type AsyncParam struct {
+	Async bool `form:"async"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+type MemoryParam struct {
+	// 默认为当天
+	Date *time.Time `json:"date" form:"date" time_format:"2006-01-02"`
+	// 包含前后多少天内拍摄的图片，默认使用配置文件内的 memoriesWindowDays
+	Window *int `json:"window" form:"window" binding:"omitempty,min=0,max=30"`
+	// 每年最多返回的图片数量
+	Limit int `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type MemoryGroup struct {
+	Year     int `json:"year"`
+	YearsAgo int `json:"yearsAgo"`
+	// 当年符合条件的所有图片数量
+	Count  int        `json:"count"`
+	Photos []PhotoDto `json:"photos"`
+}
@@ -31,10 +31,30 @@ func (cpp *CreatePhotoParam) ToModel() *model.Photo {
 type CreatePhotoFailedResult struct {
 	UploadID uint   `json:"uploadId"`
 	Message  string `json:"message"`
+	// 和其他图片重复
+	Duplicate bool `json:"duplicate,omitempty"`
 }
 
 type PhotoPageParam struct {
 	Desc string `json:"desc" form:"desc"`
+	// 只查询相册内的图片，按相册内的顺序排序
+	AlbumID uint `json:"albumId" form:"albumId"`
+	// 按标签过滤，tagMode 为 any 时包含任意一个标签，为 all 时包含所有标签
+	Tags    []string `json:"tags" form:"tags"`
+	TagMode string   `json:"tagMode" form:"tagMode" binding:"omitempty,oneof=any all"`
+	// 查询语句，如 tag:beach date:2023-06..2023-08 width>3000 -tag:private
+	Query string `json:"query" form:"query"`
+	// 全文搜索描述、文件名和标签，按相关度排序
+	Q string `json:"q" form:"q"`
+	// 只查询收藏或未收藏的图片，评分不低于 minRating 的图片
+	Favorite  *bool `json:"favorite" form:"favorite"`
+	MinRating int64 `json:"minRating" form:"minRating" binding:"omitempty,min=0,max=5"`
+	// 排序字段和方向，默认按拍摄时间倒序
+	Sort  string `json:"sort" form:"sort" binding:"omitempty,oneof=photoDate createdAt size width rating favorite"`
+	Order string `json:"order" form:"order" binding:"omitempty,oneof=asc desc"`
+	// 拍摄日期的范围，包含 from 和 to 当天
+	From *time.Time `json:"from" form:"from" time_format:"2006-01-02"`
+	To   *time.Time `json:"to" form:"to" time_format:"2006-01-02"`
 }
 
 func (ppp *PhotoPageParam) ToModel() *model.Photo {
@@ -47,33 +67,101 @@ type PhotoParam struct {
 	PhotoDate time.Time `json:"photoDate" time_format:"2006-01-02 15:04:05"`
 }
 
+type FavoriteParam struct {
+	Favorite *bool `json:"favorite" binding:"required"`
+}
+
+type RatingParam struct {
+	Rating *int64 `json:"rating" binding:"required,min=0,max=5"`
+}
+
+// PhotoFavoriteParam 批量设置多张图片的收藏
+type PhotoFavoriteParam struct {
+	PhotoIDs []uint `json:"photoIds" binding:"required,min=1,dive,required"`
+	Favorite *bool  `json:"favorite" binding:"required"`
+}
+
+// PhotoRatingParam 批量设置多张图片的评分
+type PhotoRatingParam struct {
+	PhotoIDs []uint `json:"photoIds" binding:"required,min=1,dive,required"`
+	Rating   *int64 `json:"rating" binding:"required,min=0,max=5"`
+}
+
 type PhotoDto struct {
-	ID        uint      `json:"id"`
-	Desc      string    `json:"desc"`
-	Format    string    `json:"format"`
-	Size      int64     `json:"size"`
-	Width     int64     `json:"width"`
-	Height    int64     `json:"height"`
-	PhotoDate time.Time `json:"photoDate" time_format:"2006-01-02 15:04:05"`
+	ID           uint      `json:"id"`
+	Desc         string    `json:"desc"`
+	FileName     string    `json:"fileName"`
+	Format       string    `json:"format"`
+	Size         int64     `json:"size"`
+	Width        int64     `json:"width"`
+	Height       int64     `json:"height"`
+	PhotoDate    time.Time `json:"photoDate" time_format:"2006-01-02 15:04:05"`
+	CameraMake   string    `json:"cameraMake"`
+	CameraModel  string    `json:"cameraModel"`
+	LensModel    string    `json:"lensModel"`
+	ExposureTime string    `json:"exposureTime"`
+	FNumber      float64   `json:"fNumber"`
+	ISO          int64     `json:"iso"`
+	FocalLength  float64   `json:"focalLength"`
+	Orientation  int64     `json:"orientation"`
+	Latitude     *float64  `json:"latitude"`
+	Longitude    *float64  `json:"longitude"`
+	Altitude     *float64  `json:"altitude"`
+	PHash        string    `json:"pHash"`
+	Favorite     bool      `json:"favorite"`
+	Rating       int64     `json:"rating"`
+	Tags         []string  `json:"tags" gorm:"-"`
+	// 全文搜索时匹配的内容，关键字使用 <mark> 标记
+	Snippet string `json:"snippet,omitempty" gorm:"-"`
 }
 
 func (p *PhotoDto) Update(photo *model.Photo) {
 	p.ID = photo.ID
 	p.Desc = photo.Desc
+	p.FileName = photo.FileName
 	p.Format = photo.Format
 	p.Size = photo.Size
 	p.Width = photo.Width
 	p.Height = photo.Height
 	p.PhotoDate = photo.PhotoDate
+	p.CameraMake = photo.CameraMake
+	p.CameraModel = photo.CameraModel
+	p.LensModel = photo.LensModel
+	p.ExposureTime = photo.ExposureTime
+	p.FNumber = photo.FNumber
+	p.ISO = photo.ISO
+	p.FocalLength = photo.FocalLength
+	p.Orientation = photo.Orientation
+	p.Latitude = photo.Latitude
+	p.Longitude = photo.Longitude
+	p.Altitude = photo.Altitude
+	p.PHash = photo.PHash
+	p.Favorite = photo.Favorite
+	p.Rating = photo.Rating
 }
 
 func (p *PhotoDto) ToModel() *model.Photo {
 	photo := model.Photo{
-		Desc:   p.Desc,
-		Size:   p.Size,
-		Format: p.Format,
-		Width:  p.Width,
-		Height: p.Height,
+		Desc:         p.Desc,
+		FileName:     p.FileName,
+		Size:         p.Size,
+		Format:       p.Format,
+		Width:        p.Width,
+		Height:       p.Height,
+		CameraMake:   p.CameraMake,
+		CameraModel:  p.CameraModel,
+		LensModel:    p.LensModel,
+		ExposureTime: p.ExposureTime,
+		FNumber:      p.FNumber,
+		ISO:          p.ISO,
+		FocalLength:  p.FocalLength,
+		Orientation:  p.Orientation,
+		Latitude:     p.Latitude,
+		Longitude:    p.Longitude,
+		Altitude:     p.Altitude,
+		PHash:        p.PHash,
+		Favorite:     p.Favorite,
+		Rating:       p.Rating,
 	}
 	if p.PhotoDate.IsZero() {
 		photo.PhotoDate = time.Now()
@@ -82,3 +170,21 @@ func (p *PhotoDto) ToModel() *model.Photo {
 	}
 	return &photo
 }
+
+type RenderParam struct {
+	Width   int    `json:"w" form:"w" binding:"omitempty,min=1,max=4096"`
+	Height  int    `json:"h" form:"h" binding:"omitempty,min=1,max=4096"`
+	Fit     string `json:"fit" form:"fit" binding:"omitempty,oneof=cover contain"`
+	Format  string `json:"format" form:"format" binding:"omitempty,oneof=jpeg png"`
+	Quality int    `json:"q" form:"q" binding:"omitempty,min=1,max=100"`
+}
+
+func (rp *RenderParam) ToOption() imagemanager.RenderOption {
+	return imagemanager.RenderOption{
+		Width:   rp.Width,
+		Height:  rp.Height,
+		Fit:     rp.Fit,
+		Format:  rp.Format,
+		Quality: rp.Quality,
+	}
+}
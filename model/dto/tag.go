@@ -0,0 +1,22 @@
+package dto
+
+type TagSearchParam struct {
+	Prefix string `json:"prefix" form:"prefix"`
+	Limit  int    `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type TagsParam struct {
+	Tags []string `json:"tags" binding:"required,min=1,dive,required,max=32"`
+}
+
+// PhotoTagsParam 批量添加、删除多张图片的标签
+type PhotoTagsParam struct {
+	PhotoIDs []uint   `json:"photoIds" binding:"required,min=1,dive,required"`
+	Tags     []string `json:"tags" binding:"required,min=1,dive,required,max=32"`
+}
+
+type TagDto struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+type TimelineParam struct {
+	// 图片列表的过滤条件，order 决定时间段和时间段内图片的顺序
+	Params      PhotoPageParam `json:"params" form:"params"`
+	Granularity string         `json:"granularity" form:"granularity" binding:"omitempty,oneof=year month day"`
+}
+
+type TimelineBucket struct {
+	// 时间段，格式为 2006、2006-01 或 2006-01-02
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+	// 时间段内按列表顺序的第一张和最后一张图片
+	FirstPhotoID uint `json:"firstPhotoId"`
+	LastPhotoID  uint `json:"lastPhotoId"`
+	// 作为缩略图的图片，分辨率最高的图片
+	CoverPhotoID uint `json:"coverPhotoId"`
+}
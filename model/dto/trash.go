@@ -0,0 +1,10 @@
+package dto
+
+import "time"
+
+type TrashPhotoDto struct {
+	PhotoDto
+	DeletedAt time.Time `json:"deletedAt"`
+	// 自动彻底删除的时间，不自动删除时为 null
+	PurgeAt *time.Time `json:"purgeAt" gorm:"-"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+// UploadParam 创建断点续传，metadata 内的 filename、desc、photoDate 用于上传完成后创建图片
+type UploadParam struct {
+	Length   int64
+	Metadata map[string]string
+}
+
+// UploadDto 断点续传的进度
+type UploadDto struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+func (ud *UploadDto) Completed() bool {
+	return ud.Offset == ud.Length
+}
@@ -8,7 +8,9 @@ import (
 
 type Photo struct {
 	gorm.Model
-	Desc      string
+	Desc string
+	// 上传时的文件名
+	FileName  string
 	Format    string
 	Uri       string
 	Size      int64
@@ -16,4 +18,26 @@ type Photo struct {
 	Width     int64
 	Height    int64
 	PhotoDate time.Time
+
+	// exif 信息
+	CameraMake   string
+	CameraModel  string
+	LensModel    string
+	ExposureTime string
+	FNumber      float64
+	ISO          int64
+	FocalLength  float64
+	Orientation  int64
+
+	// gps 信息，没有位置信息时为 NULL
+	Latitude  *float64 `gorm:"index:idx_photos_location,priority:1"`
+	Longitude *float64 `gorm:"index:idx_photos_location,priority:2"`
+	Altitude  *float64
+
+	// 感知哈希（dHash），16 位十六进制，用于查找相似的图片
+	PHash string `gorm:"index"`
+
+	// 收藏和评分，评分为 0-5，0 表示未评分
+	Favorite bool  `gorm:"not null;default:false"`
+	Rating   int64 `gorm:"not null;default:0"`
 }
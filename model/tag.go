@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Tag 标签名不区分大小写
+type Tag struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"type:text COLLATE NOCASE;uniqueIndex"`
+	CreatedAt time.Time
+}
+
+type PhotoTag struct {
+	PhotoID uint `gorm:"primaryKey"`
+	TagID   uint `gorm:"primaryKey;index"`
+}
@@ -0,0 +1,244 @@
+package search
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 查询语句支持的字段
+const (
+	FIELD_TAG    = "tag"
+	FIELD_DESC   = "desc"
+	FIELD_CAMERA = "camera"
+	FIELD_LENS   = "lens"
+	FIELD_FORMAT = "format"
+	FIELD_HAS    = "has"
+	FIELD_DATE   = "date"
+	FIELD_WIDTH  = "width"
+	FIELD_HEIGHT = "height"
+	FIELD_ISO    = "iso"
+	FIELD_SIZE   = "size"
+	FIELD_RATING = "rating"
+)
+
+// has 字段支持的值
+const (
+	HAS_GPS      = "gps"
+	HAS_TAG      = "tag"
+	HAS_ALBUM    = "album"
+	HAS_FAVORITE = "favorite"
+)
+
+type FieldKind int
+
+const (
+	KIND_TEXT FieldKind = iota
+	KIND_NUMBER
+	KIND_SIZE
+	KIND_DATE
+)
+
+var fieldKinds = map[string]FieldKind{
+	FIELD_TAG:    KIND_TEXT,
+	FIELD_DESC:   KIND_TEXT,
+	FIELD_CAMERA: KIND_TEXT,
+	FIELD_LENS:   KIND_TEXT,
+	FIELD_FORMAT: KIND_TEXT,
+	FIELD_HAS:    KIND_TEXT,
+	FIELD_DATE:   KIND_DATE,
+	FIELD_WIDTH:  KIND_NUMBER,
+	FIELD_HEIGHT: KIND_NUMBER,
+	FIELD_ISO:    KIND_NUMBER,
+	FIELD_SIZE:   KIND_SIZE,
+	FIELD_RATING: KIND_NUMBER,
+}
+
+var hasValues = []string{HAS_GPS, HAS_TAG, HAS_ALBUM, HAS_FAVORITE}
+
+// 日期支持的格式，精确到年、月、日
+var dateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// 大小的单位
+var sizeUnits = map[string]float64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+}
+
+type termError struct {
+	// 为 true 时错误在字段名上，否则在值上
+	field   bool
+	message string
+}
+
+func fieldError(message string) *termError {
+	return &termError{field: true, message: message}
+}
+
+func valueError(message string) *termError {
+	return &termError{message: message}
+}
+
+// resolve 检查字段和运算符，解析数字和日期的值
+func (t *Term) resolve() *termError {
+	kind, ok := fieldKinds[t.Field]
+	if !ok {
+		return fieldError("未知的字段")
+	}
+	if kind == KIND_TEXT && t.Op != OP_MATCH && t.Op != OP_EQ {
+		return fieldError("只支持 : 和 = 运算符")
+	}
+
+	switch kind {
+	case KIND_TEXT:
+		if t.Field == FIELD_HAS && !slices.Contains(hasValues, strings.ToLower(t.Value)) {
+			return valueError("只支持 " + strings.Join(hasValues, "、"))
+		}
+	case KIND_NUMBER, KIND_SIZE:
+		return t.resolveNumber(kind)
+	case KIND_DATE:
+		return t.resolveDate()
+	}
+	return nil
+}
+
+func parseNumber(kind FieldKind, s string) (float64, bool) {
+	s = strings.ToLower(s)
+	unit := 1.0
+	if kind == KIND_SIZE {
+		i := strings.IndexFunc(s, func(r rune) bool { return r >= 'a' && r <= 'z' })
+		if i >= 0 {
+			var ok bool
+			if unit, ok = sizeUnits[s[i:]]; !ok {
+				return 0, false
+			}
+			s = s[:i]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * unit, true
+}
+
+func (t *Term) resolveNumber(kind FieldKind) *termError {
+	invalid := valueError("不是有效的数字")
+	if kind == KIND_SIZE {
+		invalid = valueError("不是有效的大小，支持 KB、MB、GB 单位")
+	}
+
+	low, high, isRange := strings.Cut(t.Value, RANGE_SEP)
+	if !isRange {
+		n, ok := parseNumber(kind, t.Value)
+		if !ok {
+			return invalid
+		}
+		t.Number = n
+		return nil
+	}
+	if t.Op != OP_MATCH && t.Op != OP_EQ {
+		return valueError("比较运算不能使用范围")
+	}
+	if low == "" && high == "" {
+		return valueError("范围至少需要一个端点")
+	}
+	if low != "" {
+		n, ok := parseNumber(kind, low)
+		if !ok {
+			return invalid
+		}
+		t.Low = &n
+	}
+	if high != "" {
+		n, ok := parseNumber(kind, high)
+		if !ok {
+			return invalid
+		}
+		t.High = &n
+	}
+	if t.Low != nil && t.High != nil && *t.Low > *t.High {
+		return valueError("范围的开始不能大于结束")
+	}
+	return nil
+}
+
+// parsePeriod 解析年、月或者日，返回这段时间的开始和结束
+func parsePeriod(s string) (time.Time, time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if len(s) != len(layout) {
+			continue
+		}
+		start, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		switch layout {
+		case "2006":
+			return start, start.AddDate(1, 0, 0), true
+		case "2006-01":
+			return start, start.AddDate(0, 1, 0), true
+		default:
+			return start, start.AddDate(0, 0, 1), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func (t *Term) resolveDate() *termError {
+	invalid := valueError("不是有效的日期，支持 2006、2006-01、2006-01-02 格式")
+
+	low, high, isRange := strings.Cut(t.Value, RANGE_SEP)
+	if !isRange {
+		start, end, ok := parsePeriod(t.Value)
+		if !ok {
+			return invalid
+		}
+		switch t.Op {
+		case OP_MATCH, OP_EQ:
+			t.Start, t.End = start, end
+		case OP_GT:
+			t.Start = end
+		case OP_GE:
+			t.Start = start
+		case OP_LT:
+			t.End = start
+		case OP_LE:
+			t.End = end
+		}
+		return nil
+	}
+
+	if t.Op != OP_MATCH && t.Op != OP_EQ {
+		return valueError("比较运算不能使用范围")
+	}
+	if low == "" && high == "" {
+		return valueError("范围至少需要一个端点")
+	}
+	// 范围包含两端的整个时间段
+	if low != "" {
+		start, _, ok := parsePeriod(low)
+		if !ok {
+			return invalid
+		}
+		t.Start = start
+	}
+	if high != "" {
+		_, end, ok := parsePeriod(high)
+		if !ok {
+			return invalid
+		}
+		t.End = end
+	}
+	if !t.Start.IsZero() && !t.End.IsZero() && !t.Start.Before(t.End) {
+		return valueError("范围的开始不能晚于结束")
+	}
+	return nil
+}
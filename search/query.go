@@ -0,0 +1,175 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 字段和值之间的运算符
+const (
+	OP_MATCH = ":"
+	OP_EQ    = "="
+	OP_GT    = ">"
+	OP_GE    = ">="
+	OP_LT    = "<"
+	OP_LE    = "<="
+)
+
+// 范围的分隔符，两端都可以省略，如 2023-06..2023-08、3000..
+const RANGE_SEP = ".."
+
+// SyntaxError 查询语句错误，Pos 为出错的字符位置，从 1 开始
+type SyntaxError struct {
+	Pos     int    `json:"position"`
+	Token   string `json:"token"`
+	Message string `json:"message"`
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("第 %d 个字符 [ %s ] %s", se.Pos, se.Token, se.Message)
+}
+
+// Term 查询语句内的一个条件，多个条件之间是且的关系
+type Term struct {
+	// 条件在查询语句内的位置，从 1 开始
+	Pos  int
+	Text string
+	// 条件前有 - 时取反
+	Negate bool
+	// 没有字段名时为空，匹配图片描述
+	Field string
+	Op    string
+	Value string
+	// 数字和大小字段的比较值，运算符为 : 或 = 时使用 Low、High 表示范围
+	Number    float64
+	Low, High *float64
+	// 日期字段的范围，包含 Start 不包含 End，零值表示不限制
+	Start, End time.Time
+}
+
+// Parse 解析查询语句，如 tag:beach date:2023-06..2023-08 width>3000 camera:"X100V" -tag:private
+func Parse(query string) ([]Term, error) {
+	runes := []rune(query)
+	terms := make([]Term, 0)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		term, next, err := parseTerm(runes, i)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, *term)
+		i = next
+	}
+	return terms, nil
+}
+
+func isOpChar(r rune) bool {
+	return r == ':' || r == '=' || r == '>' || r == '<'
+}
+
+func syntaxError(runes []rune, start int, end int, message string, args ...any) *SyntaxError {
+	return &SyntaxError{Pos: start + 1, Token: string(runes[start:end]), Message: fmt.Sprintf(message, args...)}
+}
+
+// parseTerm 从 start 开始解析一个条件，返回条件和下一个条件的开始位置
+func parseTerm(runes []rune, start int) (*Term, int, error) {
+	term := &Term{Pos: start + 1}
+	i := start
+	if runes[i] == '-' {
+		term.Negate = true
+		i++
+		if i == len(runes) || unicode.IsSpace(runes[i]) {
+			return nil, 0, syntaxError(runes, start, i, "后缺少查询条件")
+		}
+	}
+
+	// 只有值的条件
+	if runes[i] == '"' {
+		value, next, err := readQuoted(runes, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		term.Value = value
+		term.Text = string(runes[start:next])
+		return term, next, nil
+	}
+
+	nameStart := i
+	for i < len(runes) && !unicode.IsSpace(runes[i]) && !isOpChar(runes[i]) {
+		i++
+	}
+	if i == len(runes) || unicode.IsSpace(runes[i]) {
+		term.Value = string(runes[nameStart:i])
+		term.Text = string(runes[start:i])
+		return term, i, nil
+	}
+
+	// 字段名和运算符
+	if i == nameStart {
+		return nil, 0, syntaxError(runes, i, i+1, "前缺少字段名")
+	}
+	term.Field = strings.ToLower(string(runes[nameStart:i]))
+	opStart := i
+	i++
+	if (runes[opStart] == '>' || runes[opStart] == '<') && i < len(runes) && runes[i] == '=' {
+		i++
+	}
+	term.Op = string(runes[opStart:i])
+
+	// 值
+	valueStart := i
+	if i < len(runes) && runes[i] == '"' {
+		value, next, err := readQuoted(runes, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		term.Value = value
+		i = next
+	} else {
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		term.Value = string(runes[valueStart:i])
+	}
+	term.Text = string(runes[start:i])
+	if term.Value == "" {
+		return nil, 0, syntaxError(runes, start, i, "缺少值")
+	}
+
+	if err := term.resolve(); err != nil {
+		// 字段错误指向字段名，值错误指向值
+		if err.field {
+			return nil, 0, syntaxError(runes, nameStart, opStart, "%s", err.message)
+		}
+		return nil, 0, syntaxError(runes, valueStart, i, "%s", err.message)
+	}
+	return term, i, nil
+}
+
+// readQuoted 读取双引号内的值，支持 \" 和 \\ 转义
+func readQuoted(runes []rune, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			sb.WriteRune(runes[i])
+		case '"':
+			i++
+			if i < len(runes) && !unicode.IsSpace(runes[i]) {
+				return "", 0, syntaxError(runes, start, i+1, "引号后需要空格")
+			}
+			return sb.String(), i, nil
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+	return "", 0, syntaxError(runes, start, len(runes), "引号没有闭合")
+}
@@ -0,0 +1,147 @@
+package search_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/search"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueryTestSuite struct {
+	suite.Suite
+}
+
+func TestQueryTestSuite(t *testing.T) {
+	suite.Run(t, &QueryTestSuite{})
+}
+
+func (s *QueryTestSuite) TestParse() {
+	terms, err := search.Parse(`tag:beach  date:2023-06..2023-08 format:png width>3000 camera:"X100V" -tag:private sunset`)
+	s.Nil(err)
+	s.Len(terms, 7)
+
+	expected := []struct {
+		pos    int
+		negate bool
+		field  string
+		op     string
+		value  string
+	}{
+		{1, false, search.FIELD_TAG, search.OP_MATCH, "beach"},
+		{12, false, search.FIELD_DATE, search.OP_MATCH, "2023-06..2023-08"},
+		{34, false, search.FIELD_FORMAT, search.OP_MATCH, "png"},
+		{45, false, search.FIELD_WIDTH, search.OP_GT, "3000"},
+		{56, false, search.FIELD_CAMERA, search.OP_MATCH, "X100V"},
+		{71, true, search.FIELD_TAG, search.OP_MATCH, "private"},
+		{84, false, "", "", "sunset"},
+	}
+	for i, e := range expected {
+		s.Equal(e.pos, terms[i].Pos, terms[i].Text)
+		s.Equal(e.negate, terms[i].Negate)
+		s.Equal(e.field, terms[i].Field)
+		s.Equal(e.op, terms[i].Op)
+		s.Equal(e.value, terms[i].Value)
+	}
+
+	s.Equal(time.Date(2023, 6, 1, 0, 0, 0, 0, time.Local), terms[1].Start)
+	s.Equal(time.Date(2023, 9, 1, 0, 0, 0, 0, time.Local), terms[1].End)
+	s.Equal(float64(3000), terms[3].Number)
+}
+
+func (s *QueryTestSuite) TestParseQuoted() {
+	terms, err := search.Parse(`"new year" desc:"say \"hi\"" -"old"`)
+	s.Nil(err)
+	s.Len(terms, 3)
+	s.Equal("new year", terms[0].Value)
+	s.Equal(`say "hi"`, terms[1].Value)
+	s.True(terms[2].Negate)
+	s.Equal("old", terms[2].Value)
+
+	terms, err = search.Parse("  ")
+	s.Nil(err)
+	s.Len(terms, 0)
+}
+
+func (s *QueryTestSuite) TestParseNumber() {
+	scenarios := []struct {
+		query  string
+		number float64
+		low    *float64
+		high   *float64
+	}{
+		{"width>=1920", 1920, nil, nil},
+		{"size<2mb", 2 << 20, nil, nil},
+		{"size:1.5KB", 1.5 * 1024, nil, nil},
+		{"iso:100..800", 0, ptr(100), ptr(800)},
+		{"height:..1080", 0, nil, ptr(1080)},
+		{"height=720..", 0, ptr(720), nil},
+	}
+	for _, scenario := range scenarios {
+		terms, err := search.Parse(scenario.query)
+		s.Nil(err, scenario.query)
+		s.Equal(scenario.number, terms[0].Number, scenario.query)
+		s.Equal(scenario.low, terms[0].Low, scenario.query)
+		s.Equal(scenario.high, terms[0].High, scenario.query)
+	}
+}
+
+func (s *QueryTestSuite) TestParseDate() {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.Local)
+	}
+	scenarios := []struct {
+		query string
+		start time.Time
+		end   time.Time
+	}{
+		{"date:2023", day(2023, 1, 1), day(2024, 1, 1)},
+		{"date=2023-02-28", day(2023, 2, 28), day(2023, 3, 1)},
+		{"date>2023-06", day(2023, 7, 1), time.Time{}},
+		{"date>=2023-06", day(2023, 6, 1), time.Time{}},
+		{"date<2023-06", time.Time{}, day(2023, 6, 1)},
+		{"date<=2023-06", time.Time{}, day(2023, 7, 1)},
+		{"date:2022..", day(2022, 1, 1), time.Time{}},
+		{"date:..2022-12-31", time.Time{}, day(2023, 1, 1)},
+	}
+	for _, scenario := range scenarios {
+		terms, err := search.Parse(scenario.query)
+		s.Nil(err, scenario.query)
+		s.Equal(scenario.start, terms[0].Start, scenario.query)
+		s.Equal(scenario.end, terms[0].End, scenario.query)
+	}
+}
+
+func (s *QueryTestSuite) TestParseError() {
+	scenarios := []struct {
+		query string
+		pos   int
+		token string
+	}{
+		{"tag:a foo:bar", 7, "foo"},
+		{"tag:a tag>b", 7, "tag"},
+		{"width>abc", 7, "abc"},
+		{"size<3tb", 6, "3tb"},
+		{"date:2023-13", 6, "2023-13"},
+		{"date:2023-08..2023-06", 6, "2023-08..2023-06"},
+		{"date>2023..2024", 6, "2023..2024"},
+		{"width:..", 7, ".."},
+		{"has:money", 5, "money"},
+		{"tag:", 1, "tag:"},
+		{":beach", 1, ":"},
+		{"a - b", 3, "-"},
+		{`camera:"X100V`, 8, `"X100V`},
+		{`"a"b`, 1, `"a"b`},
+	}
+	for _, scenario := range scenarios {
+		_, err := search.Parse(scenario.query)
+		syntaxErr, ok := err.(*search.SyntaxError)
+		s.True(ok, scenario.query)
+		s.Equal(scenario.pos, syntaxErr.Pos, scenario.query)
+		s.Equal(scenario.token, syntaxErr.Token, scenario.query)
+	}
+}
+
+func ptr(n float64) *float64 {
+	return &n
+}
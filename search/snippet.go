@@ -0,0 +1,95 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// 高亮关键字的标记
+const (
+	HIGHLIGHT_START  = "<mark>"
+	HIGHLIGHT_END    = "</mark>"
+	SNIPPET_ELLIPSIS = "…"
+	// 摘要的长度（字符）
+	SNIPPET_SIZE = 32
+)
+
+// Keywords 按空白分割搜索的关键字
+func Keywords(q string) []string {
+	return strings.Fields(q)
+}
+
+// matchAt 返回 runes 的 i 位置匹配的最长关键字的长度，不区分大小写
+func matchAt(runes []rune, i int, keywords [][]rune) int {
+	longest := 0
+	for _, keyword := range keywords {
+		if len(keyword) <= longest || i+len(keyword) > len(runes) {
+			continue
+		}
+		matched := true
+		for j, r := range keyword {
+			if unicode.ToLower(runes[i+j]) != r {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			longest = len(keyword)
+		}
+	}
+	return longest
+}
+
+// Snippet 截取 text 内第一个关键字附近 size 个字符，并标记其中所有的关键字，返回转义后的 html
+// 没有匹配的关键字时返回空字符串
+func Snippet(text string, keywords []string, size int) string {
+	runes := []rune(text)
+	lowerKeywords := make([][]rune, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword != "" {
+			lowerKeywords = append(lowerKeywords, []rune(strings.ToLower(keyword)))
+		}
+	}
+
+	first := -1
+	for i := range runes {
+		if matchAt(runes, i, lowerKeywords) > 0 {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// 关键字前保留三分之一的内容
+	start := max(0, first-size/3)
+	end := min(len(runes), start+size)
+	start = max(0, end-size)
+
+	// 摘要作为 html 显示，除了高亮的标记都需要转义
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString(SNIPPET_ELLIPSIS)
+	}
+	plain := start
+	for i := start; i < end; {
+		if n := matchAt(runes, i, lowerKeywords); n > 0 {
+			sb.WriteString(html.EscapeString(string(runes[plain:i])))
+			sb.WriteString(HIGHLIGHT_START)
+			sb.WriteString(html.EscapeString(string(runes[i : i+n])))
+			sb.WriteString(HIGHLIGHT_END)
+			i += n
+			plain = i
+			end = max(end, i)
+			continue
+		}
+		i++
+	}
+	sb.WriteString(html.EscapeString(string(runes[plain:end])))
+	if end < len(runes) {
+		sb.WriteString(SNIPPET_ELLIPSIS)
+	}
+	return sb.String()
+}
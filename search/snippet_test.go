@@ -0,0 +1,45 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/follow1123/photos/search"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnippetTestSuite struct {
+	suite.Suite
+}
+
+func TestSnippetTestSuite(t *testing.T) {
+	suite.Run(t, &SnippetTestSuite{})
+}
+
+func (s *SnippetTestSuite) TestSnippet() {
+	scenarios := []struct {
+		text     string
+		keywords []string
+		size     int
+		expected string
+	}{
+		{"sunset at the beach", []string{"beach"}, 32, "sunset at the <mark>beach</mark>"},
+		{"Beach and beach", []string{"BEACH"}, 32, "<mark>Beach</mark> and <mark>beach</mark>"},
+		{"海边的日落", []string{"日落"}, 32, "海边的<mark>日落</mark>"},
+		{"0123456789beach0123456789", []string{"beach"}, 10, "…789<mark>beach</mark>01…"},
+		{"0123456789beach", []string{"beach"}, 6, "…89<mark>beach</mark>"},
+		{"abcabc", []string{"ab", "abc"}, 32, "<mark>abc</mark><mark>abc</mark>"},
+		{"mountain", []string{"beach"}, 32, ""},
+		{"mountain", nil, 32, ""},
+		// 内容和关键字都需要转义
+		{"<img src=x onerror=alert(1)> beach", []string{"beach"}, 64, "&lt;img src=x onerror=alert(1)&gt; <mark>beach</mark>"},
+		{`a&b "<beach>"`, []string{"<beach"}, 32, "a&amp;b &#34;<mark>&lt;beach</mark>&gt;&#34;"},
+	}
+	for _, scenario := range scenarios {
+		s.Equal(scenario.expected, search.Snippet(scenario.text, scenario.keywords, scenario.size), scenario.text)
+	}
+}
+
+func (s *SnippetTestSuite) TestKeywords() {
+	s.Equal([]string{"sunset", "beach"}, search.Keywords("  sunset\tbeach "))
+	s.Len(search.Keywords(" "), 0)
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"gorm.io/gorm"
+)
+
+const ALBUM_DEFAULT_PAGE_SIZE = 20
+
+type AlbumService interface {
+	GetAlbumById(uint) (*dto.AlbumDto, error)
+	AlbumPage(dto.PageParam[struct{}]) (*dto.PageResult[dto.AlbumDto], error)
+	CreateAlbum(dto.CreateAlbumParam) (*dto.AlbumDto, error)
+	UpdateAlbum(dto.AlbumParam) (*dto.AlbumDto, error)
+	DeleteAlbum(uint) error
+	AddPhotos(uint, []uint) error
+	RemovePhotos(uint, []uint) error
+	SortPhotos(uint, []uint) error
+}
+
+type albumService struct {
+	logger.AppLogger
+	ctx *application.AppContext
+	db  *database.SqliteDB
+}
+
+func NewAlbumService(ctx *application.AppContext, db *database.SqliteDB) AlbumService {
+	return &albumService{ctx: ctx, db: db, AppLogger: *ctx.GetLogger()}
+}
+
+func (as *albumService) takeAlbum(tx *gorm.DB, id uint) (*model.Album, error) {
+	var album model.Album
+	if result := tx.First(&album, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, application.ErrDataNotFound
+		}
+		return nil, result.Error
+	}
+	return &album, nil
+}
+
+// albumPhotoQuery 查询相册内未删除的图片
+func (as *albumService) albumPhotoQuery(albumID uint) *gorm.DB {
+	return as.db.Model(&model.Photo{}).
+		Joins("JOIN album_photos ON album_photos.photo_id = photos.id").
+		Where("album_photos.album_id = ?", albumID)
+}
+
+// albumStat 相册内未删除的图片的统计
+type albumStat struct {
+	AlbumID      uint
+	PhotoCount   int64
+	FirstPhotoID uint
+	StartPhotoID uint
+	EndPhotoID   uint
+	// 设置的封面图片在相册内并且不在回收站内
+	CoverExists bool
+}
+
+// albumStats 一次查询多个相册的图片数量、默认封面和拍摄时间范围的图片
+func (as *albumService) albumStats(albumIDs []uint) (map[uint]albumStat, error) {
+	photos := as.db.Model(&model.Photo{}).
+		Select(
+			"album_photos.album_id, photos.id, photos.photo_date, album_photos.sort_order",
+			"COALESCE(photos.id = albums.cover_photo_id, 0) AS is_cover",
+		).
+		Joins("JOIN album_photos ON album_photos.photo_id = photos.id").
+		Joins("JOIN albums ON albums.id = album_photos.album_id").
+		Where("album_photos.album_id IN ?", albumIDs)
+
+	// 同一个相册的每一行窗口函数的结果都相同，去重后每个相册一行
+	var stats []albumStat
+	result := as.db.Table("(?) AS t", photos).
+		Distinct(
+			"album_id",
+			"COUNT(*) OVER (PARTITION BY album_id) AS photo_count",
+			"FIRST_VALUE(id) OVER (PARTITION BY album_id ORDER BY sort_order) AS first_photo_id",
+			"FIRST_VALUE(id) OVER (PARTITION BY album_id ORDER BY photo_date, id) AS start_photo_id",
+			"FIRST_VALUE(id) OVER (PARTITION BY album_id ORDER BY photo_date DESC, id DESC) AS end_photo_id",
+			"MAX(is_cover) OVER (PARTITION BY album_id) AS cover_exists",
+		).
+		Find(&stats)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	statMap := make(map[uint]albumStat, len(stats))
+	for _, stat := range stats {
+		statMap[stat.AlbumID] = stat
+	}
+	return statMap, nil
+}
+
+// toDtoList 没有设置封面和时间范围时，使用相册内的图片补充，封面图片在回收站内时使用默认封面
+func (as *albumService) toDtoList(albums []model.Album) ([]dto.AlbumDto, error) {
+	albumIDs := make([]uint, 0, len(albums))
+	for _, album := range albums {
+		albumIDs = append(albumIDs, album.ID)
+	}
+	statMap, err := as.albumStats(albumIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 拍摄时间范围的图片
+	dateIDs := make([]uint, 0, len(statMap)*2)
+	for _, stat := range statMap {
+		dateIDs = append(dateIDs, stat.StartPhotoID, stat.EndPhotoID)
+	}
+	photoDates := make(map[uint]time.Time, len(dateIDs))
+	if len(dateIDs) > 0 {
+		var photos []model.Photo
+		if result := as.db.Select("id", "photo_date").Where("id IN ?", dateIDs).Find(&photos); result.Error != nil {
+			return nil, result.Error
+		}
+		for _, photo := range photos {
+			photoDates[photo.ID] = photo.PhotoDate
+		}
+	}
+
+	albumDtoList := make([]dto.AlbumDto, 0, len(albums))
+	for _, album := range albums {
+		albumDto := dto.AlbumDto{}
+		albumDto.Update(&album)
+		stat := statMap[album.ID]
+		albumDto.PhotoCount = stat.PhotoCount
+		if !stat.CoverExists {
+			albumDto.CoverPhotoID = nil
+		}
+		if stat.PhotoCount > 0 {
+			if albumDto.CoverPhotoID == nil {
+				albumDto.CoverPhotoID = &stat.FirstPhotoID
+			}
+			if albumDto.StartDate == nil {
+				startDate := photoDates[stat.StartPhotoID]
+				albumDto.StartDate = &startDate
+			}
+			if albumDto.EndDate == nil {
+				endDate := photoDates[stat.EndPhotoID]
+				albumDto.EndDate = &endDate
+			}
+		}
+		albumDtoList = append(albumDtoList, albumDto)
+	}
+	return albumDtoList, nil
+}
+
+func (as *albumService) toDto(album *model.Album) (*dto.AlbumDto, error) {
+	albumDtoList, err := as.toDtoList([]model.Album{*album})
+	if err != nil {
+		return nil, err
+	}
+	return &albumDtoList[0], nil
+}
+
+func (as *albumService) GetAlbumById(id uint) (*dto.AlbumDto, error) {
+	album, err := as.takeAlbum(as.db.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	return as.toDto(album)
+}
+
+func (as *albumService) AlbumPage(pageParam dto.PageParam[struct{}]) (*dto.PageResult[dto.AlbumDto], error) {
+	if pageParam.PageNum <= 0 {
+		pageParam.PageNum = 1
+	}
+	if pageParam.PageSize <= 0 {
+		pageParam.PageSize = ALBUM_DEFAULT_PAGE_SIZE
+	}
+
+	var (
+		albums []model.Album
+		total  int64
+	)
+	if result := as.db.Model(&model.Album{}).Count(&total); result.Error != nil {
+		return nil, result.Error
+	}
+	result := as.db.
+		Order("id desc").
+		Offset((pageParam.PageNum - 1) * pageParam.PageSize).
+		Limit(pageParam.PageSize).
+		Find(&albums)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(albums) == 0 {
+		return nil, application.ErrDataNotFound
+	}
+
+	albumDtoList, err := as.toDtoList(albums)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PageResult[dto.AlbumDto]{
+		List:     albumDtoList,
+		PageNum:  pageParam.PageNum,
+		PageSize: pageParam.PageSize,
+		Total:    total,
+	}, nil
+}
+
+func (as *albumService) checkDateRange(album *model.Album) error {
+	if album.StartDate != nil && album.EndDate != nil && album.StartDate.After(*album.EndDate) {
+		return application.NewAppError(http.StatusBadRequest, "开始时间不能晚于结束时间")
+	}
+	return nil
+}
+
+func (as *albumService) CreateAlbum(param dto.CreateAlbumParam) (*dto.AlbumDto, error) {
+	album := param.ToModel()
+	if err := as.checkDateRange(album); err != nil {
+		return nil, err
+	}
+	if result := as.db.Create(album); result.Error != nil {
+		return nil, result.Error
+	}
+	return as.toDto(album)
+}
+
+func (as *albumService) UpdateAlbum(param dto.AlbumParam) (*dto.AlbumDto, error) {
+	album, err := as.takeAlbum(as.db.DB, param.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.Name != "" {
+		album.Name = param.Name
+	}
+	if param.Desc != nil {
+		album.Desc = *param.Desc
+	}
+	if param.StartDate != nil {
+		album.StartDate = param.StartDate
+	}
+	if param.EndDate != nil {
+		album.EndDate = param.EndDate
+	}
+	if err := as.checkDateRange(album); err != nil {
+		return nil, err
+	}
+	if param.CoverPhotoID != nil {
+		if *param.CoverPhotoID == 0 {
+			album.CoverPhotoID = nil
+		} else {
+			// 封面只能使用相册内的图片
+			var count int64
+			if result := as.albumPhotoQuery(album.ID).Where("photos.id = ?", *param.CoverPhotoID).Count(&count); result.Error != nil {
+				return nil, result.Error
+			}
+			if count == 0 {
+				return nil, application.NewAppError(http.StatusBadRequest, "封面图片不在相册内")
+			}
+			album.CoverPhotoID = param.CoverPhotoID
+		}
+	}
+
+	if result := as.db.Save(album); result.Error != nil {
+		return nil, result.Error
+	}
+	return as.toDto(album)
+}
+
+// DeleteAlbum 删除相册和相册内图片的关联，不删除图片
+func (as *albumService) DeleteAlbum(id uint) error {
+	return as.db.Transaction(func(tx *gorm.DB) error {
+		album, err := as.takeAlbum(tx, id)
+		if err != nil {
+			return err
+		}
+		if result := tx.Where("album_id = ?", id).Delete(&model.AlbumPhoto{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(album).Error
+	})
+}
+
+// AddPhotos 将图片添加到相册末尾，已经在相册内的图片不会重复添加
+func (as *albumService) AddPhotos(albumID uint, photoIDs []uint) error {
+	return as.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := as.takeAlbum(tx, albumID); err != nil {
+			return err
+		}
+
+		photoIDs = uniqueIds(photoIDs)
+		var count int64
+		if result := tx.Model(&model.Photo{}).Where("id IN ?", photoIDs).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count != int64(len(photoIDs)) {
+			return application.NewAppError(http.StatusBadRequest, "添加的图片不存在")
+		}
+		return AppendAlbumPhotos(tx, albumID, photoIDs)
+	})
+}
+
+// AppendAlbumPhotos 将已经存在的图片添加到相册的末尾，已经在相册内的图片不做处理
+func AppendAlbumPhotos(tx *gorm.DB, albumID uint, photoIDs []uint) error {
+	var existIDs []uint
+	if result := tx.Model(&model.AlbumPhoto{}).Where("album_id = ?", albumID).Pluck("photo_id", &existIDs); result.Error != nil {
+		return result.Error
+	}
+	var maxOrder int64
+	if result := tx.Model(&model.AlbumPhoto{}).
+		Where("album_id = ?", albumID).
+		Select("COALESCE(MAX(sort_order), 0)").
+		Scan(&maxOrder); result.Error != nil {
+		return result.Error
+	}
+
+	exists := make(map[uint]bool, len(existIDs))
+	for _, id := range existIDs {
+		exists[id] = true
+	}
+	albumPhotos := make([]model.AlbumPhoto, 0, len(photoIDs))
+	for _, photoID := range photoIDs {
+		if exists[photoID] {
+			continue
+		}
+		maxOrder++
+		albumPhotos = append(albumPhotos, model.AlbumPhoto{AlbumID: albumID, PhotoID: photoID, SortOrder: maxOrder})
+	}
+	if len(albumPhotos) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&albumPhotos, SQL_INSERT_BATCH_SIZE).Error
+}
+
+// RemovePhotos 从相册内移除图片，移除封面时使用默认封面
+func (as *albumService) RemovePhotos(albumID uint, photoIDs []uint) error {
+	return as.db.Transaction(func(tx *gorm.DB) error {
+		album, err := as.takeAlbum(tx, albumID)
+		if err != nil {
+			return err
+		}
+		if result := tx.Where("album_id = ? AND photo_id IN ?", albumID, photoIDs).Delete(&model.AlbumPhoto{}); result.Error != nil {
+			return result.Error
+		}
+		if album.CoverPhotoID != nil && slices.Contains(photoIDs, *album.CoverPhotoID) {
+			return tx.Model(album).Update("cover_photo_id", nil).Error
+		}
+		return nil
+	})
+}
+
+// SortPhotos 按照 photoIDs 的顺序排列图片，没有传入的图片保持原来的顺序排在后面
+func (as *albumService) SortPhotos(albumID uint, photoIDs []uint) error {
+	return as.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := as.takeAlbum(tx, albumID); err != nil {
+			return err
+		}
+
+		var existIDs []uint
+		result := tx.Model(&model.AlbumPhoto{}).
+			Where("album_id = ?", albumID).
+			Order("sort_order").
+			Pluck("photo_id", &existIDs)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		photoIDs = uniqueIds(photoIDs)
+		for _, photoID := range photoIDs {
+			if !slices.Contains(existIDs, photoID) {
+				return application.NewAppError(http.StatusBadRequest, "图片 %d 不在相册内", photoID)
+			}
+		}
+		for _, photoID := range existIDs {
+			if !slices.Contains(photoIDs, photoID) {
+				photoIDs = append(photoIDs, photoID)
+			}
+		}
+
+		for i, photoID := range photoIDs {
+			result := tx.Model(&model.AlbumPhoto{}).
+				Where("album_id = ? AND photo_id = ?", albumID, photoID).
+				Update("sort_order", i+1)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+}
+
+func uniqueIds(ids []uint) []uint {
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
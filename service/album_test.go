@@ -0,0 +1,282 @@
+package service_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/stretchr/testify/suite"
+)
+
+type AlbumServiceSuite struct {
+	suite.Suite
+	photoServ service.PhotoService
+	trashServ service.TrashService
+	serv      service.AlbumService
+	db        *database.SqliteDB
+	config    *config.Config
+}
+
+func TestAlbumServiceSuite(t *testing.T) {
+	suite.Run(t, &AlbumServiceSuite{})
+}
+
+func (s *AlbumServiceSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	db, err := appgen.GenDatabase(appComponents)
+	s.Nil(err)
+
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.Nil(migrator.InitOrMigrate())
+
+	s.photoServ = service.NewPhotoService(ctx, db)
+	s.trashServ = service.NewTrashService(ctx, db)
+	s.serv = service.NewAlbumService(ctx, db)
+	s.db = db
+	s.config = appComponents.Config
+}
+
+func (s *AlbumServiceSuite) TearDownSuite() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+func (s *AlbumServiceSuite) SetupTest() {
+	s.db.Migrator().CreateTable(&model.Photo{}, &model.Album{}, &model.AlbumPhoto{})
+}
+
+func (s *AlbumServiceSuite) TearDownTest() {
+	s.db.Migrator().DropTable(&model.Photo{}, &model.Album{}, &model.AlbumPhoto{})
+}
+
+// createPhotos 上传 n 张拍摄时间间隔一天的图片
+func (s *AlbumServiceSuite) createPhotos(n int) []uint {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	params := make([]dto.CreatePhotoParam, 0, n)
+	for i := range n {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf)
+		s.Nil(err)
+		param := dto.CreatePhotoParam{UploadID: uint(i), PhotoDate: start.AddDate(0, 0, i)}
+		param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "album")
+		params = append(params, param)
+	}
+	s.Len(s.photoServ.CreatePhoto(params), 0)
+
+	var ids []uint
+	s.Nil(s.db.Model(&model.Photo{}).Order("photo_date").Pluck("id", &ids).Error)
+	return ids
+}
+
+func (s *AlbumServiceSuite) albumPhotoIds(albumID uint) []uint {
+	result, err := s.photoServ.PhotoPage(dto.PageParam[dto.PhotoPageParam]{
+		Params:   dto.PhotoPageParam{AlbumID: albumID},
+		PageNum:  1,
+		PageSize: 100,
+	})
+	if err == application.ErrDataNotFound {
+		return nil
+	}
+	s.Nil(err)
+	ids := make([]uint, 0, len(result.List))
+	for _, photoDto := range result.List {
+		ids = append(ids, photoDto.ID)
+	}
+	return ids
+}
+
+func (s *AlbumServiceSuite) TestCreateAlbum() {
+	albumDto, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "travel", Desc: "2024"})
+	s.Nil(err)
+	s.Equal("travel", albumDto.Name)
+	s.Equal(int64(0), albumDto.PhotoCount)
+	s.Nil(albumDto.CoverPhotoID)
+	s.Nil(albumDto.StartDate)
+
+	start := time.Now()
+	end := start.Add(-time.Hour)
+	_, err = s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "invalid", StartDate: &start, EndDate: &end})
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(http.StatusBadRequest, appErr.Code)
+
+	result, err := s.serv.AlbumPage(dto.PageParam[struct{}]{})
+	s.Nil(err)
+	s.Equal(int64(1), result.Total)
+	s.Equal(albumDto.ID, result.List[0].ID)
+}
+
+func (s *AlbumServiceSuite) TestAddPhotos() {
+	ids := s.createPhotos(3)
+	albumDto, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "album"})
+	s.Nil(err)
+
+	s.Nil(s.serv.AddPhotos(albumDto.ID, []uint{ids[2], ids[0]}))
+	// 已经在相册内的图片不会重复添加
+	s.Nil(s.serv.AddPhotos(albumDto.ID, []uint{ids[0], ids[1]}))
+	s.Equal([]uint{ids[2], ids[0], ids[1]}, s.albumPhotoIds(albumDto.ID))
+
+	albumDto, err = s.serv.GetAlbumById(albumDto.ID)
+	s.Nil(err)
+	s.Equal(int64(3), albumDto.PhotoCount)
+	s.Equal(ids[2], *albumDto.CoverPhotoID)
+	s.Equal(2024, albumDto.StartDate.Year())
+	s.Equal(1, albumDto.StartDate.Day())
+	s.Equal(3, albumDto.EndDate.Day())
+
+	appErr, ok := s.serv.AddPhotos(albumDto.ID, []uint{ids[0] + 100}).(*application.AppError)
+	s.True(ok)
+	s.Equal(http.StatusBadRequest, appErr.Code)
+	s.Equal(application.ErrDataNotFound, s.serv.AddPhotos(albumDto.ID+1, ids))
+}
+
+func (s *AlbumServiceSuite) TestSortPhotos() {
+	ids := s.createPhotos(3)
+	albumDto, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "album"})
+	s.Nil(err)
+	s.Nil(s.serv.AddPhotos(albumDto.ID, ids))
+
+	s.Nil(s.serv.SortPhotos(albumDto.ID, []uint{ids[2], ids[1], ids[0]}))
+	s.Equal([]uint{ids[2], ids[1], ids[0]}, s.albumPhotoIds(albumDto.ID))
+
+	// 没有传入的图片保持原来的顺序
+	s.Nil(s.serv.SortPhotos(albumDto.ID, []uint{ids[0]}))
+	s.Equal([]uint{ids[0], ids[2], ids[1]}, s.albumPhotoIds(albumDto.ID))
+
+	s.Nil(s.serv.RemovePhotos(albumDto.ID, []uint{ids[2]}))
+	appErr, ok := s.serv.SortPhotos(albumDto.ID, []uint{ids[2]}).(*application.AppError)
+	s.True(ok)
+	s.Equal(http.StatusBadRequest, appErr.Code)
+}
+
+func (s *AlbumServiceSuite) TestUpdateCover() {
+	ids := s.createPhotos(3)
+	albumDto, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "album"})
+	s.Nil(err)
+	s.Nil(s.serv.AddPhotos(albumDto.ID, ids[:2]))
+
+	desc := "desc"
+	albumDto, err = s.serv.UpdateAlbum(dto.AlbumParam{ID: albumDto.ID, Desc: &desc, CoverPhotoID: &ids[1]})
+	s.Nil(err)
+	s.Equal("album", albumDto.Name)
+	s.Equal(desc, albumDto.Desc)
+	s.Equal(ids[1], *albumDto.CoverPhotoID)
+
+	// 封面只能使用相册内的图片
+	_, err = s.serv.UpdateAlbum(dto.AlbumParam{ID: albumDto.ID, CoverPhotoID: &ids[2]})
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(http.StatusBadRequest, appErr.Code)
+
+	// 移除封面后使用第一张图片
+	s.Nil(s.serv.RemovePhotos(albumDto.ID, []uint{ids[1]}))
+	albumDto, err = s.serv.GetAlbumById(albumDto.ID)
+	s.Nil(err)
+	s.Equal(ids[0], *albumDto.CoverPhotoID)
+	s.Equal(int64(1), albumDto.PhotoCount)
+}
+
+func (s *AlbumServiceSuite) TestDeletePhotoInAlbum() {
+	ids := s.createPhotos(2)
+	albumDto, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "album"})
+	s.Nil(err)
+	s.Nil(s.serv.AddPhotos(albumDto.ID, ids))
+	_, err = s.serv.UpdateAlbum(dto.AlbumParam{ID: albumDto.ID, CoverPhotoID: &ids[1]})
+	s.Nil(err)
+
+	// 回收站内的图片不显示在相册内，恢复后重新显示
+	s.Nil(s.photoServ.DeletePhoto(ids[1]))
+	s.Equal([]uint{ids[0]}, s.albumPhotoIds(albumDto.ID))
+	// 封面在回收站内时使用默认封面
+	albumDto, err = s.serv.GetAlbumById(albumDto.ID)
+	s.Nil(err)
+	s.Equal(ids[0], *albumDto.CoverPhotoID)
+	s.Equal(int64(1), albumDto.PhotoCount)
+	_, err = s.trashServ.RestorePhoto(ids[1])
+	s.Nil(err)
+	s.Equal(ids, s.albumPhotoIds(albumDto.ID))
+	albumDto, err = s.serv.GetAlbumById(albumDto.ID)
+	s.Nil(err)
+	s.Equal(ids[1], *albumDto.CoverPhotoID)
+
+	// 彻底删除后移出相册
+	s.Nil(s.photoServ.DeletePhoto(ids[1]))
+	s.Nil(s.trashServ.DeletePhoto(ids[1]))
+	albumDto, err = s.serv.GetAlbumById(albumDto.ID)
+	s.Nil(err)
+	s.Equal(ids[0], *albumDto.CoverPhotoID)
+	var count int64
+	s.db.Model(&model.AlbumPhoto{}).Where("photo_id = ?", ids[1]).Count(&count)
+	s.Equal(int64(0), count)
+}
+
+func (s *AlbumServiceSuite) TestAlbumPage() {
+	ids := s.createPhotos(3)
+	empty, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "empty"})
+	s.Nil(err)
+	first, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "first"})
+	s.Nil(err)
+	s.Nil(s.serv.AddPhotos(first.ID, []uint{ids[1], ids[0]}))
+	second, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "second"})
+	s.Nil(err)
+	s.Nil(s.serv.AddPhotos(second.ID, ids))
+	_, err = s.serv.UpdateAlbum(dto.AlbumParam{ID: second.ID, CoverPhotoID: &ids[2]})
+	s.Nil(err)
+	s.Nil(s.photoServ.DeletePhoto(ids[2]))
+
+	result, err := s.serv.AlbumPage(dto.PageParam[struct{}]{})
+	s.Nil(err)
+	s.Len(result.List, 3)
+	albums := map[uint]dto.AlbumDto{}
+	for _, albumDto := range result.List {
+		albums[albumDto.ID] = albumDto
+	}
+
+	s.Equal(int64(0), albums[empty.ID].PhotoCount)
+	s.Nil(albums[empty.ID].CoverPhotoID)
+	s.Nil(albums[empty.ID].StartDate)
+
+	s.Equal(int64(2), albums[first.ID].PhotoCount)
+	s.Equal(ids[1], *albums[first.ID].CoverPhotoID)
+	s.Equal(1, albums[first.ID].StartDate.Day())
+	s.Equal(2, albums[first.ID].EndDate.Day())
+
+	// 封面在回收站内
+	s.Equal(int64(2), albums[second.ID].PhotoCount)
+	s.Equal(ids[0], *albums[second.ID].CoverPhotoID)
+	s.Equal(2, albums[second.ID].EndDate.Day())
+}
+
+func (s *AlbumServiceSuite) TestDeleteAlbum() {
+	ids := s.createPhotos(2)
+	albumDto, err := s.serv.CreateAlbum(dto.CreateAlbumParam{Name: "album"})
+	s.Nil(err)
+	s.Nil(s.serv.AddPhotos(albumDto.ID, ids))
+
+	s.Nil(s.serv.DeleteAlbum(albumDto.ID))
+	_, err = s.serv.GetAlbumById(albumDto.ID)
+	s.Equal(application.ErrDataNotFound, err)
+	s.Equal(application.ErrDataNotFound, s.serv.DeleteAlbum(albumDto.ID))
+
+	// 不删除相册内的图片
+	_, err = s.photoServ.GetPhotoById(ids[0])
+	s.Nil(err)
+	var count int64
+	s.db.Model(&model.AlbumPhoto{}).Count(&count)
+	s.Equal(int64(0), count)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"gorm.io/gorm"
+)
+
+// DateShiftService 批量修改拍摄时间，每次修改保存修改前后的时间，可以撤销
+type DateShiftService interface {
+	ShiftDates(dto.DateShiftParam) (*dto.DateShiftDto, error)
+	GetDateShift(uint) (*dto.DateShiftDto, error)
+	UndoDateShift(uint) (*dto.DateShiftDto, error)
+}
+
+type dateShiftService struct {
+	logger.AppLogger
+	ctx *application.AppContext
+	db  *database.SqliteDB
+}
+
+func NewDateShiftService(ctx *application.AppContext, db *database.SqliteDB) DateShiftService {
+	return &dateShiftService{ctx: ctx, db: db, AppLogger: *ctx.GetLogger()}
+}
+
+// DateShifter 拍摄时间的修改方式
+type DateShifter struct {
+	Description string
+	Apply       func(time.Time) time.Time
+}
+
+// ParseDateShifter 按偏移或者时区转换修改拍摄时间，只能使用一种方式
+func ParseDateShifter(offset string, fromZone string, toZone string) (*DateShifter, error) {
+	if (offset == "") == (fromZone == "" && toZone == "") {
+		return nil, application.NewAppError(http.StatusBadRequest, "offset 和 fromZone、toZone 需要填写其中一种")
+	}
+	if offset != "" {
+		duration, err := time.ParseDuration(offset)
+		if err != nil || duration == 0 {
+			return nil, application.NewAppError(http.StatusBadRequest, "offset [ %s ] 格式错误，如 1h30m、-24h", offset)
+		}
+		return &DateShifter{
+			Description: fmt.Sprintf("偏移 %s", duration),
+			Apply:       func(t time.Time) time.Time { return t.Add(duration) },
+		}, nil
+	}
+
+	from, err := time.LoadLocation(fromZone)
+	if err != nil {
+		return nil, application.NewAppError(http.StatusBadRequest, "fromZone [ %s ] 不是有效的时区", fromZone)
+	}
+	to, err := time.LoadLocation(toZone)
+	if err != nil {
+		return nil, application.NewAppError(http.StatusBadRequest, "toZone [ %s ] 不是有效的时区", toZone)
+	}
+	return &DateShifter{
+		Description: fmt.Sprintf("时区 %s -> %s", from, to),
+		// 拍摄时间的数字按 from 时区解释，转换为 to 时区的数字，保存时仍然使用原来的时区
+		// 每个时间单独计算时差，处理夏令时
+		Apply: func(t time.Time) time.Time {
+			converted := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), from).In(to)
+			return time.Date(
+				converted.Year(), converted.Month(), converted.Day(),
+				converted.Hour(), converted.Minute(), converted.Second(), converted.Nanosecond(),
+				t.Location(),
+			)
+		},
+	}, nil
+}
+
+// ShiftPhotoDates 修改图片的拍摄时间并保存修改记录，dryRun 为 true 时只计算修改后的时间
+func ShiftPhotoDates(tx *gorm.DB, photoIDs []uint, shifter *DateShifter, dryRun bool) (*model.DateShift, error) {
+	dateShift := &model.DateShift{Description: shifter.Description, CreatedAt: time.Now()}
+	if len(photoIDs) == 0 {
+		return dateShift, nil
+	}
+	for chunk := range slices.Chunk(photoIDs, SQL_IN_CHUNK_SIZE) {
+		var photos []model.Photo
+		if result := tx.Select("id", "photo_date").Where("id IN ?", chunk).Order("id").Find(&photos); result.Error != nil {
+			return nil, result.Error
+		}
+		for _, photo := range photos {
+			dateShift.Items = append(dateShift.Items, model.DateShiftItem{
+				PhotoID: photo.ID,
+				OldDate: photo.PhotoDate,
+				NewDate: shifter.Apply(photo.PhotoDate),
+			})
+		}
+	}
+	if dryRun || len(dateShift.Items) == 0 {
+		return dateShift, nil
+	}
+
+	if result := tx.Omit("Items").Create(dateShift); result.Error != nil {
+		return nil, result.Error
+	}
+	itemPhotoIDs := make([]uint, 0, len(dateShift.Items))
+	for i := range dateShift.Items {
+		dateShift.Items[i].DateShiftID = dateShift.ID
+		itemPhotoIDs = append(itemPhotoIDs, dateShift.Items[i].PhotoID)
+	}
+	if result := tx.CreateInBatches(&dateShift.Items, SQL_INSERT_BATCH_SIZE); result.Error != nil {
+		return nil, result.Error
+	}
+	if err := updatePhotoDates(tx, dateShift.ID, itemPhotoIDs, "new_date"); err != nil {
+		return nil, err
+	}
+	return dateShift, nil
+}
+
+// updatePhotoDates 将图片的拍摄时间批量修改为修改记录内的时间，column 为 old_date 或者 new_date
+func updatePhotoDates(tx *gorm.DB, dateShiftID uint, photoIDs []uint, column string) error {
+	for chunk := range slices.Chunk(photoIDs, SQL_IN_CHUNK_SIZE) {
+		date := tx.Model(&model.DateShiftItem{}).
+			Select(column).
+			Where("date_shift_id = ? AND photo_id = photos.id", dateShiftID)
+		// 回收站内的图片也修改
+		result := tx.Unscoped().Model(&model.Photo{}).Where("id IN ?", chunk).Update("photo_date", date)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+func (ds *dateShiftService) ShiftDates(param dto.DateShiftParam) (*dto.DateShiftDto, error) {
+	if err := checkPhotoSelection(param.PhotoIDs, param.Query); err != nil {
+		return nil, err
+	}
+	shifter, err := ParseDateShifter(param.Offset, param.FromZone, param.ToZone)
+	if err != nil {
+		return nil, err
+	}
+
+	var dateShift *model.DateShift
+	err = ds.db.Transaction(func(tx *gorm.DB) error {
+		photoIDs, _, err := SelectPhotos(tx, param.PhotoIDs, param.Query)
+		if err != nil {
+			return err
+		}
+		dateShift, err = ShiftPhotoDates(tx, photoIDs, shifter, param.DryRun)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	dateShiftDto := &dto.DateShiftDto{}
+	dateShiftDto.Update(dateShift)
+	return dateShiftDto, nil
+}
+
+func (ds *dateShiftService) takeDateShift(tx *gorm.DB, id uint) (*model.DateShift, error) {
+	var dateShift model.DateShift
+	if result := tx.Preload("Items").First(&dateShift, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, application.ErrDataNotFound
+		}
+		return nil, result.Error
+	}
+	return &dateShift, nil
+}
+
+func (ds *dateShiftService) GetDateShift(id uint) (*dto.DateShiftDto, error) {
+	dateShift, err := ds.takeDateShift(ds.db.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	dateShiftDto := &dto.DateShiftDto{}
+	dateShiftDto.Update(dateShift)
+	return dateShiftDto, nil
+}
+
+// UndoDateShift 恢复修改前的拍摄时间，拍摄时间已经再次修改过的图片不做处理
+func (ds *dateShiftService) UndoDateShift(id uint) (*dto.DateShiftDto, error) {
+	dateShiftDto := &dto.DateShiftDto{}
+	err := ds.db.Transaction(func(tx *gorm.DB) error {
+		dateShift, err := ds.takeDateShift(tx, id)
+		if err != nil {
+			return err
+		}
+		if dateShift.UndoneAt != nil {
+			return application.NewAppError(http.StatusBadRequest, "修改记录 [ %d ] 已经撤销", id)
+		}
+		dateShiftDto.Update(dateShift)
+
+		// 回收站内的图片也恢复
+		photoIDs := make([]uint, 0, len(dateShift.Items))
+		for _, item := range dateShift.Items {
+			photoIDs = append(photoIDs, item.PhotoID)
+		}
+		photoDates := make(map[uint]time.Time, len(photoIDs))
+		for chunk := range slices.Chunk(photoIDs, SQL_IN_CHUNK_SIZE) {
+			var photos []model.Photo
+			if result := tx.Unscoped().Select("id", "photo_date").Where("id IN ?", chunk).Find(&photos); result.Error != nil {
+				return result.Error
+			}
+			for _, photo := range photos {
+				photoDates[photo.ID] = photo.PhotoDate
+			}
+		}
+		restoreIDs := make([]uint, 0, len(photoIDs))
+		for i, item := range dateShift.Items {
+			photoDate, found := photoDates[item.PhotoID]
+			if !found || !photoDate.Equal(item.NewDate) {
+				dateShiftDto.Items[i].Skipped = true
+				continue
+			}
+			restoreIDs = append(restoreIDs, item.PhotoID)
+		}
+		if err := updatePhotoDates(tx, dateShift.ID, restoreIDs, "old_date"); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// 只更新撤销时间，不重新保存 Items
+		if result := tx.Model(&model.DateShift{}).Where("id = ?", dateShift.ID).Update("undone_at", now); result.Error != nil {
+			return result.Error
+		}
+		dateShiftDto.UndoneAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dateShiftDto, nil
+}
@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/stretchr/testify/suite"
+)
+
+type DateShiftServiceSuite struct {
+	suite.Suite
+	photoServ service.PhotoService
+	serv      service.DateShiftService
+	db        *database.SqliteDB
+	config    *config.Config
+}
+
+func TestDateShiftServiceSuite(t *testing.T) {
+	suite.Run(t, &DateShiftServiceSuite{})
+}
+
+func (s *DateShiftServiceSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	db, err := appgen.GenDatabase(appComponents)
+	s.Nil(err)
+
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.Nil(migrator.InitOrMigrate())
+
+	s.photoServ = service.NewPhotoService(ctx, db)
+	s.serv = service.NewDateShiftService(ctx, db)
+	s.db = db
+	s.config = appComponents.Config
+}
+
+func (s *DateShiftServiceSuite) TearDownSuite() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+func (s *DateShiftServiceSuite) SetupTest() {
+	s.db.Migrator().CreateTable(&model.Photo{}, &model.DateShift{}, &model.DateShiftItem{})
+}
+
+func (s *DateShiftServiceSuite) TearDownTest() {
+	s.db.Migrator().DropTable(&model.Photo{}, &model.DateShift{}, &model.DateShiftItem{})
+}
+
+func (s *DateShiftServiceSuite) photoDate(id uint) time.Time {
+	var photo model.Photo
+	s.Nil(s.db.Unscoped().First(&photo, id).Error)
+	return photo.PhotoDate
+}
+
+func (s *DateShiftServiceSuite) TestShiftDates() {
+	date := time.Date(2024, 7, 1, 10, 0, 0, 0, time.Local)
+	photos := []model.Photo{{Desc: "trip", PhotoDate: date}, {Desc: "trip", PhotoDate: date.Add(time.Hour)}, {PhotoDate: date}}
+	s.Nil(s.db.Create(&photos).Error)
+
+	// 预览时不修改
+	preview, err := s.serv.ShiftDates(dto.DateShiftParam{Query: "trip", Offset: "-1h30m", DryRun: true})
+	s.Nil(err)
+	s.Equal(uint(0), preview.ID)
+	s.Len(preview.Items, 2)
+	s.Equal(photos[1].ID, preview.Items[1].PhotoID)
+	s.True(date.Add(time.Hour).Equal(preview.Items[1].OldDate))
+	s.True(date.Add(-30 * time.Minute).Equal(preview.Items[1].NewDate))
+	s.True(date.Equal(s.photoDate(photos[0].ID)))
+
+	result, err := s.serv.ShiftDates(dto.DateShiftParam{Query: "trip", Offset: "-1h30m"})
+	s.Nil(err)
+	s.NotZero(result.ID)
+	s.True(date.Add(-90 * time.Minute).Equal(s.photoDate(photos[0].ID)))
+	s.True(date.Equal(s.photoDate(photos[2].ID)))
+
+	saved, err := s.serv.GetDateShift(result.ID)
+	s.Nil(err)
+	s.Equal(result.Items, saved.Items)
+
+	// 撤销前再次修改的图片不撤销
+	s.Nil(s.db.Model(&photos[1]).Update("photo_date", date).Error)
+	undone, err := s.serv.UndoDateShift(result.ID)
+	s.Nil(err)
+	s.NotNil(undone.UndoneAt)
+	s.False(undone.Items[0].Skipped)
+	s.True(undone.Items[1].Skipped)
+	s.True(date.Equal(s.photoDate(photos[0].ID)))
+	s.True(date.Equal(s.photoDate(photos[1].ID)))
+
+	_, err = s.serv.UndoDateShift(result.ID)
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(400, appErr.Code)
+	_, err = s.serv.UndoDateShift(1000)
+	s.Equal(application.ErrDataNotFound, err)
+}
+
+func (s *DateShiftServiceSuite) TestShiftTimeZone() {
+	utc := time.FixedZone("", 0)
+	photos := []model.Photo{
+		{PhotoDate: time.Date(2024, 7, 1, 10, 0, 0, 0, utc)},
+		// 夏令时和冬令时的时差不同
+		{PhotoDate: time.Date(2024, 1, 1, 10, 0, 0, 0, utc)},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+
+	result, err := s.serv.ShiftDates(dto.DateShiftParam{
+		PhotoIDs: []uint{photos[0].ID, photos[1].ID},
+		FromZone: "UTC",
+		ToZone:   "Europe/Berlin",
+	})
+	s.Nil(err)
+	s.Equal(time.Date(2024, 7, 1, 12, 0, 0, 0, utc).Unix(), s.photoDate(photos[0].ID).Unix())
+	s.Equal(time.Date(2024, 1, 1, 11, 0, 0, 0, utc).Unix(), s.photoDate(photos[1].ID).Unix())
+	s.Len(result.Items, 2)
+
+	for _, param := range []dto.DateShiftParam{
+		{PhotoIDs: []uint{photos[0].ID}},
+		{PhotoIDs: []uint{photos[0].ID}, Offset: "1h", FromZone: "UTC", ToZone: "Asia/Tokyo"},
+		{PhotoIDs: []uint{photos[0].ID}, Offset: "1d"},
+		{PhotoIDs: []uint{photos[0].ID}, FromZone: "UTC", ToZone: "Mars/Olympus"},
+		{PhotoIDs: []uint{photos[0].ID, 1000}, Offset: "1h"},
+		{Offset: "1h"},
+	} {
+		_, err := s.serv.ShiftDates(param)
+		appErr, ok := err.(*application.AppError)
+		s.True(ok, param)
+		s.Equal(400, appErr.Code, param)
+	}
+}
+
+// 接近单次处理上限时分批保存修改记录和拍摄时间
+func (s *DateShiftServiceSuite) TestShiftDatesMaxPhotos() {
+	date := time.Date(2024, 7, 1, 10, 0, 0, 0, time.Local)
+	photos := make([]model.Photo, service.BATCH_MAX_PHOTOS)
+	for i := range photos {
+		photos[i].PhotoDate = date
+	}
+	s.Nil(s.db.CreateInBatches(&photos, 1000).Error)
+	photoIDs := make([]uint, 0, len(photos))
+	for _, photo := range photos {
+		photoIDs = append(photoIDs, photo.ID)
+	}
+
+	result, err := s.serv.ShiftDates(dto.DateShiftParam{PhotoIDs: photoIDs, Offset: "1h"})
+	s.Nil(err)
+	s.Len(result.Items, service.BATCH_MAX_PHOTOS)
+	s.True(date.Add(time.Hour).Equal(s.photoDate(photoIDs[0])))
+	s.True(date.Add(time.Hour).Equal(s.photoDate(photoIDs[len(photoIDs)-1])))
+
+	// 撤销前再次修改的图片不撤销
+	s.Nil(s.db.Model(&photos[0]).Update("photo_date", date.Add(2*time.Hour)).Error)
+	undone, err := s.serv.UndoDateShift(result.ID)
+	s.Nil(err)
+	s.True(undone.Items[0].Skipped)
+	s.False(undone.Items[len(undone.Items)-1].Skipped)
+	s.True(date.Add(2 * time.Hour).Equal(s.photoDate(photoIDs[0])))
+	var count int64
+	s.Nil(s.db.Model(&model.Photo{}).Where("photo_date = ?", date).Count(&count).Error)
+	s.Equal(int64(service.BATCH_MAX_PHOTOS-1), count)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/google/uuid"
+)
+
+const (
+	JOB_QUEUED  = "queued"
+	JOB_RUNNING = "running"
+	JOB_DONE    = "done"
+
+	JOB_FILE_PENDING   = "pending"
+	JOB_FILE_HASHING   = "hashing"
+	JOB_FILE_DUPLICATE = "duplicate"
+	JOB_FILE_SAVED     = "saved"
+	JOB_FILE_FAILED    = "failed"
+
+	// 推送的事件，job 和 done 的数据为整个任务，file 的数据为单个文件
+	JOB_EVENT_JOB  = "job"
+	JOB_EVENT_FILE = "file"
+	JOB_EVENT_DONE = "done"
+
+	// 等待执行的任务数量上限
+	JOB_QUEUE_SIZE = 100
+	// 完成的任务保留的时间
+	JOB_RETENTION = time.Hour
+)
+
+// JobService 异步导入图片，任务按提交的顺序逐个执行，只保存在内存内
+type JobService interface {
+	EnqueueImport([]dto.CreatePhotoParam) (*dto.JobDto, error)
+	GetJob(string) (*dto.JobDto, error)
+	SubscribeJob(string) (<-chan dto.JobEvent, func(), error)
+}
+
+type jobService struct {
+	logger.AppLogger
+	ctx       *application.AppContext
+	photoServ PhotoService
+
+	mu    sync.Mutex
+	jobs  map[string]*importJob
+	queue chan *importJob
+}
+
+func NewJobService(ctx *application.AppContext, photoServ PhotoService) JobService {
+	js := &jobService{
+		ctx:       ctx,
+		photoServ: photoServ,
+		jobs:      make(map[string]*importJob),
+		queue:     make(chan *importJob, JOB_QUEUE_SIZE),
+		AppLogger: *ctx.GetLogger(),
+	}
+	go js.run()
+	return js
+}
+
+type importJob struct {
+	mu     sync.Mutex
+	job    dto.JobDto
+	params []dto.CreatePhotoParam
+	// 请求结束后上传的文件会被删除，先复制到数据目录下
+	spooled []string
+	// uploadID 对应的文件下标
+	index       map[uint]int
+	subscribers []chan dto.JobEvent
+}
+
+// snapshot 复制任务当前的状态
+func (ij *importJob) snapshot() *dto.JobDto {
+	job := ij.job
+	job.Files = slices.Clone(ij.job.Files)
+	return &job
+}
+
+// publish 需要持有锁，订阅的 channel 可以放下所有事件，不会阻塞
+func (ij *importJob) publish(event dto.JobEvent) {
+	for _, ch := range ij.subscribers {
+		ch <- event
+	}
+}
+
+func (ij *importJob) setStatus(status string) {
+	ij.mu.Lock()
+	defer ij.mu.Unlock()
+	ij.job.Status = status
+	event := JOB_EVENT_JOB
+	if status == JOB_DONE {
+		now := time.Now()
+		ij.job.FinishedAt = &now
+		event = JOB_EVENT_DONE
+	}
+	ij.publish(dto.JobEvent{Event: event, Data: ij.snapshot()})
+	if status == JOB_DONE {
+		for _, ch := range ij.subscribers {
+			close(ch)
+		}
+		ij.subscribers = nil
+	}
+}
+
+func (ij *importJob) updateFile(file dto.JobFileDto) {
+	ij.mu.Lock()
+	defer ij.mu.Unlock()
+	i, ok := ij.index[file.UploadID]
+	if !ok {
+		return
+	}
+	current := &ij.job.Files[i]
+	current.Status = file.Status
+	current.Message = file.Message
+	current.PhotoID = file.PhotoID
+	ij.publish(dto.JobEvent{Event: JOB_EVENT_FILE, Data: *current})
+}
+
+func (ij *importJob) subscribe() (<-chan dto.JobEvent, func()) {
+	ij.mu.Lock()
+	defer ij.mu.Unlock()
+	// 每个文件最多推送两次，加上开始、运行和完成的事件
+	ch := make(chan dto.JobEvent, 2*len(ij.job.Files)+3)
+	ch <- dto.JobEvent{Event: JOB_EVENT_JOB, Data: ij.snapshot()}
+	if ij.job.Status == JOB_DONE {
+		ch <- dto.JobEvent{Event: JOB_EVENT_DONE, Data: ij.snapshot()}
+		close(ch)
+		return ch, func() {}
+	}
+	ij.subscribers = append(ij.subscribers, ch)
+	unsubscribe := func() {
+		ij.mu.Lock()
+		defer ij.mu.Unlock()
+		ij.subscribers = slices.DeleteFunc(ij.subscribers, func(c chan dto.JobEvent) bool { return c == ch })
+	}
+	return ch, unsubscribe
+}
+
+func (js *jobService) run() {
+	for job := range js.queue {
+		js.runJob(job)
+	}
+}
+
+func (js *jobService) runJob(job *importJob) {
+	job.setStatus(JOB_RUNNING)
+	js.photoServ.CreatePhotoWithProgress(job.params, job.updateFile)
+	js.removeSpooled(job.spooled)
+	job.setStatus(JOB_DONE)
+}
+
+func (js *jobService) removeSpooled(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			js.Error("remove spooled file %s error: %v", path, err)
+		}
+	}
+}
+
+// spool 把图片复制到数据目录下，返回文件路径
+func (js *jobService) spool(source imagemanager.ImageSource) (string, error) {
+	reader, err := source.GetReader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	file, err := os.CreateTemp(js.ctx.GetConfig().GetUploadsPath(), "job-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		js.removeSpooled([]string{file.Name()})
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func (js *jobService) EnqueueImport(params []dto.CreatePhotoParam) (*dto.JobDto, error) {
+	if len(params) == 0 {
+		return nil, application.NewAppError(http.StatusBadRequest, "没有需要导入的图片")
+	}
+	job := &importJob{
+		job: dto.JobDto{
+			ID:        uuid.NewString(),
+			Status:    JOB_QUEUED,
+			Files:     make([]dto.JobFileDto, 0, len(params)),
+			CreatedAt: time.Now(),
+		},
+		params: make([]dto.CreatePhotoParam, 0, len(params)),
+		index:  make(map[uint]int, len(params)),
+	}
+	for _, param := range params {
+		if _, ok := job.index[param.UploadID]; ok {
+			js.removeSpooled(job.spooled)
+			return nil, application.NewAppError(http.StatusBadRequest, "uploadId [ %d ] 重复", param.UploadID)
+		}
+		if param.ImageSource == nil {
+			js.removeSpooled(job.spooled)
+			return nil, application.NewAppError(http.StatusBadRequest, "uploadId [ %d ] 没有可以导入的文件", param.UploadID)
+		}
+		name := param.ImageSource.GetName()
+		// 远程文件在任务执行时读取，和同步导入一样只记录原图的 uri
+		if _, ok := param.ImageSource.(*imagemanager.RemoteUriSource); !ok {
+			path, err := js.spool(param.ImageSource)
+			if err != nil {
+				js.removeSpooled(job.spooled)
+				return nil, err
+			}
+			job.spooled = append(job.spooled, path)
+			param.ImageSource = imagemanager.NewFileSource(path, name)
+		}
+		job.index[param.UploadID] = len(job.params)
+		job.params = append(job.params, param)
+		job.job.Files = append(job.job.Files, dto.JobFileDto{UploadID: param.UploadID, Name: name, Status: JOB_FILE_PENDING})
+	}
+
+	// 加入队列后任务可能马上开始执行，先复制状态
+	snapshot := job.snapshot()
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.purgeFinished()
+	select {
+	case js.queue <- job:
+	default:
+		js.removeSpooled(job.spooled)
+		return nil, application.NewAppError(http.StatusServiceUnavailable, "等待执行的任务超过 %d 个", JOB_QUEUE_SIZE)
+	}
+	js.jobs[snapshot.ID] = job
+	return snapshot, nil
+}
+
+// purgeFinished 删除完成时间超过保留时间的任务，需要持有锁
+func (js *jobService) purgeFinished() {
+	for id, job := range js.jobs {
+		job.mu.Lock()
+		finishedAt := job.job.FinishedAt
+		job.mu.Unlock()
+		if finishedAt != nil && time.Since(*finishedAt) > JOB_RETENTION {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+func (js *jobService) getJob(id string) (*importJob, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.purgeFinished()
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil, application.ErrDataNotFound
+	}
+	return job, nil
+}
+
+func (js *jobService) GetJob(id string) (*dto.JobDto, error) {
+	job, err := js.getJob(id)
+	if err != nil {
+		return nil, err
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.snapshot(), nil
+}
+
+// SubscribeJob 订阅任务的进度，第一个事件为任务当前的状态，任务完成后关闭 channel
+func (js *jobService) SubscribeJob(id string) (<-chan dto.JobEvent, func(), error) {
+	job, err := js.getJob(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, unsubscribe := job.subscribe()
+	return events, unsubscribe, nil
+}
@@ -0,0 +1,195 @@
+package service_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobServiceSuite struct {
+	suite.Suite
+	serv   service.JobService
+	db     *database.SqliteDB
+	config *config.Config
+}
+
+func TestJobServiceSuite(t *testing.T) {
+	suite.Run(t, &JobServiceSuite{})
+}
+
+func (s *JobServiceSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	db, err := appgen.GenDatabase(appComponents)
+	s.Nil(err)
+
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.Nil(migrator.InitOrMigrate())
+
+	s.serv = service.NewJobService(ctx, service.NewPhotoService(ctx, db))
+	s.db = db
+	s.config = appComponents.Config
+}
+
+func (s *JobServiceSuite) TearDownSuite() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+func (s *JobServiceSuite) SetupTest() {
+	s.db.Migrator().CreateTable(&model.Photo{})
+	s.Nil(s.db.InitFullTextSearch())
+}
+
+func (s *JobServiceSuite) TearDownTest() {
+	s.db.Migrator().DropTable(&model.Photo{}, database.FTS_TABLE)
+}
+
+func (s *JobServiceSuite) genImage() []byte {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	return buf.Bytes()
+}
+
+func (s *JobServiceSuite) TestEnqueueImport() {
+	img1, img2 := s.genImage(), s.genImage()
+	params := []dto.CreatePhotoParam{
+		{UploadID: 1, ImageSource: imagemanager.NewReaderSource(bytes.NewReader(img1), "a.png")},
+		{UploadID: 2, ImageSource: imagemanager.NewReaderSource(bytes.NewReader(img2), "b.png")},
+		{UploadID: 3, ImageSource: imagemanager.NewReaderSource(bytes.NewReader(img1), "c.png")},
+	}
+	job, err := s.serv.EnqueueImport(params)
+	s.Nil(err)
+	s.Equal(service.JOB_QUEUED, job.Status)
+	s.Len(job.Files, 3)
+	for _, file := range job.Files {
+		s.Equal(service.JOB_FILE_PENDING, file.Status)
+	}
+
+	events, unsubscribe, err := s.serv.SubscribeJob(job.ID)
+	s.Nil(err)
+	defer unsubscribe()
+
+	// 第一个事件为任务当前的状态，最后一个为完成事件
+	var received []dto.JobEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	s.Equal(service.JOB_EVENT_JOB, received[0].Event)
+	last := received[len(received)-1]
+	s.Equal(service.JOB_EVENT_DONE, last.Event)
+	s.Equal(service.JOB_DONE, last.Data.(*dto.JobDto).Status)
+
+	// 每个文件的最后一个状态为完成的状态
+	fileStatus := map[uint]string{}
+	for _, event := range received {
+		if event.Event == service.JOB_EVENT_FILE {
+			file := event.Data.(dto.JobFileDto)
+			fileStatus[file.UploadID] = file.Status
+		}
+	}
+	for _, status := range fileStatus {
+		s.NotEqual(service.JOB_FILE_HASHING, status)
+	}
+
+	job, err = s.serv.GetJob(job.ID)
+	s.Nil(err)
+	s.Equal(service.JOB_DONE, job.Status)
+	s.NotNil(job.FinishedAt)
+	statusCount := map[string]int{}
+	for _, file := range job.Files {
+		statusCount[file.Status]++
+		if file.Status == service.JOB_FILE_SAVED {
+			s.NotZero(file.PhotoID)
+		}
+	}
+	s.Equal(map[string]int{service.JOB_FILE_SAVED: 2, service.JOB_FILE_DUPLICATE: 1}, statusCount)
+
+	var count int64
+	s.Nil(s.db.Model(&model.Photo{}).Count(&count).Error)
+	s.Equal(int64(2), count)
+
+	// 复制的文件已经删除
+	entries, err := os.ReadDir(s.config.GetUploadsPath())
+	s.Nil(err)
+	s.Empty(entries)
+
+	// 完成后订阅只返回当前状态和完成事件
+	events, _, err = s.serv.SubscribeJob(job.ID)
+	s.Nil(err)
+	received = received[:0]
+	for event := range events {
+		received = append(received, event)
+	}
+	s.Len(received, 2)
+	s.Equal(service.JOB_EVENT_DONE, received[1].Event)
+}
+
+func (s *JobServiceSuite) TestEnqueueImportRemote() {
+	// 远程文件不在加入队列时读取，读取失败时只有这个文件失败
+	source := imagemanager.NewRemoteUriSource("ftp://127.0.0.1:1/photos/a.png", imagemanager.NewRemoteOpener(nil))
+	job, err := s.serv.EnqueueImport([]dto.CreatePhotoParam{{UploadID: 1, ImageSource: source}})
+	s.Nil(err)
+	s.Equal("a.png", job.Files[0].Name)
+	entries, err := os.ReadDir(s.config.GetUploadsPath())
+	s.Nil(err)
+	s.Empty(entries)
+
+	events, unsubscribe, err := s.serv.SubscribeJob(job.ID)
+	s.Nil(err)
+	defer unsubscribe()
+	for range events {
+	}
+	job, err = s.serv.GetJob(job.ID)
+	s.Nil(err)
+	s.Equal(service.JOB_DONE, job.Status)
+	s.Equal(service.JOB_FILE_FAILED, job.Files[0].Status)
+}
+
+func (s *JobServiceSuite) TestEnqueueImportInvalid() {
+	source := func() imagemanager.ImageSource {
+		return imagemanager.NewReaderSource(bytes.NewReader([]byte("data")), "a.png")
+	}
+	scenarios := [][]dto.CreatePhotoParam{
+		{},
+		{{UploadID: 1, ImageSource: source()}, {UploadID: 1, ImageSource: source()}},
+		{{UploadID: 1, ImageSource: source()}, {UploadID: 2}},
+	}
+
+	for _, params := range scenarios {
+		_, err := s.serv.EnqueueImport(params)
+		var appError *application.AppError
+		s.True(errors.As(err, &appError))
+		s.Equal(http.StatusBadRequest, appError.Code)
+	}
+
+	// 失败时删除已经复制的文件
+	entries, err := os.ReadDir(s.config.GetUploadsPath())
+	s.Nil(err)
+	s.Empty(entries)
+}
+
+func (s *JobServiceSuite) TestGetJobNotFound() {
+	_, err := s.serv.GetJob("none")
+	s.Equal(application.ErrDataNotFound, err)
+	_, _, err = s.serv.SubscribeJob("none")
+	s.Equal(application.ErrDataNotFound, err)
+}
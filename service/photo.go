@@ -2,11 +2,16 @@ package service
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/follow1123/photos/application"
 	"github.com/follow1123/photos/database"
@@ -14,16 +19,30 @@ import (
 	"github.com/follow1123/photos/logger"
 	"github.com/follow1123/photos/model"
 	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/search"
 	"gorm.io/gorm"
 )
 
 type PhotoService interface {
 	GetPhotoById(uint) (*dto.PhotoDto, error)
 	PhotoPage(dto.PageParam[dto.PhotoPageParam]) (*dto.PageResult[dto.PhotoDto], error)
+	PhotoCursorPage(dto.CursorParam[dto.PhotoPageParam]) (*dto.CursorResult[dto.PhotoDto], error)
+	PhotoTimeline(dto.TimelineParam) ([]dto.TimelineBucket, error)
+	PhotoMemories(dto.MemoryParam) ([]dto.MemoryGroup, error)
 	CreatePhoto([]dto.CreatePhotoParam) []dto.CreatePhotoFailedResult
+	CreatePhotoWithProgress([]dto.CreatePhotoParam, PhotoProgress) []dto.CreatePhotoFailedResult
 	UpdatePhoto(dto.PhotoParam) (*dto.PhotoDto, error)
 	DeletePhoto(uint) error
+	SetFavorite(dto.PhotoFavoriteParam) error
+	SetRating(dto.PhotoRatingParam) error
+	BatchPhotos(dto.BatchParam) ([]dto.BatchResult, error)
 	GetPhotoFile(uint, bool) (io.ReadCloser, *imagemanager.ImageInfo, error)
+	GetPhotoRendition(uint, string) (io.ReadCloser, *imagemanager.ImageInfo, error)
+	RenderPhoto(uint, dto.RenderParam) (io.ReadCloser, *imagemanager.ImageInfo, error)
+	RenderETag(uint, dto.RenderParam) (string, error)
+	GeoPhotos(dto.GeoParam) (*dto.GeoResult, error)
+	DuplicatePhotos(dto.DuplicateParam) ([]dto.DuplicateCluster, error)
+	BackfillPerceptualHash() (int, error)
 }
 
 type photoService struct {
@@ -46,7 +65,11 @@ func (ps *photoService) GetPhotoById(id uint) (*dto.PhotoDto, error) {
 	}
 	photoDto := &dto.PhotoDto{}
 	photoDto.Update(&photo)
-	return photoDto, nil
+	photoDtoList := []dto.PhotoDto{*photoDto}
+	if err := FillPhotoTags(ps.db.DB, photoDtoList); err != nil {
+		return nil, err
+	}
+	return &photoDtoList[0], nil
 }
 
 func (ps *photoService) PhotoPage(pageParam dto.PageParam[dto.PhotoPageParam]) (*dto.PageResult[dto.PhotoDto], error) {
@@ -54,9 +77,9 @@ func (ps *photoService) PhotoPage(pageParam dto.PageParam[dto.PhotoPageParam]) (
 		photoDtoList []dto.PhotoDto
 		total        int64
 	)
-	query := ps.db.Model(&model.Photo{})
-	if pageParam.Params.Desc != "" {
-		query = query.Where("desc like ?", "%"+pageParam.Params.Desc+"%")
+	query, err := ps.filterPhotos(pageParam.Params)
+	if err != nil {
+		return nil, err
 	}
 
 	result := query.Count(&total)
@@ -64,7 +87,11 @@ func (ps *photoService) PhotoPage(pageParam dto.PageParam[dto.PhotoPageParam]) (
 		return nil, result.Error
 	}
 
-	result = query.Offset((pageParam.PageNum - 1) * pageParam.PageSize).Limit(pageParam.PageSize).Find(&photoDtoList)
+	result = query.
+		Order(ps.resolvePhotoSort(pageParam.Params).order(false)).
+		Offset((pageParam.PageNum - 1) * pageParam.PageSize).
+		Limit(pageParam.PageSize).
+		Find(&photoDtoList)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -72,6 +99,9 @@ func (ps *photoService) PhotoPage(pageParam dto.PageParam[dto.PhotoPageParam]) (
 	if len(photoDtoList) == 0 {
 		return nil, application.ErrDataNotFound
 	}
+	if err := ps.fillPhotoList(photoDtoList, pageParam.Params); err != nil {
+		return nil, err
+	}
 
 	return &dto.PageResult[dto.PhotoDto]{
 		List:     photoDtoList,
@@ -82,6 +112,142 @@ func (ps *photoService) PhotoPage(pageParam dto.PageParam[dto.PhotoPageParam]) (
 
 }
 
+// filterPhotos 根据图片列表的过滤条件生成查询，不包含排序
+func (ps *photoService) filterPhotos(params dto.PhotoPageParam) (*gorm.DB, error) {
+	query := ps.db.Model(&model.Photo{})
+	if params.Desc != "" {
+		query = query.Where("desc like ?", "%"+params.Desc+"%")
+	}
+	if params.AlbumID != 0 {
+		query = query.
+			Joins("JOIN album_photos ON album_photos.photo_id = photos.id").
+			Where("album_photos.album_id = ?", params.AlbumID)
+	}
+	if len(params.Tags) > 0 {
+		tags, err := NormalizeTags(params.Tags)
+		if err != nil {
+			return nil, err
+		}
+		tagQuery := ps.db.Model(&model.PhotoTag{}).
+			Select("photo_tags.photo_id").
+			Joins("JOIN tags ON tags.id = photo_tags.tag_id").
+			Where("tags.name IN ?", tags)
+		if params.TagMode == TAG_MODE_ALL {
+			tagQuery = tagQuery.Group("photo_tags.photo_id").Having("COUNT(*) = ?", len(tags))
+		}
+		query = query.Where("photos.id IN (?)", tagQuery)
+	}
+	if params.Favorite != nil {
+		query = query.Where("photos.favorite = ?", *params.Favorite)
+	}
+	if params.MinRating > 0 {
+		query = query.Where("photos.rating >= ?", params.MinRating)
+	}
+	if params.From != nil && params.To != nil && params.From.After(*params.To) {
+		return nil, application.NewAppError(http.StatusBadRequest, "from 不能晚于 to")
+	}
+	if params.From != nil {
+		query = query.Where("photos.photo_date >= ?", *params.From)
+	}
+	if params.To != nil {
+		// 包含 to 当天
+		query = query.Where("photos.photo_date < ?", params.To.AddDate(0, 0, 1))
+	}
+	if keywords := search.Keywords(params.Q); len(keywords) > 0 {
+		query = ps.applyKeywords(query, keywords)
+	}
+	if params.Query != "" {
+		var err error
+		if query, err = ApplySearchQuery(query, params.Query); err != nil {
+			return nil, err
+		}
+	}
+	return query, nil
+}
+
+const (
+	PHOTO_SORT_PHOTO_DATE = "photoDate"
+	PHOTO_SORT_CREATED_AT = "createdAt"
+	PHOTO_SORT_SIZE       = "size"
+	PHOTO_SORT_WIDTH      = "width"
+	PHOTO_SORT_RATING     = "rating"
+	PHOTO_SORT_FAVORITE   = "favorite"
+	SORT_ORDER_ASC        = "asc"
+	SORT_ORDER_DESC       = "desc"
+)
+
+// 排序字段对应的列，迁移时创建了 (列, id) 的索引
+var photoSortColumns = map[string]string{
+	PHOTO_SORT_PHOTO_DATE: "photos.photo_date",
+	PHOTO_SORT_CREATED_AT: "photos.created_at",
+	PHOTO_SORT_SIZE:       "photos.size",
+	PHOTO_SORT_WIDTH:      "photos.width",
+	PHOTO_SORT_RATING:     "photos.rating",
+	PHOTO_SORT_FAVORITE:   "photos.favorite",
+}
+
+// 没有指定排序字段时的排序方式
+const (
+	PHOTO_SORT_ALBUM     = "album"
+	PHOTO_SORT_RELEVANCE = "relevance"
+)
+
+// photoSort 图片列表的排序方式
+type photoSort struct {
+	// 排序方式的标识，用于检查 cursor 是否使用相同的排序
+	key string
+	// 排序的列，为空时不能使用 keyset 分页
+	column string
+	desc   bool
+	// 没有排序的列时使用的排序
+	orderBy string
+}
+
+// order 按排序列和 id 排序，保证顺序稳定，reverse 为 true 时反向排序
+func (sort photoSort) order(reverse bool) string {
+	if sort.column == "" {
+		return sort.orderBy
+	}
+	direction := SORT_ORDER_ASC
+	if sort.desc != reverse {
+		direction = SORT_ORDER_DESC
+	}
+	return fmt.Sprintf("%s %s, photos.id %s", sort.column, direction, direction)
+}
+
+// resolvePhotoSort 没有指定排序字段时，相册内的图片按相册的顺序，全文搜索按相关度，其他按拍摄时间倒序
+func (ps *photoService) resolvePhotoSort(params dto.PhotoPageParam) photoSort {
+	order := SORT_ORDER_DESC
+	if params.Order == SORT_ORDER_ASC {
+		order = SORT_ORDER_ASC
+	}
+	if _, ok := photoSortColumns[params.Sort]; !ok {
+		if params.AlbumID != 0 {
+			return photoSort{key: PHOTO_SORT_ALBUM, column: "album_photos.sort_order"}
+		}
+		if keywords := search.Keywords(params.Q); len(keywords) > 0 && ps.useFullTextSearch(keywords) {
+			return photoSort{key: PHOTO_SORT_RELEVANCE, orderBy: FTS_RANK_ORDER + ", photos.id"}
+		}
+		params.Sort = PHOTO_SORT_PHOTO_DATE
+	}
+	return photoSort{
+		key:    params.Sort + ":" + order,
+		column: photoSortColumns[params.Sort],
+		desc:   order == SORT_ORDER_DESC,
+	}
+}
+
+// fillPhotoList 补充图片列表的标签和搜索摘要
+func (ps *photoService) fillPhotoList(photoDtoList []dto.PhotoDto, params dto.PhotoPageParam) error {
+	if err := FillPhotoTags(ps.db.DB, photoDtoList); err != nil {
+		return err
+	}
+	if keywords := search.Keywords(params.Q); len(keywords) > 0 {
+		ps.fillSnippets(photoDtoList, keywords)
+	}
+	return nil
+}
+
 // func (ps *photoService) saveUploadPhoto(param *dto.CreatePhotoParam) error {
 // 	uploadMgr := ps.ctx.GetImageManager().NewUploadManager(
 // 		imagemanager.NewMultipartSource(param.FileHeader),
@@ -132,11 +298,37 @@ func (ps *photoService) PhotoPage(pageParam dto.PageParam[dto.PhotoPageParam]) (
 // 	return nil
 // }
 
+// preparedUpload 已经保存文件，等待保存数据的图片
+type preparedUpload struct {
+	uploadID  uint
+	photo     *model.Photo
+	uploadMgr *imagemanager.UploadImageManager
+}
+
+// PhotoProgress 创建图片时每个文件的状态变化，会在多个 worker 内同时调用
+type PhotoProgress func(dto.JobFileDto)
+
 func (ps *photoService) CreatePhoto(params []dto.CreatePhotoParam) []dto.CreatePhotoFailedResult {
-	var (
-		preparedPhotos = make([]model.Photo, 0, len(params))
-		failedResults  = make([]dto.CreatePhotoFailedResult, 0, len(params))
-	)
+	return ps.CreatePhotoWithProgress(params, nil)
+}
+
+func failedJobFile(failure dto.CreatePhotoFailedResult) dto.JobFileDto {
+	status := JOB_FILE_FAILED
+	if failure.Duplicate {
+		status = JOB_FILE_DUPLICATE
+	}
+	return dto.JobFileDto{UploadID: failure.UploadID, Status: status, Message: failure.Message}
+}
+
+func (ps *photoService) CreatePhotoWithProgress(
+	params []dto.CreatePhotoParam, progress PhotoProgress,
+) []dto.CreatePhotoFailedResult {
+	report := func(file dto.JobFileDto) {
+		if progress != nil {
+			progress(file)
+		}
+	}
+	failedResults := make([]dto.CreatePhotoFailedResult, 0, len(params))
 	var (
 		numWorkers  = 8
 		numJobs     = len(params)
@@ -149,94 +341,26 @@ func (ps *photoService) CreatePhoto(params []dto.CreatePhotoParam) []dto.CreateP
 	)
 
 	jobs := make(chan int, numJobs)
-	models := make(chan *model.Photo, numModels)
+	models := make(chan *preparedUpload, numModels)
 	failures := make(chan *dto.CreatePhotoFailedResult, numFailures)
 
+	// 所有 worker 共用内存预算，限制同时解码的图片
+	budget := imagemanager.NewMemoryBudget(ps.ctx.GetConfig().GetMemoryBudget())
+
 	for range numWorkers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				param := params[job]
-				uploadMgr := ps.ctx.GetImageManager().NewUploadManager(param.ImageSource)
-				var photo = model.Photo{Desc: param.Desc, PhotoDate: param.PhotoDate}
-				imageName := uploadMgr.GetImageName()
-				if !strings.Contains(photo.Desc, imageName) {
-					photo.Desc = ConcatDesc(photo.Desc, imageName)
-				}
-
-				sum, err := uploadMgr.GetHexSum()
-				if err != nil {
-					ps.Error("get hex sum error: %v", err)
-					failures <- &dto.CreatePhotoFailedResult{
-						UploadID: param.UploadID,
-						Message:  err.Error(),
-					}
-					continue
-				}
-
-				// 判断是否和其他正在上传的文件重复
-				_, loaded := sumMap.LoadOrStore(sum, true)
-				if loaded {
-					failures <- &dto.CreatePhotoFailedResult{
-						UploadID: param.UploadID,
-						Message:  BuildUploadDupMsg(imageName, photo.Desc),
-					}
-					continue
-				}
-
-				// 判断数据库内是否存在相同的图片
-				result := ps.db.Select("id").Where(&model.Photo{Sum: sum}).Take(&model.Photo{})
-				if result.Error == nil {
-					msg := "文件重复"
-					ps.Error(msg)
-					failures <- &dto.CreatePhotoFailedResult{
-						UploadID: param.UploadID,
-						Message:  msg,
-					}
-					continue
-				}
-				if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-					ps.Error("select same sum photo error: %v", err)
-					failures <- &dto.CreatePhotoFailedResult{
-						UploadID: param.UploadID,
-						Message:  result.Error.Error(),
-					}
-					continue
-				}
-
-				photo.Sum = sum
-
-				// 获取图片其他信息
-				imgInfo, err := uploadMgr.GetImageInfo()
-				if err != nil {
-					ps.Error("get image info error: %v", err)
-					failures <- &dto.CreatePhotoFailedResult{
-						UploadID: param.UploadID,
-						Message:  err.Error(),
-					}
-					continue
-				}
-
-				photo.Size = imgInfo.Size
-				photo.Format = imgInfo.Format
-				photo.Width = imgInfo.Width
-				photo.Height = imgInfo.Height
-
-				// 保存图片
-				uri, err := uploadMgr.Save()
-				if err != nil {
-					ps.Error("save image error: %v", err)
-					failures <- &dto.CreatePhotoFailedResult{
-						UploadID: param.UploadID,
-						Message:  err.Error(),
-					}
+				report(dto.JobFileDto{UploadID: params[job].UploadID, Status: JOB_FILE_HASHING})
+				upload, failure := ps.prepareUploadPhoto(params[job], &sumMap, budget)
+				if failure != nil {
+					report(failedJobFile(*failure))
+					failures <- failure
 					continue
 				}
-				photo.Uri = uri
-
 				// 加入待保存列表
-				models <- &photo
+				models <- upload
 			}
 		}()
 	}
@@ -246,22 +370,157 @@ func (ps *photoService) CreatePhoto(params []dto.CreatePhotoParam) []dto.CreateP
 	}
 	close(jobs)
 
-	wg.Wait()
-	close(models)
-	close(failures)
+	go func() {
+		wg.Wait()
+		close(models)
+		close(failures)
+	}()
+
+	// 准备好的图片马上逐个保存，不等待其他图片，减少其他任务上传相同文件时等待的时间
+	// 失败时删除这张图片新写入的文件，不影响其他图片
+	var saveFailedResults []dto.CreatePhotoFailedResult
+	contentLock := ps.ctx.GetImageManager().GetContentLock()
+	for upload := range models {
+		if result := ps.db.Create(upload.photo); result.Error != nil {
+			ps.Error("save photo error: %v", result.Error)
+			failure := dto.CreatePhotoFailedResult{
+				UploadID: upload.uploadID,
+				Message:  result.Error.Error(),
+			}
+			saveFailedResults = append(saveFailedResults, failure)
+			report(failedJobFile(failure))
+			ps.discardUpload(upload)
+			contentLock.Unlock(upload.photo.Sum)
+			continue
+		}
+		contentLock.Unlock(upload.photo.Sum)
+		report(dto.JobFileDto{UploadID: upload.uploadID, Status: JOB_FILE_SAVED, PhotoID: upload.photo.ID})
+	}
 	for failedResult := range failures {
 		failedResults = append(failedResults, *failedResult)
 	}
 
-	for photo := range models {
-		preparedPhotos = append(preparedPhotos, *photo)
+	return append(failedResults, saveFailedResults...)
+}
+
+// discardUpload 删除保存失败的图片的文件，有其他图片（包括回收站内的图片）使用相同的文件时不删除
+func (ps *photoService) discardUpload(upload *preparedUpload) {
+	var count int64
+	if result := ps.db.Unscoped().Model(&model.Photo{}).Where("uri = ?", upload.photo.Uri).Count(&count); result.Error != nil {
+		ps.Error("count photos of uri %s error: %v", upload.photo.Uri, result.Error)
+		return
+	}
+	if count > 0 {
+		return
+	}
+	if err := upload.uploadMgr.Discard(); err != nil {
+		ps.Error("discard upload files error: %v", err)
+	}
+}
+
+// prepareUploadPhoto 保存上传的图片，返回待保存的图片数据或者失败原因
+func (ps *photoService) prepareUploadPhoto(
+	param dto.CreatePhotoParam,
+	sumMap *sync.Map,
+	budget *imagemanager.MemoryBudget,
+) (upload *preparedUpload, failure *dto.CreatePhotoFailedResult) {
+	uploadMgr := ps.ctx.GetImageManager().NewUploadManager(
+		param.ImageSource,
+		imagemanager.WithUploadMemoryBudget(budget),
+	)
+	defer uploadMgr.Close()
+
+	imageName := uploadMgr.GetImageName()
+	var photo = model.Photo{Desc: param.Desc, FileName: imageName, PhotoDate: param.PhotoDate}
+	if !strings.Contains(photo.Desc, imageName) {
+		photo.Desc = ConcatDesc(photo.Desc, imageName)
 	}
 
-	if result := ps.db.Create(&preparedPhotos); result.Error != nil {
-		panic(fmt.Sprintf("batch save photo error: %v", result.Error))
+	sum, err := uploadMgr.GetHexSum()
+	if err != nil {
+		ps.Error("get hex sum error: %v", err)
+		return nil, &dto.CreatePhotoFailedResult{
+			UploadID: param.UploadID,
+			Message:  err.Error(),
+		}
 	}
 
-	return failedResults
+	// 判断是否和同一批正在上传的文件重复
+	_, loaded := sumMap.LoadOrStore(sum, true)
+	if loaded {
+		return nil, &dto.CreatePhotoFailedResult{
+			UploadID:  param.UploadID,
+			Message:   BuildUploadDupMsg(imageName, photo.Desc),
+			Duplicate: true,
+		}
+	}
+
+	// 其他任务正在上传或者删除相同的文件时，等待完成后再判断数据库内是否存在
+	// 保存数据或者删除文件后才解锁，避免删除文件时其他任务正在使用相同的文件
+	contentLock := ps.ctx.GetImageManager().GetContentLock()
+	contentLock.Lock(sum)
+	defer func() {
+		if upload == nil {
+			contentLock.Unlock(sum)
+		}
+	}()
+
+	// 判断数据库内是否存在相同的图片
+	result := ps.db.Select("id").Where(&model.Photo{Sum: sum}).Take(&model.Photo{})
+	if result.Error == nil {
+		msg := "文件重复"
+		ps.Error(msg)
+		return nil, &dto.CreatePhotoFailedResult{
+			UploadID:  param.UploadID,
+			Message:   msg,
+			Duplicate: true,
+		}
+	}
+	if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		ps.Error("select same sum photo error: %v", err)
+		return nil, &dto.CreatePhotoFailedResult{
+			UploadID: param.UploadID,
+			Message:  result.Error.Error(),
+		}
+	}
+
+	photo.Sum = sum
+
+	// 获取图片其他信息
+	imgInfo, err := uploadMgr.GetImageInfo()
+	if err != nil {
+		ps.Error("get image info error: %v", err)
+		return nil, &dto.CreatePhotoFailedResult{
+			UploadID: param.UploadID,
+			Message:  err.Error(),
+		}
+	}
+
+	photo.Size = imgInfo.Size
+	photo.Format = imgInfo.Format
+	photo.Width = imgInfo.Width
+	photo.Height = imgInfo.Height
+	UpdateExifInfo(&photo, imgInfo.Exif)
+
+	// 保存图片
+	uri, err := uploadMgr.Save()
+	if err != nil {
+		ps.Error("save image error: %v", err)
+		return nil, &dto.CreatePhotoFailedResult{
+			UploadID: param.UploadID,
+			Message:  err.Error(),
+		}
+	}
+	photo.Uri = uri
+
+	// 感知哈希不是必须的，计算失败时由后台任务补充
+	if hash, err := uploadMgr.GetPerceptualHash(); err != nil {
+		ps.Error("get perceptual hash error: %v", err)
+	} else {
+		photo.PHash = imagemanager.FormatHash(hash)
+	}
+
+	return &preparedUpload{uploadID: param.UploadID, photo: &photo, uploadMgr: uploadMgr}, nil
 }
 
 func (ps *photoService) UpdatePhoto(param dto.PhotoParam) (*dto.PhotoDto, error) {
@@ -280,6 +539,30 @@ func (ps *photoService) UpdatePhoto(param dto.PhotoParam) (*dto.PhotoDto, error)
 	return photoDto, nil
 }
 
+func (ps *photoService) SetFavorite(param dto.PhotoFavoriteParam) error {
+	return ps.updatePhotos(param.PhotoIDs, "favorite", *param.Favorite)
+}
+
+func (ps *photoService) SetRating(param dto.PhotoRatingParam) error {
+	return ps.updatePhotos(param.PhotoIDs, "rating", *param.Rating)
+}
+
+// updatePhotos 修改多张图片的同一个字段，有图片不存在时不修改
+func (ps *photoService) updatePhotos(photoIDs []uint, column string, value any) error {
+	photoIDs = uniqueIds(photoIDs)
+	return ps.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Photo{}).Where("id IN ?", photoIDs).Update(column, value)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(photoIDs)) {
+			return application.NewAppError(http.StatusBadRequest, "修改的图片不存在")
+		}
+		return nil
+	})
+}
+
+// DeletePhoto 将图片移动到回收站，文件在彻底删除时才删除
 func (ps *photoService) DeletePhoto(id uint) error {
 	photo := &model.Photo{}
 	if result := ps.db.First(photo, id); result.Error != nil {
@@ -295,6 +578,10 @@ func (ps *photoService) DeletePhoto(id uint) error {
 }
 
 func (ps *photoService) GetPhotoFile(id uint, original bool) (io.ReadCloser, *imagemanager.ImageInfo, error) {
+	if !original {
+		return ps.GetPhotoRendition(id, imagemanager.RENDITION_PREVIEW)
+	}
+
 	var photo model.Photo
 	if result := ps.db.First(&photo, id); result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -306,22 +593,248 @@ func (ps *photoService) GetPhotoFile(id uint, original bool) (io.ReadCloser, *im
 	downloadManager := ps.ctx.GetImageManager().NewDownloadManager(photo.Uri)
 
 	imgInfo := &imagemanager.ImageInfo{Size: photo.Size, Format: photo.Format}
-	if original {
-		reader, err := downloadManager.OpenOriginal()
-		if err != nil {
-			return nil, nil, err
+	reader, err := downloadManager.OpenOriginal()
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, imgInfo, nil
+}
+
+func (ps *photoService) GetPhotoRendition(id uint, size string) (io.ReadCloser, *imagemanager.ImageInfo, error) {
+	rendition, err := imagemanager.GetRendition(size)
+	if err != nil {
+		return nil, nil, application.NewAppError(http.StatusBadRequest, "不支持的图片尺寸: %s", size)
+	}
+
+	var photo model.Photo
+	if result := ps.db.First(&photo, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil, application.ErrDataNotFound
 		}
-		return reader, imgInfo, nil
+		return nil, nil, result.Error
+	}
+
+	downloadManager := ps.ctx.GetImageManager().NewDownloadManager(photo.Uri)
+	imageData, err := downloadManager.GetRendition(rendition)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	imageData, err := downloadManager.GetCompressed()
+	// 缩略图的格式不一定和原图相同
+	format, err := imagemanager.DetectFormat(imageData)
 	if err != nil {
 		return nil, nil, err
 	}
-	imgInfo.Size = int64(len(imageData))
+	imgInfo := &imagemanager.ImageInfo{Size: int64(len(imageData)), Format: format}
 	return io.NopCloser(bytes.NewReader(imageData)), imgInfo, nil
 }
 
+func (ps *photoService) RenderPhoto(id uint, param dto.RenderParam) (io.ReadCloser, *imagemanager.ImageInfo, error) {
+	var photo model.Photo
+	if result := ps.db.First(&photo, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil, application.ErrDataNotFound
+		}
+		return nil, nil, result.Error
+	}
+
+	downloadManager := ps.ctx.GetImageManager().NewDownloadManager(photo.Uri)
+	rc, imgInfo, err := downloadManager.Render(param.ToOption().Normalize(photo.Format))
+	if errors.Is(err, imagemanager.ErrImageTooLarge) {
+		return nil, nil, application.NewAppError(http.StatusUnprocessableEntity, "图片尺寸过大，无法渲染")
+	}
+	return rc, imgInfo, err
+}
+
+// RenderETag 使用图片内容和补全默认值后的参数生成渲染结果的 ETag
+// 彻底删除后 id 可能被新的图片使用，不能只使用 id
+func (ps *photoService) RenderETag(id uint, param dto.RenderParam) (string, error) {
+	var photo model.Photo
+	if result := ps.db.Select("uri", "sum", "format").First(&photo, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return "", application.ErrDataNotFound
+		}
+		return "", result.Error
+	}
+
+	// 之前上传的图片没有 sum，使用 uri
+	version := photo.Sum
+	if version == "" {
+		version = fmt.Sprintf("%x", sha1.Sum([]byte(photo.Uri)))
+	}
+	return fmt.Sprintf(`"%s-%s"`, version, param.ToOption().Normalize(photo.Format).CacheName()), nil
+}
+
+const (
+	GEO_DEFAULT_LIMIT = 200
+	// 聚合时每个格子约占 64 像素（地图瓦片 256 像素）
+	GEO_CLUSTER_DEGREES = 90.0
+)
+
+func (ps *photoService) GeoPhotos(param dto.GeoParam) (*dto.GeoResult, error) {
+	minLat, maxLat, minLng, maxLng := *param.MinLat, *param.MaxLat, *param.MinLng, *param.MaxLng
+	if minLat > maxLat {
+		return nil, application.NewAppError(http.StatusBadRequest, "minLat 不能大于 maxLat")
+	}
+	limit := param.Limit
+	if limit == 0 {
+		limit = GEO_DEFAULT_LIMIT
+	}
+
+	bboxQuery := func() *gorm.DB {
+		query := ps.db.Model(&model.Photo{}).
+			Where("latitude IS NOT NULL AND longitude IS NOT NULL").
+			Where("latitude BETWEEN ? AND ?", minLat, maxLat)
+		// 跨越 180 度经线
+		if minLng > maxLng {
+			return query.Where("longitude >= ? OR longitude <= ?", minLng, maxLng)
+		}
+		return query.Where("longitude BETWEEN ? AND ?", minLng, maxLng)
+	}
+
+	photoDtoList := make([]dto.PhotoDto, 0)
+	if result := bboxQuery().Order("photo_date desc").Limit(limit).Find(&photoDtoList); result.Error != nil {
+		return nil, result.Error
+	}
+
+	clusters := make([]dto.GeoCluster, 0)
+	if param.Zoom != nil {
+		cell := GEO_CLUSTER_DEGREES / math.Exp2(float64(*param.Zoom))
+		result := bboxQuery().
+			Select(
+				"AVG(latitude) AS latitude, AVG(longitude) AS longitude, COUNT(*) AS count, MIN(id) AS photo_id",
+			).
+			Group(fmt.Sprintf("CAST((latitude + 90) / %v AS INTEGER), CAST((longitude + 180) / %v AS INTEGER)", cell, cell)).
+			Scan(&clusters)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+
+	return &dto.GeoResult{Photos: photoDtoList, Clusters: clusters}, nil
+}
+
+const (
+	DUPLICATE_DEFAULT_DISTANCE = 6
+	PHASH_BATCH_SIZE           = 100
+)
+
+func (ps *photoService) DuplicatePhotos(param dto.DuplicateParam) ([]dto.DuplicateCluster, error) {
+	distance := DUPLICATE_DEFAULT_DISTANCE
+	if param.Distance != nil {
+		distance = *param.Distance
+	}
+
+	var rows []struct {
+		ID    uint
+		PHash string
+	}
+	result := ps.db.Model(&model.Photo{}).
+		Select("id, p_hash").
+		Where("p_hash IS NOT NULL AND p_hash <> ''").
+		Order("id").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	ids := make([]uint, 0, len(rows))
+	hashes := make([]uint64, 0, len(rows))
+	for _, row := range rows {
+		hash, err := imagemanager.ParseHash(row.PHash)
+		if err != nil {
+			ps.Error("parse photo %d perceptual hash %s error: %v", row.ID, row.PHash, err)
+			continue
+		}
+		ids = append(ids, row.ID)
+		hashes = append(hashes, hash)
+	}
+
+	clusterIndexes := imagemanager.ClusterHashes(hashes, distance)
+	var clusterIds []uint
+	for _, indexes := range clusterIndexes {
+		for _, index := range indexes {
+			clusterIds = append(clusterIds, ids[index])
+		}
+	}
+	// 一次查询所有分组内的图片，在内存内分组
+	photoMap := make(map[uint]dto.PhotoDto, len(clusterIds))
+	for chunk := range slices.Chunk(clusterIds, SQL_IN_CHUNK_SIZE) {
+		var photoDtoList []dto.PhotoDto
+		if result := ps.db.Model(&model.Photo{}).Where("id IN ?", chunk).Find(&photoDtoList); result.Error != nil {
+			return nil, result.Error
+		}
+		for _, photoDto := range photoDtoList {
+			photoMap[photoDto.ID] = photoDto
+		}
+	}
+
+	clusters := make([]dto.DuplicateCluster, 0, len(clusterIndexes))
+	for _, indexes := range clusterIndexes {
+		// 下标按 id 排序
+		photoDtoList := make([]dto.PhotoDto, 0, len(indexes))
+		for _, index := range indexes {
+			if photoDto, ok := photoMap[ids[index]]; ok {
+				photoDtoList = append(photoDtoList, photoDto)
+			}
+		}
+		clusters = append(clusters, dto.DuplicateCluster{Photos: photoDtoList})
+	}
+	return clusters, nil
+}
+
+// BackfillPerceptualHash 为之前上传的图片计算感知哈希，返回更新的数量
+func (ps *photoService) BackfillPerceptualHash() (int, error) {
+	var (
+		photos  []model.Photo
+		updated int
+	)
+	result := ps.db.
+		Select("id, uri").
+		Where("p_hash IS NULL OR p_hash = ''").
+		FindInBatches(&photos, PHASH_BATCH_SIZE, func(tx *gorm.DB, batch int) error {
+			for _, photo := range photos {
+				hash, err := ps.ctx.GetImageManager().NewDownloadManager(photo.Uri).GetPerceptualHash()
+				if err != nil {
+					ps.Error("get photo %d perceptual hash error: %v", photo.ID, err)
+					continue
+				}
+				result := ps.db.Model(&model.Photo{}).
+					Where("id = ?", photo.ID).
+					UpdateColumn("p_hash", imagemanager.FormatHash(hash))
+				if result.Error != nil {
+					return result.Error
+				}
+				updated++
+			}
+			return nil
+		})
+	return updated, result.Error
+}
+
+// UpdateExifInfo 将 exif 信息写入 photo，上传时未指定拍摄日期则使用 exif 内的拍摄日期
+func UpdateExifInfo(photo *model.Photo, exifInfo *imagemanager.ExifInfo) {
+	if exifInfo != nil {
+		photo.CameraMake = exifInfo.CameraMake
+		photo.CameraModel = exifInfo.CameraModel
+		photo.LensModel = exifInfo.LensModel
+		photo.ExposureTime = exifInfo.ExposureTime
+		photo.FNumber = exifInfo.FNumber
+		photo.ISO = exifInfo.ISO
+		photo.FocalLength = exifInfo.FocalLength
+		photo.Orientation = exifInfo.Orientation
+		photo.Latitude = exifInfo.Latitude
+		photo.Longitude = exifInfo.Longitude
+		photo.Altitude = exifInfo.Altitude
+		if photo.PhotoDate.IsZero() {
+			photo.PhotoDate = exifInfo.DateTimeOriginal
+		}
+	}
+	if photo.PhotoDate.IsZero() {
+		photo.PhotoDate = time.Now()
+	}
+}
+
 func ConcatDesc(desc string, name string) string {
 	return fmt.Sprintf("%s\n%s", desc, name)
 }
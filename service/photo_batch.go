@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"gorm.io/gorm"
+)
+
+// 批量操作
+const (
+	BATCH_OP_SHIFT_DATE   = "shiftDate"
+	BATCH_OP_SET_DESC     = "setDesc"
+	BATCH_OP_DELETE       = "delete"
+	BATCH_OP_ADD_TAG      = "addTag"
+	BATCH_OP_ADD_TO_ALBUM = "addToAlbum"
+
+	// 单次批量操作最多处理的图片数量
+	BATCH_MAX_PHOTOS = 10000
+)
+
+// sqlite 单条语句最多使用 32766 个参数，大量数据需要分批执行
+const (
+	// IN 查询每次最多使用的 id 数量，留出其他条件使用的参数
+	SQL_IN_CHUNK_SIZE = 10000
+	// 批量插入时每条语句插入的行数
+	SQL_INSERT_BATCH_SIZE = 1000
+)
+
+// batchOperation 对已经确认存在的图片执行的操作
+type batchOperation func(tx *gorm.DB, photoIDs []uint) error
+
+// BatchPhotos 在同一个事务内处理所有图片，有图片处理失败时撤销所有修改，错误详情内返回每张图片的结果
+func (ps *photoService) BatchPhotos(param dto.BatchParam) ([]dto.BatchResult, error) {
+	if err := checkPhotoSelection(param.PhotoIDs, param.Query); err != nil {
+		return nil, err
+	}
+	operation, err := ps.batchOperation(param)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []dto.BatchResult
+	err = ps.db.Transaction(func(tx *gorm.DB) error {
+		var (
+			photoIDs []uint
+			err      error
+		)
+		if photoIDs, results, err = SelectPhotos(tx, param.PhotoIDs, param.Query); err != nil {
+			return err
+		}
+		if len(photoIDs) == 0 {
+			return nil
+		}
+		return operation(tx, photoIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchOperation 检查操作的参数
+func (ps *photoService) batchOperation(param dto.BatchParam) (batchOperation, error) {
+	switch param.Op {
+	case BATCH_OP_SHIFT_DATE:
+		if param.Offset == "" {
+			return nil, application.NewAppError(http.StatusBadRequest, "offset 不能为空")
+		}
+		shifter, err := ParseDateShifter(param.Offset, "", "")
+		if err != nil {
+			return nil, err
+		}
+		// 和单独修改拍摄时间一样保存修改记录，可以撤销
+		return func(tx *gorm.DB, photoIDs []uint) error {
+			_, err := ShiftPhotoDates(tx, photoIDs, shifter, false)
+			return err
+		}, nil
+	case BATCH_OP_SET_DESC:
+		if param.Desc == nil {
+			return nil, application.NewAppError(http.StatusBadRequest, "desc 不能为空")
+		}
+		return func(tx *gorm.DB, photoIDs []uint) error {
+			for chunk := range slices.Chunk(photoIDs, SQL_IN_CHUNK_SIZE) {
+				if result := tx.Model(&model.Photo{}).Where("id IN ?", chunk).Update("desc", *param.Desc); result.Error != nil {
+					return result.Error
+				}
+			}
+			return nil
+		}, nil
+	case BATCH_OP_DELETE:
+		// 移动到回收站
+		return func(tx *gorm.DB, photoIDs []uint) error {
+			for chunk := range slices.Chunk(photoIDs, SQL_IN_CHUNK_SIZE) {
+				if result := tx.Where("id IN ?", chunk).Delete(&model.Photo{}); result.Error != nil {
+					return result.Error
+				}
+			}
+			return nil
+		}, nil
+	case BATCH_OP_ADD_TAG:
+		names, err := NormalizeTags(param.Tags)
+		if err != nil {
+			return nil, err
+		}
+		return func(tx *gorm.DB, photoIDs []uint) error {
+			return AddPhotoTags(tx, photoIDs, names)
+		}, nil
+	case BATCH_OP_ADD_TO_ALBUM:
+		if param.AlbumID == 0 {
+			return nil, application.NewAppError(http.StatusBadRequest, "albumId 不能为空")
+		}
+		return func(tx *gorm.DB, photoIDs []uint) error {
+			if err := tx.Select("id").First(&model.Album{}, param.AlbumID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return application.NewAppError(http.StatusBadRequest, "相册 [ %d ] 不存在", param.AlbumID)
+				}
+				return err
+			}
+			return AppendAlbumPhotos(tx, param.AlbumID, photoIDs)
+		}, nil
+	default:
+		return nil, application.NewAppError(http.StatusBadRequest, "不支持的操作 [ %s ]", param.Op)
+	}
+}
+
+func checkPhotoSelection(photoIDs []uint, query string) error {
+	if len(photoIDs) == 0 && strings.TrimSpace(query) == "" {
+		return application.NewAppError(http.StatusBadRequest, "photoIds 和 query 至少需要填写一个")
+	}
+	return nil
+}
+
+// SelectPhotos 查询存在并且满足查询语句的图片，同时返回每张图片的结果
+// 指定的图片不存在时返回错误，错误详情内包含每张图片的结果
+func SelectPhotos(tx *gorm.DB, requestIDs []uint, searchQuery string) ([]uint, []dto.BatchResult, error) {
+	requestIDs = uniqueIds(requestIDs)
+	if len(requestIDs) > BATCH_MAX_PHOTOS {
+		return nil, nil, application.NewAppError(http.StatusBadRequest, "单次最多处理 %d 张图片", BATCH_MAX_PHOTOS)
+	}
+	query := tx.Model(&model.Photo{})
+	if strings.TrimSpace(searchQuery) != "" {
+		var err error
+		if query, err = ApplySearchQuery(query, searchQuery); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(requestIDs) > 0 {
+		query = query.Where("photos.id IN ?", requestIDs)
+	}
+	var photoIDs []uint
+	if result := query.Order("photos.id").Limit(BATCH_MAX_PHOTOS+1).Pluck("photos.id", &photoIDs); result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if len(photoIDs) > BATCH_MAX_PHOTOS {
+		return nil, nil, application.NewAppError(http.StatusBadRequest, "单次最多处理 %d 张图片", BATCH_MAX_PHOTOS)
+	}
+
+	results := selectResults(requestIDs, searchQuery, photoIDs)
+	if failed := len(results) - len(photoIDs); failed > 0 {
+		appErr := application.NewAppError(http.StatusBadRequest, "%d 张图片处理失败，没有修改任何图片", failed)
+		appErr.Details = results
+		return nil, nil, appErr
+	}
+	return photoIDs, results, nil
+}
+
+// selectResults 指定了图片 id 时返回每个 id 的结果，否则返回查询到的图片的结果
+func selectResults(requestIDs []uint, searchQuery string, photoIDs []uint) []dto.BatchResult {
+	if len(requestIDs) == 0 {
+		results := make([]dto.BatchResult, 0, len(photoIDs))
+		for _, id := range photoIDs {
+			results = append(results, dto.BatchResult{PhotoID: id, Success: true})
+		}
+		return results
+	}
+	message := "图片不存在"
+	if strings.TrimSpace(searchQuery) != "" {
+		message = "图片不存在或者不满足查询条件"
+	}
+	results := make([]dto.BatchResult, 0, len(requestIDs))
+	for _, id := range requestIDs {
+		if _, found := slices.BinarySearch(photoIDs, id); found {
+			results = append(results, dto.BatchResult{PhotoID: id, Success: true})
+		} else {
+			results = append(results, dto.BatchResult{PhotoID: id, Message: message})
+		}
+	}
+	return results
+}
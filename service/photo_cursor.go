@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"gorm.io/gorm"
+)
+
+const PHOTO_CURSOR_DEFAULT_LIMIT = 50
+
+// photoCursor 分页的位置，编码后返回给前端，前端不需要解析
+type photoCursor struct {
+	// 生成 cursor 时的排序方式
+	Sort string `json:"s"`
+	// 向前翻页
+	Prev bool `json:"p,omitempty"`
+	// 位置上的图片的排序列的值和 id，时间使用 RFC3339 格式的字符串
+	Value any  `json:"v,omitempty"`
+	ID    uint `json:"i,omitempty"`
+	// 不能使用 keyset 分页时，使用 offset
+	Offset int `json:"o,omitempty"`
+}
+
+func (pc photoCursor) encode() string {
+	data, _ := json.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePhotoCursor(cursor string) (*photoCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var pc photoCursor
+	if err := decoder.Decode(&pc); err != nil {
+		return nil, err
+	}
+	if pc.Offset < 0 {
+		return nil, fmt.Errorf("offset %d 小于 0", pc.Offset)
+	}
+	return &pc, nil
+}
+
+// sortValue 排序列的值转换为查询的参数
+func (pc photoCursor) sortValue() (any, error) {
+	switch value := pc.Value.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, value)
+	case json.Number:
+		return value.Int64()
+	default:
+		return nil, fmt.Errorf("排序的值类型错误 %T", pc.Value)
+	}
+}
+
+func (ps *photoService) PhotoCursorPage(param dto.CursorParam[dto.PhotoPageParam]) (*dto.CursorResult[dto.PhotoDto], error) {
+	if param.Limit <= 0 {
+		param.Limit = PHOTO_CURSOR_DEFAULT_LIMIT
+	}
+	sort := ps.resolvePhotoSort(param.Params)
+	var cursor *photoCursor
+	if param.Cursor != "" {
+		var err error
+		if cursor, err = decodePhotoCursor(param.Cursor); err != nil {
+			return nil, application.NewAppError(http.StatusBadRequest, "cursor 格式错误: %v", err)
+		}
+		if cursor.Sort != sort.key {
+			return nil, application.NewAppError(
+				http.StatusBadRequest, "cursor 的排序方式 [ %s ] 和当前的排序方式 [ %s ] 不一致", cursor.Sort, sort.key)
+		}
+	}
+
+	query, err := ps.filterPhotos(param.Params)
+	if err != nil {
+		return nil, err
+	}
+	result := &dto.CursorResult[dto.PhotoDto]{List: []dto.PhotoDto{}}
+	if param.WithTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if sort.column == "" {
+		err = ps.offsetPage(query, sort, cursor, param.Limit, result)
+	} else {
+		err = ps.keysetPage(query, sort, cursor, param, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.fillPhotoList(result.List, param.Params); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// offsetPage 按相关度排序时，排序的值不能作为查询条件，使用 offset 分页
+func (ps *photoService) offsetPage(
+	query *gorm.DB, sort photoSort, cursor *photoCursor, limit int, result *dto.CursorResult[dto.PhotoDto],
+) error {
+	offset := 0
+	if cursor != nil {
+		offset = cursor.Offset
+	}
+	// 多查询一条，判断是否有下一页
+	if err := query.Order(sort.order(false)).Offset(offset).Limit(limit + 1).Find(&result.List).Error; err != nil {
+		return err
+	}
+	if len(result.List) > limit {
+		result.List = result.List[:limit]
+		result.NextCursor = photoCursor{Sort: sort.key, Offset: offset + limit}.encode()
+	}
+	if offset > 0 {
+		result.PrevCursor = photoCursor{Sort: sort.key, Offset: max(offset-limit, 0)}.encode()
+	}
+	return nil
+}
+
+// keysetPage 根据上一页最后一张图片的排序值和 id 查询，不需要跳过前面的数据
+// 向前翻页时反向排序查询，再把结果反转
+func (ps *photoService) keysetPage(
+	query *gorm.DB,
+	sort photoSort,
+	cursor *photoCursor,
+	param dto.CursorParam[dto.PhotoPageParam],
+	result *dto.CursorResult[dto.PhotoDto],
+) error {
+	prev := cursor != nil && cursor.Prev
+	if cursor != nil {
+		value, err := cursor.sortValue()
+		if err != nil {
+			return application.NewAppError(http.StatusBadRequest, "cursor 格式错误: %v", err)
+		}
+		op := ">"
+		if sort.desc != prev {
+			op = "<"
+		}
+		query = query.Where(fmt.Sprintf("(%s, photos.id) %s (?, ?)", sort.column, op), value, cursor.ID)
+	}
+	// 多查询一条，判断翻页方向上是否还有数据
+	if err := query.Order(sort.order(prev)).Limit(param.Limit + 1).Find(&result.List).Error; err != nil {
+		return err
+	}
+	hasMore := len(result.List) > param.Limit
+	if hasMore {
+		result.List = result.List[:param.Limit]
+	}
+	if prev {
+		slices.Reverse(result.List)
+	}
+
+	// 没有数据时，另一个方向的 cursor 使用当前的位置
+	positionCursor := func(prev bool) string {
+		if cursor == nil {
+			return ""
+		}
+		return photoCursor{Sort: sort.key, Prev: prev, Value: cursor.Value, ID: cursor.ID}.encode()
+	}
+	var nextHasMore, prevHasMore bool
+	if prev {
+		nextHasMore, prevHasMore = cursor != nil, hasMore
+	} else {
+		nextHasMore, prevHasMore = hasMore, cursor != nil
+	}
+	if len(result.List) == 0 {
+		if nextHasMore {
+			result.NextCursor = positionCursor(false)
+		}
+		if prevHasMore {
+			result.PrevCursor = positionCursor(true)
+		}
+		return nil
+	}
+	if nextHasMore {
+		last := result.List[len(result.List)-1].ID
+		next, err := ps.photoCursorAt(sort, param.Params, last, false)
+		if err != nil {
+			return err
+		}
+		result.NextCursor = next
+	}
+	if prevHasMore {
+		prevCursor, err := ps.photoCursorAt(sort, param.Params, result.List[0].ID, true)
+		if err != nil {
+			return err
+		}
+		result.PrevCursor = prevCursor
+	}
+	return nil
+}
+
+// photoCursorAt 生成指定图片位置的 cursor
+func (ps *photoService) photoCursorAt(sort photoSort, params dto.PhotoPageParam, id uint, prev bool) (string, error) {
+	query := ps.db.Model(&model.Photo{}).Select(sort.column).Where("photos.id = ?", id)
+	if sort.key == PHOTO_SORT_ALBUM {
+		query = query.
+			Joins("JOIN album_photos ON album_photos.photo_id = photos.id").
+			Where("album_photos.album_id = ?", params.AlbumID)
+	}
+	var value any
+	if err := query.Row().Scan(&value); err != nil {
+		return "", err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.Format(time.RFC3339Nano)
+	}
+	return photoCursor{Sort: sort.key, Prev: prev, Value: value, ID: id}.encode(), nil
+}
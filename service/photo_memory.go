@@ -0,0 +1,144 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"time"
+
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+)
+
+const MEMORY_DEFAULT_LIMIT = 10
+
+// memoryRange 往年当天前后 window 天的范围
+type memoryRange struct {
+	year       int
+	start, end time.Time
+	photos     []model.Photo
+}
+
+func (ps *photoService) PhotoMemories(param dto.MemoryParam) ([]dto.MemoryGroup, error) {
+	now := time.Now()
+	if param.Date != nil {
+		now = *param.Date
+	}
+	window := ps.ctx.GetConfig().GetMemoriesWindowDays()
+	if param.Window != nil {
+		window = *param.Window
+	}
+	if param.Limit <= 0 {
+		param.Limit = MEMORY_DEFAULT_LIMIT
+	}
+	groups := []dto.MemoryGroup{}
+
+	// 闰年的 2 月 29 日在其他年份为 3 月 1 日
+	dayRange := func(year int) *memoryRange {
+		day := time.Date(year, now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		return &memoryRange{year: year, start: day.AddDate(0, 0, -window), end: day.AddDate(0, 0, window+1)}
+	}
+	// 按月日过滤，不按年份生成条件，拍摄时间很早的图片会生成过多的条件
+	query := ps.db.Select("id", "photo_date").Where("photo_date < ?", dayRange(now.Year()).start)
+	if monthDays := memoryMonthDays(now, window); monthDays != nil {
+		query = query.Where("substr(photo_date, 6, 5) IN ?", monthDays)
+	}
+	var candidates []model.Photo
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	// 前后的天数不超过半年，图片只可能属于前一年、当年或者后一年的范围
+	rangeMap := make(map[int]*memoryRange)
+	var ranges []*memoryRange
+	for _, photo := range candidates {
+		year := photo.PhotoDate.Year()
+		for _, y := range []int{year, year + 1, year - 1} {
+			if y >= now.Year() {
+				continue
+			}
+			r, ok := rangeMap[y]
+			if !ok {
+				r = dayRange(y)
+				rangeMap[y] = r
+				ranges = append(ranges, r)
+			}
+			if !photo.PhotoDate.Before(r.start) && photo.PhotoDate.Before(r.end) {
+				r.photos = append(r.photos, photo)
+				break
+			}
+		}
+	}
+	// 从去年开始
+	slices.SortFunc(ranges, func(a, b *memoryRange) int {
+		return cmp.Compare(b.year, a.year)
+	})
+
+	// 按日期和图片 id 的哈希值选择图片，同一天的选择结果相同，不同的日期选择不同的图片
+	seed := now.Format("2006-01-02")
+	score := func(id uint) uint64 {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s/%d", seed, id)
+		return h.Sum64()
+	}
+	groupIndex := make(map[uint]int)
+	var selectedIDs []uint
+	for _, r := range ranges {
+		if len(r.photos) == 0 {
+			continue
+		}
+		slices.SortFunc(r.photos, func(a, b model.Photo) int {
+			return cmp.Compare(score(a.ID), score(b.ID))
+		})
+		for _, photo := range r.photos[:min(param.Limit, len(r.photos))] {
+			groupIndex[photo.ID] = len(groups)
+			selectedIDs = append(selectedIDs, photo.ID)
+		}
+		groups = append(groups, dto.MemoryGroup{
+			Year:     r.year,
+			YearsAgo: now.Year() - r.year,
+			Count:    len(r.photos),
+			Photos:   []dto.PhotoDto{},
+		})
+	}
+	if len(selectedIDs) == 0 {
+		return groups, nil
+	}
+
+	var photoDtoList []dto.PhotoDto
+	result := ps.db.Model(&model.Photo{}).
+		Where("id IN ?", selectedIDs).
+		Order("photo_date, id").
+		Find(&photoDtoList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if err := FillPhotoTags(ps.db.DB, photoDtoList); err != nil {
+		return nil, err
+	}
+	for _, photoDto := range photoDtoList {
+		group := &groups[groupIndex[photoDto.ID]]
+		group.Photos = append(group.Photos, photoDto)
+	}
+	return groups, nil
+}
+
+// memoryMonthDays 往年当天前后 window 天内的月日，格式为 01-02，包含所有年份的情况时返回 nil
+// 2 月 29 日的处理和前后是否为闰年有关，合并连续四年的结果
+func memoryMonthDays(now time.Time, window int) []string {
+	if 2*window+1 >= 365 {
+		return nil
+	}
+	monthDays := make([]string, 0, 2*window+2)
+	for year := 2022; year <= 2025; year++ {
+		day := time.Date(year, now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		for offset := -window; offset <= window; offset++ {
+			monthDay := day.AddDate(0, 0, offset).Format("01-02")
+			if !slices.Contains(monthDays, monthDay) {
+				monthDays = append(monthDays, monthDay)
+			}
+		}
+	}
+	return monthDays
+}
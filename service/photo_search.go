@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/search"
+	"gorm.io/gorm"
+)
+
+// 查询语句内的字段对应的列
+var searchColumns = map[string]string{
+	search.FIELD_DESC:   "photos.desc",
+	search.FIELD_LENS:   "photos.lens_model",
+	search.FIELD_DATE:   "photos.photo_date",
+	search.FIELD_WIDTH:  "photos.width",
+	search.FIELD_HEIGHT: "photos.height",
+	search.FIELD_ISO:    "photos.iso",
+	search.FIELD_SIZE:   "photos.size",
+	search.FIELD_RATING: "photos.rating",
+}
+
+// 格式的别名
+var formatAliases = map[string]string{
+	"jpg": "jpeg",
+	"tif": "tiff",
+}
+
+// ApplySearchQuery 解析查询语句并添加到查询条件，语句错误时返回 400 错误
+func ApplySearchQuery(db *gorm.DB, query string) (*gorm.DB, error) {
+	terms, err := search.Parse(query)
+	if err != nil {
+		var syntaxErr *search.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			appErr := application.NewAppError(http.StatusBadRequest, "查询条件错误: %s", syntaxErr.Error())
+			appErr.Details = syntaxErr
+			return nil, appErr
+		}
+		return nil, err
+	}
+	for _, term := range terms {
+		sql, args := searchTermClause(term)
+		if term.Negate {
+			sql = "NOT (" + sql + ")"
+		}
+		db = db.Where(sql, args...)
+	}
+	return db, nil
+}
+
+func likeClause(column string, op string, value string) (string, []any) {
+	if op == search.OP_EQ {
+		return column + " = ? COLLATE NOCASE", []any{value}
+	}
+	return column + ` LIKE ? ESCAPE '\'`, []any{"%" + EscapeLike(value) + "%"}
+}
+
+func searchTermClause(term search.Term) (string, []any) {
+	column := searchColumns[term.Field]
+	switch term.Field {
+	case "", search.FIELD_DESC:
+		return likeClause(searchColumns[search.FIELD_DESC], term.Op, term.Value)
+	case search.FIELD_LENS:
+		return likeClause(column, term.Op, term.Value)
+	case search.FIELD_CAMERA:
+		makeSql, makeArgs := likeClause("photos.camera_make", term.Op, term.Value)
+		modelSql, modelArgs := likeClause("photos.camera_model", term.Op, term.Value)
+		return "(" + makeSql + " OR " + modelSql + ")", append(makeArgs, modelArgs...)
+	case search.FIELD_TAG:
+		return "photos.id IN (SELECT photo_tags.photo_id FROM photo_tags " +
+			"JOIN tags ON tags.id = photo_tags.tag_id WHERE tags.name = ?)", []any{term.Value}
+	case search.FIELD_FORMAT:
+		format := strings.ToLower(term.Value)
+		if alias, ok := formatAliases[format]; ok {
+			format = alias
+		}
+		return "photos.format = ?", []any{format}
+	case search.FIELD_HAS:
+		switch strings.ToLower(term.Value) {
+		case search.HAS_GPS:
+			return "photos.latitude IS NOT NULL AND photos.longitude IS NOT NULL", nil
+		case search.HAS_TAG:
+			return "photos.id IN (SELECT photo_id FROM photo_tags)", nil
+		case search.HAS_FAVORITE:
+			return "photos.favorite = ?", []any{true}
+		default:
+			return "photos.id IN (SELECT photo_id FROM album_photos)", nil
+		}
+	case search.FIELD_DATE:
+		var (
+			conditions []string
+			args       []any
+		)
+		if !term.Start.IsZero() {
+			conditions = append(conditions, column+" >= ?")
+			args = append(args, term.Start)
+		}
+		if !term.End.IsZero() {
+			conditions = append(conditions, column+" < ?")
+			args = append(args, term.End)
+		}
+		return strings.Join(conditions, " AND "), args
+	default:
+		// 数字和大小
+		if term.Low == nil && term.High == nil {
+			op := term.Op
+			if op == search.OP_MATCH {
+				op = search.OP_EQ
+			}
+			return column + " " + op + " ?", []any{term.Number}
+		}
+		var (
+			conditions []string
+			args       []any
+		)
+		if term.Low != nil {
+			conditions = append(conditions, column+" >= ?")
+			args = append(args, *term.Low)
+		}
+		if term.High != nil {
+			conditions = append(conditions, column+" <= ?")
+			args = append(args, *term.High)
+		}
+		return strings.Join(conditions, " AND "), args
+	}
+}
+
+// useFullTextSearch 有 fts5 并且关键字都不少于 3 个字符时使用全文索引
+func (ps *photoService) useFullTextSearch(keywords []string) bool {
+	if !ps.db.FTSEnabled() {
+		return false
+	}
+	for _, keyword := range keywords {
+		if utf8.RuneCountInString(keyword) < database.FTS_MIN_TOKEN_LENGTH {
+			return false
+		}
+	}
+	return true
+}
+
+// FTSMatchExpr 每个关键字作为一个短语，所有短语都需要匹配
+func FTSMatchExpr(keywords []string) string {
+	phrases := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		phrases = append(phrases, `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`)
+	}
+	return strings.Join(phrases, " ")
+}
+
+// 全文搜索的相关度排序，标签的权重更高
+const FTS_RANK_ORDER = "bm25(photos_fts, 1.0, 1.0, 2.0)"
+
+// applyKeywords 搜索描述、文件名和标签
+func (ps *photoService) applyKeywords(query *gorm.DB, keywords []string) *gorm.DB {
+	if ps.useFullTextSearch(keywords) {
+		return query.
+			Joins("JOIN photos_fts ON photos_fts.rowid = photos.id").
+			Where("photos_fts MATCH ?", FTSMatchExpr(keywords))
+	}
+	for _, keyword := range keywords {
+		like := "%" + EscapeLike(keyword) + "%"
+		query = query.Where(`(photos.desc LIKE ? ESCAPE '\' OR photos.file_name LIKE ? ESCAPE '\' OR photos.id IN (`+
+			`SELECT photo_tags.photo_id FROM photo_tags JOIN tags ON tags.id = photo_tags.tag_id `+
+			`WHERE tags.name LIKE ? ESCAPE '\'))`, like, like, like)
+	}
+	return query
+}
+
+// fillSnippets 生成匹配内容的摘要
+// trigram 分词时 fts5 的 snippet 按三个字符截取，会截断关键字，所以都使用 search.Snippet 生成
+func (ps *photoService) fillSnippets(photoDtoList []dto.PhotoDto, keywords []string) {
+	for i := range photoDtoList {
+		photoDto := &photoDtoList[i]
+		for _, text := range []string{photoDto.Desc, photoDto.FileName, strings.Join(photoDto.Tags, " ")} {
+			if photoDto.Snippet = search.Snippet(text, keywords, search.SNIPPET_SIZE); photoDto.Snippet != "" {
+				break
+			}
+		}
+	}
+}
@@ -2,7 +2,13 @@ package service_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -16,8 +22,10 @@ import (
 	"github.com/follow1123/photos/imagemanager"
 	"github.com/follow1123/photos/model"
 	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/search"
 	"github.com/follow1123/photos/service"
 	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
 )
 
 type PhotoServiceSuite struct {
@@ -40,7 +48,7 @@ func (s *PhotoServiceSuite) SetupSuite() {
 
 	migrator, err := appgen.GenDBMigrator(appComponents)
 	s.Nil(err)
-	migrator.InitOrMigrate()
+	s.Nil(migrator.InitOrMigrate())
 
 	serv := service.NewPhotoService(ctx, db)
 
@@ -57,11 +65,14 @@ func (s *PhotoServiceSuite) TearDownSuite() {
 }
 
 func (s *PhotoServiceSuite) SetupTest() {
-	s.db.Migrator().CreateTable(&model.Photo{})
+	s.db.Migrator().CreateTable(&model.Photo{}, &model.Tag{}, &model.PhotoTag{}, &model.Album{}, &model.AlbumPhoto{})
+	s.Nil(s.db.InitFullTextSearch())
 }
 
 func (s *PhotoServiceSuite) TearDownTest() {
-	s.db.Migrator().DropTable(&model.Photo{})
+	s.db.Migrator().DropTable(
+		&model.Photo{}, &model.Tag{}, &model.PhotoTag{}, &model.Album{}, &model.AlbumPhoto{}, database.FTS_TABLE,
+	)
 }
 
 func (s *PhotoServiceSuite) TestGetByIdSuccess() {
@@ -172,3 +183,858 @@ func (s *PhotoServiceSuite) TestCreatePhotoFailure() {
 	}
 
 }
+
+func (s *PhotoServiceSuite) TestCreatePhotoSaveFailure() {
+	// 模拟描述为 fail 的图片保存数据失败
+	callbackName := "test:fail_photo"
+	s.Nil(s.db.Callback().Create().Before("gorm:create").Register(callbackName, func(tx *gorm.DB) {
+		if photo, ok := tx.Statement.Dest.(*model.Photo); ok && strings.HasPrefix(photo.Desc, "fail") {
+			tx.AddError(errors.New("save failed"))
+		}
+	}))
+	defer s.db.Callback().Create().Remove(callbackName)
+
+	params := make([]dto.CreatePhotoParam, 0, 2)
+	images := make([][]byte, 0, 2)
+	for i, desc := range []string{"fail", "ok"} {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf)
+		s.Nil(err)
+		param := dto.CreatePhotoParam{UploadID: uint(i), Desc: desc}
+		param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), desc)
+		params = append(params, param)
+		images = append(images, buf.Bytes())
+	}
+
+	failureResults := s.serv.CreatePhoto(params)
+	s.Equal([]dto.CreatePhotoFailedResult{{UploadID: 0, Message: "save failed"}}, failureResults)
+
+	var photos []model.Photo
+	s.Nil(s.db.Find(&photos).Error)
+	s.Len(photos, 1)
+	s.True(strings.HasPrefix(photos[0].Desc, "ok"))
+
+	// 保存失败的图片的文件已经删除
+	for i, data := range images {
+		sum := sha256.Sum256(data)
+		fileUri := imagemanager.CreateLocalFileUri(s.config.GetFilesPath(), hex.EncodeToString(sum[:]))
+		_, err := os.Stat(fileUri.GetOriginalFilePath())
+		s.Equal(i == 0, os.IsNotExist(err))
+	}
+}
+
+func (s *PhotoServiceSuite) TestCreatePhotoConcurrentDuplicate() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	buildParam := func(desc string) dto.CreatePhotoParam {
+		param := dto.CreatePhotoParam{UploadID: 1, Desc: desc}
+		param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), desc)
+		return param
+	}
+
+	// 保存数据失败前，其他任务上传相同的文件
+	concurrentResults := make(chan []dto.CreatePhotoFailedResult, 1)
+	callbackName := "test:concurrent_photo"
+	s.Nil(s.db.Callback().Create().Before("gorm:create").Register(callbackName, func(tx *gorm.DB) {
+		if photo, ok := tx.Statement.Dest.(*model.Photo); ok && strings.HasPrefix(photo.Desc, "fail") {
+			go func() {
+				concurrentResults <- s.serv.CreatePhoto([]dto.CreatePhotoParam{buildParam("ok")})
+			}()
+			// 正在上传的文件保存完成前等待
+			select {
+			case <-concurrentResults:
+				s.Fail("upload of the same file should wait")
+			case <-time.After(100 * time.Millisecond):
+			}
+			tx.AddError(errors.New("save failed"))
+		}
+	}))
+	failureResults := s.serv.CreatePhoto([]dto.CreatePhotoParam{buildParam("fail")})
+	s.Equal([]dto.CreatePhotoFailedResult{{UploadID: 1, Message: "save failed"}}, failureResults)
+	// 第一个任务失败后，等待的任务正常保存，文件没有被删除
+	s.Len(<-concurrentResults, 0)
+	s.Nil(s.db.Callback().Create().Remove(callbackName))
+	var photo model.Photo
+	s.Nil(s.db.First(&photo).Error)
+	s.True(strings.HasPrefix(photo.Desc, "ok"))
+	_, err = os.Stat(imagemanager.NewFileUri(s.config.GetFilesPath(), photo.Uri).GetOriginalFilePath())
+	s.Nil(err)
+
+	// 再次上传时数据库内已经存在
+	results := s.serv.CreatePhoto([]dto.CreatePhotoParam{buildParam("again")})
+	s.Len(results, 1)
+	s.True(results[0].Duplicate)
+}
+
+func (s *PhotoServiceSuite) TestCreatePhotoWithExif() {
+	expectedExif := &imagegen.Exif{
+		DateTimeOriginal: time.Date(2019, 10, 1, 8, 0, 0, 0, time.Local),
+		Make:             "Canon",
+		Model:            "EOS R5",
+		ISO:              100,
+	}
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf, imagegen.WithFormat(imagegen.FORMAT_JPEG), imagegen.WithExif(expectedExif))
+	s.Nil(err)
+
+	param := dto.CreatePhotoParam{UploadID: 1, Desc: "exif"}
+	param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "exif.jpg")
+	failureResults := s.serv.CreatePhoto([]dto.CreatePhotoParam{param})
+	s.Len(failureResults, 0)
+
+	photoDto, err := s.serv.GetPhotoById(1)
+	s.Nil(err)
+	s.True(expectedExif.DateTimeOriginal.Equal(photoDto.PhotoDate))
+	s.Equal(expectedExif.Make, photoDto.CameraMake)
+	s.Equal(expectedExif.Model, photoDto.CameraModel)
+	s.Equal(int64(expectedExif.ISO), photoDto.ISO)
+}
+
+func (s *PhotoServiceSuite) TestGeoPhotos() {
+	coordinates := [][2]float64{
+		{39.9042, 116.4074},
+		{39.9050, 116.4080},
+		{31.2304, 121.4737},
+		{-33.8568, 151.2153},
+		{35.6762, 179.9000},
+	}
+	for _, coordinate := range coordinates {
+		lat, lng := coordinate[0], coordinate[1]
+		s.db.Create(&model.Photo{PhotoDate: time.Now(), Latitude: &lat, Longitude: &lng})
+	}
+	s.db.Create(&model.Photo{PhotoDate: time.Now()})
+
+	ptr := func(v float64) *float64 { return &v }
+	zoom := 4
+
+	result, err := s.serv.GeoPhotos(dto.GeoParam{
+		MinLat: ptr(30), MaxLat: ptr(41), MinLng: ptr(110), MaxLng: ptr(125), Zoom: &zoom,
+	})
+	s.Nil(err)
+	s.Len(result.Photos, 3)
+	s.Len(result.Clusters, 2)
+	var total int64
+	for _, cluster := range result.Clusters {
+		total += cluster.Count
+	}
+	s.Equal(int64(3), total)
+
+	// 跨越 180 度经线
+	result, err = s.serv.GeoPhotos(dto.GeoParam{
+		MinLat: ptr(30), MaxLat: ptr(41), MinLng: ptr(179), MaxLng: ptr(-179),
+	})
+	s.Nil(err)
+	s.Len(result.Photos, 1)
+	s.Len(result.Clusters, 0)
+
+	_, err = s.serv.GeoPhotos(dto.GeoParam{
+		MinLat: ptr(41), MaxLat: ptr(30), MinLng: ptr(110), MaxLng: ptr(125),
+	})
+	s.NotNil(err)
+}
+
+func (s *PhotoServiceSuite) TestSetFavoriteAndRating() {
+	photos := []model.Photo{
+		{PhotoDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{PhotoDate: time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)},
+		{PhotoDate: time.Date(2024, 3, 3, 0, 0, 0, 0, time.Local)},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	id := func(i int) uint { return photos[i].ID }
+	favorite, rating := true, int64(4)
+
+	s.Nil(s.serv.SetFavorite(dto.PhotoFavoriteParam{PhotoIDs: []uint{id(0), id(2), id(2)}, Favorite: &favorite}))
+	s.Nil(s.serv.SetRating(dto.PhotoRatingParam{PhotoIDs: []uint{id(1)}, Rating: &rating}))
+	rating = 2
+	s.Nil(s.serv.SetRating(dto.PhotoRatingParam{PhotoIDs: []uint{id(2)}, Rating: &rating}))
+
+	photoDto, err := s.serv.GetPhotoById(id(0))
+	s.Nil(err)
+	s.True(photoDto.Favorite)
+	s.Equal(int64(0), photoDto.Rating)
+
+	// 有图片不存在时不修改
+	err = s.serv.SetFavorite(dto.PhotoFavoriteParam{PhotoIDs: []uint{id(1), 1000}, Favorite: &favorite})
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(400, appErr.Code)
+	photoDto, err = s.serv.GetPhotoById(id(1))
+	s.Nil(err)
+	s.False(photoDto.Favorite)
+
+	notFavorite := false
+	scenarios := []struct {
+		params   dto.PhotoPageParam
+		expected []uint
+	}{
+		{dto.PhotoPageParam{Favorite: &favorite}, []uint{id(2), id(0)}},
+		{dto.PhotoPageParam{Favorite: &notFavorite}, []uint{id(1)}},
+		{dto.PhotoPageParam{MinRating: 2}, []uint{id(2), id(1)}},
+		{dto.PhotoPageParam{Sort: service.PHOTO_SORT_RATING}, []uint{id(1), id(2), id(0)}},
+		{dto.PhotoPageParam{Sort: service.PHOTO_SORT_FAVORITE, Order: service.SORT_ORDER_ASC}, []uint{id(1), id(0), id(2)}},
+	}
+	for _, scenario := range scenarios {
+		result, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{Params: scenario.params, PageNum: 1, PageSize: 10})
+		s.Nil(err, scenario.params)
+		ids := make([]uint, 0, len(result.List))
+		for _, photoDto := range result.List {
+			ids = append(ids, photoDto.ID)
+		}
+		s.Equal(scenario.expected, ids, scenario.params)
+	}
+}
+
+func (s *PhotoServiceSuite) TestBatchPhotos() {
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	photos := []model.Photo{
+		{Desc: "beach", PhotoDate: date},
+		{Desc: "beach", PhotoDate: date},
+		{Desc: "mountain", PhotoDate: date},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	id := func(i int) uint { return photos[i].ID }
+	album := model.Album{Name: "trip"}
+	s.Nil(s.db.Create(&album).Error)
+	desc := "sea"
+
+	scenarios := []struct {
+		param    dto.BatchParam
+		expected []dto.BatchResult
+	}{
+		{dto.BatchParam{PhotoIDs: []uint{id(0), id(2)}, Op: service.BATCH_OP_SHIFT_DATE, Offset: "-24h"}, []dto.BatchResult{
+			{PhotoID: id(0), Success: true}, {PhotoID: id(2), Success: true},
+		}},
+		{dto.BatchParam{Query: "beach", Op: service.BATCH_OP_ADD_TAG, Tags: []string{"summer"}}, []dto.BatchResult{
+			{PhotoID: id(0), Success: true}, {PhotoID: id(1), Success: true},
+		}},
+		{dto.BatchParam{PhotoIDs: []uint{id(1), id(2)}, Query: "tag:summer", Op: service.BATCH_OP_ADD_TO_ALBUM, AlbumID: album.ID}, nil},
+		{dto.BatchParam{PhotoIDs: []uint{id(1)}, Op: service.BATCH_OP_ADD_TO_ALBUM, AlbumID: album.ID}, []dto.BatchResult{
+			{PhotoID: id(1), Success: true},
+		}},
+		{dto.BatchParam{PhotoIDs: []uint{id(0), 1000}, Op: service.BATCH_OP_SET_DESC, Desc: &desc}, nil},
+		{dto.BatchParam{Query: "mountain", Op: service.BATCH_OP_SET_DESC, Desc: &desc}, []dto.BatchResult{
+			{PhotoID: id(2), Success: true},
+		}},
+		{dto.BatchParam{Query: "tag:summer -beach", Op: service.BATCH_OP_DELETE}, []dto.BatchResult{}},
+		{dto.BatchParam{PhotoIDs: []uint{id(1)}, Op: service.BATCH_OP_DELETE}, []dto.BatchResult{
+			{PhotoID: id(1), Success: true},
+		}},
+	}
+	for _, scenario := range scenarios {
+		results, err := s.serv.BatchPhotos(scenario.param)
+		if scenario.expected != nil {
+			s.Nil(err, scenario.param)
+			s.Equal(scenario.expected, results, scenario.param)
+			continue
+		}
+		// 有图片失败时返回每张图片的结果
+		appErr, ok := err.(*application.AppError)
+		s.True(ok, scenario.param)
+		s.Equal(400, appErr.Code)
+		details, ok := appErr.Details.([]dto.BatchResult)
+		s.True(ok)
+		s.True(details[0].Success)
+		s.False(details[1].Success)
+	}
+
+	var actual []model.Photo
+	s.Nil(s.db.Unscoped().Order("id").Find(&actual).Error)
+	s.Equal(date.Add(-24*time.Hour).Unix(), actual[0].PhotoDate.Unix())
+	s.Equal(date.Unix(), actual[1].PhotoDate.Unix())
+	// 失败时没有修改
+	s.Equal("beach", actual[0].Desc)
+	s.Equal("sea", actual[2].Desc)
+	s.True(actual[1].DeletedAt.Valid)
+
+	var albumPhotoIDs []uint
+	s.Nil(s.db.Model(&model.AlbumPhoto{}).Order("photo_id").Pluck("photo_id", &albumPhotoIDs).Error)
+	s.Equal([]uint{id(1)}, albumPhotoIDs)
+	photoDto, err := s.serv.GetPhotoById(id(0))
+	s.Nil(err)
+	s.Equal([]string{"summer"}, photoDto.Tags)
+
+	for _, param := range []dto.BatchParam{
+		{Op: service.BATCH_OP_DELETE},
+		{PhotoIDs: []uint{id(0)}, Op: service.BATCH_OP_SHIFT_DATE, Offset: "1d"},
+		{PhotoIDs: []uint{id(0)}, Op: service.BATCH_OP_SET_DESC},
+		{PhotoIDs: []uint{id(0)}, Op: service.BATCH_OP_ADD_TAG, Tags: []string{" "}},
+		{PhotoIDs: []uint{id(0)}, Op: service.BATCH_OP_ADD_TO_ALBUM, AlbumID: 1000},
+		{Query: "width>", Op: service.BATCH_OP_DELETE},
+	} {
+		_, err := s.serv.BatchPhotos(param)
+		appErr, ok := err.(*application.AppError)
+		s.True(ok, param)
+		s.Equal(400, appErr.Code, param)
+	}
+}
+
+// 接近单次处理上限时分批执行，不超过 sqlite 的参数数量限制
+func (s *PhotoServiceSuite) TestBatchPhotosMaxPhotos() {
+	photos := make([]model.Photo, service.BATCH_MAX_PHOTOS)
+	for i := range photos {
+		photos[i].PhotoDate = time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	}
+	s.Nil(s.db.CreateInBatches(&photos, 1000).Error)
+	photoIDs := make([]uint, 0, len(photos))
+	for _, photo := range photos {
+		photoIDs = append(photoIDs, photo.ID)
+	}
+	album := model.Album{Name: "all"}
+	s.Nil(s.db.Create(&album).Error)
+	desc := "all"
+
+	for _, param := range []dto.BatchParam{
+		{PhotoIDs: photoIDs, Op: service.BATCH_OP_ADD_TO_ALBUM, AlbumID: album.ID},
+		{PhotoIDs: photoIDs, Op: service.BATCH_OP_ADD_TAG, Tags: []string{"a", "b"}},
+		{PhotoIDs: photoIDs, Op: service.BATCH_OP_SET_DESC, Desc: &desc},
+		{PhotoIDs: photoIDs, Op: service.BATCH_OP_DELETE},
+	} {
+		results, err := s.serv.BatchPhotos(param)
+		s.Nil(err, param.Op)
+		s.Len(results, service.BATCH_MAX_PHOTOS, param.Op)
+	}
+
+	var count int64
+	s.Nil(s.db.Model(&model.AlbumPhoto{}).Count(&count).Error)
+	s.Equal(int64(service.BATCH_MAX_PHOTOS), count)
+	s.Nil(s.db.Model(&model.PhotoTag{}).Count(&count).Error)
+	s.Equal(int64(2*service.BATCH_MAX_PHOTOS), count)
+	s.Nil(s.db.Unscoped().Model(&model.Photo{}).Where("`desc` = ? AND deleted_at IS NOT NULL", desc).Count(&count).Error)
+	s.Equal(int64(service.BATCH_MAX_PHOTOS), count)
+
+	// 超过上限时不查询数据库
+	_, err := s.serv.BatchPhotos(dto.BatchParam{PhotoIDs: append(photoIDs, photoIDs[len(photoIDs)-1]+1), Op: service.BATCH_OP_DELETE})
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(400, appErr.Code)
+}
+
+func (s *PhotoServiceSuite) TestPhotoPageQuery() {
+	lat, lng := 39.9042, 116.4074
+	photos := []model.Photo{
+		{Desc: "beach sunset", Format: "png", Width: 4000, Height: 3000, Size: 3 << 20,
+			PhotoDate: time.Date(2023, 7, 15, 18, 0, 0, 0, time.Local), CameraModel: "X100V", Latitude: &lat, Longitude: &lng,
+			Favorite: true, Rating: 5},
+		{Desc: "mountain", Format: "jpeg", Width: 1920, Height: 1080, Size: 500 << 10,
+			PhotoDate: time.Date(2023, 9, 1, 8, 0, 0, 0, time.Local), CameraMake: "Canon", ISO: 800},
+		{Desc: "private beach", Format: "jpeg", Width: 6000, Height: 4000, Size: 8 << 20,
+			PhotoDate: time.Date(2022, 6, 1, 8, 0, 0, 0, time.Local), CameraModel: "X100V", Rating: 3},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	tags := []model.Tag{{Name: "beach"}, {Name: "private"}}
+	s.Nil(s.db.Create(&tags).Error)
+	s.Nil(s.db.Create(&[]model.PhotoTag{
+		{PhotoID: photos[0].ID, TagID: tags[0].ID},
+		{PhotoID: photos[2].ID, TagID: tags[0].ID},
+		{PhotoID: photos[2].ID, TagID: tags[1].ID},
+	}).Error)
+
+	scenarios := []struct {
+		query    string
+		expected []uint
+	}{
+		{"tag:beach", []uint{photos[0].ID, photos[2].ID}},
+		{"tag:beach -tag:private", []uint{photos[0].ID}},
+		{"date:2023-06..2023-08", []uint{photos[0].ID}},
+		{"date>=2023", []uint{photos[0].ID, photos[1].ID}},
+		{"format:jpg width>3000", []uint{photos[2].ID}},
+		{`camera:"x100v" size>=2mb`, []uint{photos[0].ID, photos[2].ID}},
+		{"camera=canon iso:100..800", []uint{photos[1].ID}},
+		{"has:gps", []uint{photos[0].ID}},
+		{"-has:tag", []uint{photos[1].ID}},
+		{"has:favorite", []uint{photos[0].ID}},
+		{"rating>=3 -has:favorite", []uint{photos[2].ID}},
+		{"beach -private", []uint{photos[0].ID}},
+		{`"mountain" height<=1080`, []uint{photos[1].ID}},
+		{"date:2024", nil},
+	}
+	for _, scenario := range scenarios {
+		result, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{
+			Params:   dto.PhotoPageParam{Query: scenario.query},
+			PageNum:  1,
+			PageSize: 10,
+		})
+		if scenario.expected == nil {
+			s.Equal(application.ErrDataNotFound, err, scenario.query)
+			continue
+		}
+		s.Nil(err, scenario.query)
+		ids := make([]uint, 0, len(result.List))
+		for _, photoDto := range result.List {
+			ids = append(ids, photoDto.ID)
+		}
+		s.ElementsMatch(scenario.expected, ids, scenario.query)
+	}
+
+	_, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{
+		Params:   dto.PhotoPageParam{Query: "tag:beach width>abc"},
+		PageNum:  1,
+		PageSize: 10,
+	})
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(400, appErr.Code)
+	syntaxErr, ok := appErr.Details.(*search.SyntaxError)
+	s.True(ok)
+	s.Equal(17, syntaxErr.Pos)
+	s.Equal("abc", syntaxErr.Token)
+}
+
+func (s *PhotoServiceSuite) TestPhotoPageKeywords() {
+	photos := []model.Photo{
+		{Desc: "sunset at the beach", FileName: "IMG_0001.jpg", PhotoDate: time.Now()},
+		{Desc: "海边的日落", FileName: "IMG_0002.png", PhotoDate: time.Now()},
+		{Desc: "mountain", FileName: "DSC_1234.jpg", PhotoDate: time.Now()},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	tag := model.Tag{Name: "holiday"}
+	s.Nil(s.db.Create(&tag).Error)
+	s.Nil(s.db.Create(&model.PhotoTag{PhotoID: photos[2].ID, TagID: tag.ID}).Error)
+	// 修改描述后索引同步更新
+	s.Nil(s.db.Model(&photos[2]).Update("desc", "snowy mountain").Error)
+
+	scenarios := []struct {
+		q        string
+		expected []uint
+		snippet  string
+	}{
+		{"beach", []uint{photos[0].ID}, "sunset at the <mark>beach</mark>"},
+		{"SUNSET beach", []uint{photos[0].ID}, "<mark>sunset</mark> at the <mark>beach</mark>"},
+		{"img_0002", []uint{photos[1].ID}, "<mark>IMG_0002</mark>.png"},
+		{"日落", []uint{photos[1].ID}, "海边的<mark>日落</mark>"},
+		{"海边的", []uint{photos[1].ID}, "<mark>海边的</mark>日落"},
+		{"holiday", []uint{photos[2].ID}, "<mark>holiday</mark>"},
+		{"snowy", []uint{photos[2].ID}, "<mark>snowy</mark> mountain"},
+		{"IMG", []uint{photos[0].ID, photos[1].ID}, ""},
+		{"forest", nil, ""},
+	}
+	for _, scenario := range scenarios {
+		result, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{
+			Params:   dto.PhotoPageParam{Q: scenario.q},
+			PageNum:  1,
+			PageSize: 10,
+		})
+		if scenario.expected == nil {
+			s.Equal(application.ErrDataNotFound, err, scenario.q)
+			continue
+		}
+		s.Nil(err, scenario.q)
+		ids := make([]uint, 0, len(result.List))
+		for _, photoDto := range result.List {
+			ids = append(ids, photoDto.ID)
+			s.Contains(photoDto.Snippet, search.HIGHLIGHT_START, scenario.q)
+		}
+		s.ElementsMatch(scenario.expected, ids, scenario.q)
+		if scenario.snippet != "" {
+			s.Equal(scenario.snippet, result.List[0].Snippet, scenario.q)
+		}
+	}
+}
+
+func (s *PhotoServiceSuite) TestPhotoPageSort() {
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 12, 0, 0, 0, time.Local)
+	}
+	photos := []model.Photo{
+		{PhotoDate: day(2), Size: 300, Width: 1000},
+		{PhotoDate: day(1), Size: 100, Width: 3000},
+		{PhotoDate: day(3), Size: 200, Width: 2000},
+		// 相同的值按 id 排序
+		{PhotoDate: day(3), Size: 200, Width: 2000},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	id := func(i int) uint { return photos[i].ID }
+	// 日期参数只有年月日
+	from := time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)
+	to := time.Date(2024, 3, 3, 0, 0, 0, 0, time.Local)
+
+	scenarios := []struct {
+		params   dto.PhotoPageParam
+		expected []uint
+	}{
+		{dto.PhotoPageParam{}, []uint{id(3), id(2), id(0), id(1)}},
+		{dto.PhotoPageParam{Order: service.SORT_ORDER_ASC}, []uint{id(1), id(0), id(2), id(3)}},
+		{dto.PhotoPageParam{Sort: service.PHOTO_SORT_SIZE, Order: service.SORT_ORDER_ASC}, []uint{id(1), id(2), id(3), id(0)}},
+		{dto.PhotoPageParam{Sort: service.PHOTO_SORT_WIDTH}, []uint{id(1), id(3), id(2), id(0)}},
+		{dto.PhotoPageParam{Sort: service.PHOTO_SORT_CREATED_AT, Order: service.SORT_ORDER_ASC}, []uint{id(0), id(1), id(2), id(3)}},
+		{dto.PhotoPageParam{From: &from}, []uint{id(3), id(2), id(0)}},
+		{dto.PhotoPageParam{To: &from}, []uint{id(0), id(1)}},
+		{dto.PhotoPageParam{From: &to}, []uint{id(3), id(2)}},
+		{dto.PhotoPageParam{From: &from, To: &from}, []uint{id(0)}},
+	}
+	for _, scenario := range scenarios {
+		result, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{Params: scenario.params, PageNum: 1, PageSize: 10})
+		s.Nil(err, scenario.params)
+		ids := make([]uint, 0, len(result.List))
+		for _, photoDto := range result.List {
+			ids = append(ids, photoDto.ID)
+		}
+		s.Equal(scenario.expected, ids, scenario.params)
+	}
+
+	_, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{
+		Params:   dto.PhotoPageParam{From: &to, To: &from},
+		PageNum:  1,
+		PageSize: 10,
+	})
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(400, appErr.Code)
+}
+
+func (s *PhotoServiceSuite) TestPhotoCursorPage() {
+	// 包含纳秒和相同的拍摄时间
+	base := time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.Local)
+	photos := make([]model.Photo, 0, 7)
+	for i := range 7 {
+		photos = append(photos, model.Photo{PhotoDate: base.Add(time.Duration(i/2) * time.Hour), Size: int64(i % 3)})
+	}
+	s.Nil(s.db.Create(&photos).Error)
+
+	scenarios := []struct {
+		params   dto.PhotoPageParam
+		expected []uint
+	}{
+		{dto.PhotoPageParam{}, []uint{7, 6, 5, 4, 3, 2, 1}},
+		{dto.PhotoPageParam{Sort: service.PHOTO_SORT_SIZE, Order: service.SORT_ORDER_ASC}, []uint{1, 4, 7, 2, 5, 3, 6}},
+	}
+	for _, scenario := range scenarios {
+		expected := make([]uint, 0, len(scenario.expected))
+		for _, i := range scenario.expected {
+			expected = append(expected, photos[i-1].ID)
+		}
+		param := dto.CursorParam[dto.PhotoPageParam]{Params: scenario.params, Limit: 3, WithTotal: true}
+
+		// 向后翻页直到最后一页
+		var (
+			ids    []uint
+			pages  []*dto.CursorResult[dto.PhotoDto]
+			cursor string
+		)
+		for range 3 {
+			param.Cursor = cursor
+			result, err := s.serv.PhotoCursorPage(param)
+			s.Nil(err)
+			s.Equal(int64(7), *result.Total)
+			for _, photoDto := range result.List {
+				ids = append(ids, photoDto.ID)
+			}
+			pages = append(pages, result)
+			cursor = result.NextCursor
+		}
+		s.Equal(expected, ids, scenario.params)
+		s.Empty(pages[0].PrevCursor)
+		s.Empty(pages[2].NextCursor)
+
+		// 向前翻页和之前的结果相同
+		param.Cursor = pages[2].PrevCursor
+		result, err := s.serv.PhotoCursorPage(param)
+		s.Nil(err)
+		s.Equal(pages[1].List, result.List)
+		param.Cursor = result.PrevCursor
+		result, err = s.serv.PhotoCursorPage(param)
+		s.Nil(err)
+		s.Equal(pages[0].List, result.List)
+		s.Empty(result.PrevCursor)
+		s.NotEmpty(result.NextCursor)
+	}
+
+	// 最后一张图片删除后，最后一页返回空列表
+	param := dto.CursorParam[dto.PhotoPageParam]{Limit: 6}
+	first, err := s.serv.PhotoCursorPage(param)
+	s.Nil(err)
+	s.NotEmpty(first.NextCursor)
+	s.Nil(first.Total)
+	s.Nil(s.db.Delete(&photos[0]).Error)
+	param.Cursor = first.NextCursor
+	last, err := s.serv.PhotoCursorPage(param)
+	s.Nil(err)
+	s.Empty(last.List)
+	s.Empty(last.NextCursor)
+	s.NotEmpty(last.PrevCursor)
+
+	// cursor 和排序方式不一致或格式错误
+	for _, param := range []dto.CursorParam[dto.PhotoPageParam]{
+		{Params: dto.PhotoPageParam{Sort: service.PHOTO_SORT_SIZE}, Cursor: first.NextCursor},
+		{Params: dto.PhotoPageParam{Order: service.SORT_ORDER_ASC}, Cursor: first.NextCursor},
+		{Cursor: "not a cursor"},
+	} {
+		_, err := s.serv.PhotoCursorPage(param)
+		appErr, ok := err.(*application.AppError)
+		s.True(ok)
+		s.Equal(400, appErr.Code)
+	}
+}
+
+func (s *PhotoServiceSuite) TestPhotoTimeline() {
+	date := func(year int, month time.Month, day int, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.Local)
+	}
+	photos := []model.Photo{
+		{PhotoDate: date(2023, 12, 31, 23), Width: 100, Height: 100, Desc: "a"},
+		{PhotoDate: date(2024, 1, 1, 0), Width: 100, Height: 100, Desc: "b"},
+		{PhotoDate: date(2024, 1, 1, 8), Width: 400, Height: 300, Desc: "a"},
+		{PhotoDate: date(2024, 1, 20, 8), Width: 200, Height: 100, Desc: "a"},
+		{PhotoDate: date(2024, 3, 5, 8), Width: 200, Height: 100, Desc: "a"},
+	}
+	s.Nil(s.db.Create(&photos).Error)
+	id := func(i int) uint { return photos[i].ID }
+
+	scenarios := []struct {
+		param    dto.TimelineParam
+		expected []dto.TimelineBucket
+	}{
+		{dto.TimelineParam{Granularity: service.TIMELINE_YEAR}, []dto.TimelineBucket{
+			{Date: "2024", Count: 4, FirstPhotoID: id(4), LastPhotoID: id(1), CoverPhotoID: id(2)},
+			{Date: "2023", Count: 1, FirstPhotoID: id(0), LastPhotoID: id(0), CoverPhotoID: id(0)},
+		}},
+		// 默认按月
+		{dto.TimelineParam{}, []dto.TimelineBucket{
+			{Date: "2024-03", Count: 1, FirstPhotoID: id(4), LastPhotoID: id(4), CoverPhotoID: id(4)},
+			{Date: "2024-01", Count: 3, FirstPhotoID: id(3), LastPhotoID: id(1), CoverPhotoID: id(2)},
+			{Date: "2023-12", Count: 1, FirstPhotoID: id(0), LastPhotoID: id(0), CoverPhotoID: id(0)},
+		}},
+		{dto.TimelineParam{Granularity: service.TIMELINE_DAY, Params: dto.PhotoPageParam{Order: service.SORT_ORDER_ASC}}, []dto.TimelineBucket{
+			{Date: "2023-12-31", Count: 1, FirstPhotoID: id(0), LastPhotoID: id(0), CoverPhotoID: id(0)},
+			{Date: "2024-01-01", Count: 2, FirstPhotoID: id(1), LastPhotoID: id(2), CoverPhotoID: id(2)},
+			{Date: "2024-01-20", Count: 1, FirstPhotoID: id(3), LastPhotoID: id(3), CoverPhotoID: id(3)},
+			{Date: "2024-03-05", Count: 1, FirstPhotoID: id(4), LastPhotoID: id(4), CoverPhotoID: id(4)},
+		}},
+		// 使用图片列表的过滤条件
+		{dto.TimelineParam{Granularity: service.TIMELINE_YEAR, Params: dto.PhotoPageParam{Desc: "b"}}, []dto.TimelineBucket{
+			{Date: "2024", Count: 1, FirstPhotoID: id(1), LastPhotoID: id(1), CoverPhotoID: id(1)},
+		}},
+		{dto.TimelineParam{Params: dto.PhotoPageParam{Desc: "c"}}, []dto.TimelineBucket{}},
+	}
+	for _, scenario := range scenarios {
+		buckets, err := s.serv.PhotoTimeline(scenario.param)
+		s.Nil(err)
+		s.Equal(scenario.expected, buckets, scenario.param)
+	}
+}
+
+func (s *PhotoServiceSuite) TestPhotoMemories() {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.Local)
+	}
+	photos := []model.Photo{
+		{PhotoDate: date(2022, 1, 2)},
+		{PhotoDate: date(2022, 1, 5)},
+		// 跨年的范围属于 2023 年
+		{PhotoDate: date(2022, 12, 30)},
+		{PhotoDate: date(2023, 1, 1)},
+		// 今年的图片不包含在回忆内
+		{PhotoDate: date(2024, 1, 1)},
+	}
+	for range 5 {
+		photos = append(photos, model.Photo{PhotoDate: date(2021, 1, 1)})
+	}
+	// 拍摄时间很早的图片
+	photos = append(photos, model.Photo{PhotoDate: date(1, 1, 1)})
+	s.Nil(s.db.Create(&photos).Error)
+	id := func(i int) uint { return photos[i].ID }
+	today := date(2024, 1, 1)
+
+	groupIDs := func(groups []dto.MemoryGroup) map[int][]uint {
+		result := make(map[int][]uint)
+		for _, group := range groups {
+			for _, photoDto := range group.Photos {
+				result[group.Year] = append(result[group.Year], photoDto.ID)
+			}
+		}
+		return result
+	}
+
+	window := 0
+	groups, err := s.serv.PhotoMemories(dto.MemoryParam{Date: &today, Window: &window})
+	s.Nil(err)
+	s.Equal(map[int][]uint{2023: {id(3)}, 2021: {id(5), id(6), id(7), id(8), id(9)}, 1: {id(10)}}, groupIDs(groups))
+	s.Equal(1, groups[0].YearsAgo)
+	s.Equal(3, groups[1].YearsAgo)
+	s.Equal(2023, groups[2].YearsAgo)
+
+	// 默认使用配置的前后 3 天
+	groups, err = s.serv.PhotoMemories(dto.MemoryParam{Date: &today})
+	s.Nil(err)
+	s.Equal(map[int][]uint{
+		2023: {id(2), id(3)},
+		2022: {id(0)},
+		2021: {id(5), id(6), id(7), id(8), id(9)},
+		1:    {id(10)},
+	}, groupIDs(groups))
+
+	// 限制数量时，同一天选择的图片相同
+	groups, err = s.serv.PhotoMemories(dto.MemoryParam{Date: &today, Limit: 2})
+	s.Nil(err)
+	s.Len(groups[2].Photos, 2)
+	s.Equal(5, groups[2].Count)
+	again, err := s.serv.PhotoMemories(dto.MemoryParam{Date: &today, Limit: 2})
+	s.Nil(err)
+	s.Equal(groups, again)
+
+	// 没有往年的图片
+	past := date(2000, 6, 1)
+	groups, err = s.serv.PhotoMemories(dto.MemoryParam{Date: &past})
+	s.Nil(err)
+	s.Empty(groups)
+}
+
+func (s *PhotoServiceSuite) TestRebuildFullTextSearch() {
+	if !s.db.FTSEnabled() {
+		s.T().Skip("sqlite fts5 is not enabled")
+	}
+	s.Nil(s.db.Create(&model.Photo{Desc: "sunset at the beach", PhotoDate: time.Now()}).Error)
+	s.Nil(s.db.Exec("DELETE FROM " + database.FTS_TABLE).Error)
+	_, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{Params: dto.PhotoPageParam{Q: "beach"}, PageNum: 1, PageSize: 10})
+	s.Equal(application.ErrDataNotFound, err)
+
+	count, err := s.db.RebuildFullTextSearch()
+	s.Nil(err)
+	s.Equal(int64(1), count)
+	result, err := s.serv.PhotoPage(dto.PageParam[dto.PhotoPageParam]{Params: dto.PhotoPageParam{Q: "beach"}, PageNum: 1, PageSize: 10})
+	s.Nil(err)
+	s.Len(result.List, 1)
+}
+
+func (s *PhotoServiceSuite) TestGetPhotoRendition() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+
+	param := dto.CreatePhotoParam{UploadID: 1}
+	param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "rendition")
+	s.Len(s.serv.CreatePhoto([]dto.CreatePhotoParam{param}), 0)
+
+	rc, imgInfo, err := s.serv.GetPhotoRendition(1, imagemanager.RENDITION_THUMB)
+	s.Nil(err)
+	defer rc.Close()
+	config, format, err := image.DecodeConfig(rc)
+	s.Nil(err)
+	s.Equal(format, imgInfo.Format)
+	s.LessOrEqual(max(config.Width, config.Height), 256)
+
+	_, _, err = s.serv.GetPhotoRendition(1, "unknown")
+	s.NotNil(err)
+	_, _, err = s.serv.GetPhotoRendition(2, imagemanager.RENDITION_THUMB)
+	s.Equal(application.ErrDataNotFound, err)
+}
+
+func (s *PhotoServiceSuite) TestRenderPhoto() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf, imagegen.WithFormat(imagegen.FORMAT_PNG))
+	s.Nil(err)
+
+	param := dto.CreatePhotoParam{UploadID: 1}
+	param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "render")
+	s.Len(s.serv.CreatePhoto([]dto.CreatePhotoParam{param}), 0)
+
+	renderParam := dto.RenderParam{Width: 300, Height: 200, Fit: imagemanager.FIT_COVER, Format: "jpeg"}
+	for range 2 {
+		rc, imgInfo, err := s.serv.RenderPhoto(1, renderParam)
+		s.Nil(err)
+		data, err := io.ReadAll(rc)
+		s.Nil(err)
+		rc.Close()
+		s.Equal(int64(len(data)), imgInfo.Size)
+
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		s.Nil(err)
+		s.Equal("jpeg", format)
+		s.Equal(300, config.Width)
+		s.Equal(200, config.Height)
+	}
+
+	_, _, err = s.serv.RenderPhoto(2, renderParam)
+	s.Equal(application.ErrDataNotFound, err)
+}
+
+func (s *PhotoServiceSuite) TestRenderETag() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf, imagegen.WithFormat(imagegen.FORMAT_PNG))
+	s.Nil(err)
+
+	param := dto.CreatePhotoParam{UploadID: 1}
+	param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "etag")
+	s.Len(s.serv.CreatePhoto([]dto.CreatePhotoParam{param}), 0)
+
+	etag, err := s.serv.RenderETag(1, dto.RenderParam{Width: 300})
+	s.Nil(err)
+	var photo model.Photo
+	s.Nil(s.db.First(&photo, 1).Error)
+	s.Contains(etag, photo.Sum)
+
+	// 补全默认值后相同的参数 ETag 相同
+	normalized, err := s.serv.RenderETag(1, dto.RenderParam{Width: 300, Fit: imagemanager.FIT_CONTAIN, Format: "png", Quality: 80})
+	s.Nil(err)
+	s.Equal(etag, normalized)
+	other, err := s.serv.RenderETag(1, dto.RenderParam{Width: 200})
+	s.Nil(err)
+	s.NotEqual(etag, other)
+
+	_, err = s.serv.RenderETag(2, dto.RenderParam{Width: 300})
+	s.Equal(application.ErrDataNotFound, err)
+}
+
+func (s *PhotoServiceSuite) TestDuplicatePhotos() {
+	genParam := func(uploadID uint, opts ...imagegen.Option) dto.CreatePhotoParam {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf, opts...)
+		s.Nil(err)
+		param := dto.CreatePhotoParam{UploadID: uploadID}
+		param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "duplicate")
+		return param
+	}
+	// 同一张图片的不同尺寸和格式，以及一张不同的图片
+	params := []dto.CreatePhotoParam{
+		genParam(1, imagegen.WithStyle(&imagegen.StyleBlocks{Seed: 1}), imagegen.WithSize(1920, 1080), imagegen.WithFormat(imagegen.FORMAT_PNG)),
+		genParam(2, imagegen.WithStyle(&imagegen.StyleBlocks{Seed: 1}), imagegen.WithSize(1280, 800), imagegen.WithFormat(imagegen.FORMAT_JPEG)),
+		genParam(3, imagegen.WithStyle(&imagegen.StyleBlocks{Seed: 2}), imagegen.WithSize(1920, 1080), imagegen.WithFormat(imagegen.FORMAT_PNG)),
+	}
+	s.Len(s.serv.CreatePhoto(params), 0)
+
+	clusters, err := s.serv.DuplicatePhotos(dto.DuplicateParam{})
+	s.Nil(err)
+	s.Len(clusters, 1)
+	s.Len(clusters[0].Photos, 2)
+	for _, photo := range clusters[0].Photos {
+		s.Len(photo.PHash, 16)
+	}
+
+	distance := 0
+	clusters, err = s.serv.DuplicatePhotos(dto.DuplicateParam{Distance: &distance})
+	s.Nil(err)
+	for _, cluster := range clusters {
+		s.Equal(cluster.Photos[0].PHash, cluster.Photos[1].PHash)
+	}
+}
+
+func (s *PhotoServiceSuite) TestBackfillPerceptualHash() {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf, imagegen.WithStyle(&imagegen.StyleBlocks{Seed: 3}))
+	s.Nil(err)
+	param := dto.CreatePhotoParam{UploadID: 1}
+	param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "backfill")
+	s.Len(s.serv.CreatePhoto([]dto.CreatePhotoParam{param}), 0)
+
+	var photo model.Photo
+	s.Nil(s.db.First(&photo).Error)
+	expectedHash, err := imagemanager.ParseHash(photo.PHash)
+	s.Nil(err)
+
+	// 模拟之前上传的没有感知哈希的图片
+	s.Nil(s.db.Model(&photo).UpdateColumn("p_hash", nil).Error)
+	updated, err := s.serv.BackfillPerceptualHash()
+	s.Nil(err)
+	s.Equal(1, updated)
+
+	s.Nil(s.db.First(&photo, photo.ID).Error)
+	actualHash, err := imagemanager.ParseHash(photo.PHash)
+	s.Nil(err)
+	// thumb 文件经过了 jpeg 压缩，哈希值可能有少量差异
+	s.LessOrEqual(imagemanager.HammingDistance(expectedHash, actualHash), 2)
+
+	updated, err = s.serv.BackfillPerceptualHash()
+	s.Nil(err)
+	s.Equal(0, updated)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/follow1123/photos/model/dto"
+)
+
+const (
+	TIMELINE_YEAR  = "year"
+	TIMELINE_MONTH = "month"
+	TIMELINE_DAY   = "day"
+)
+
+// 时间段对应拍摄时间的前缀长度，拍摄时间按本地时间保存，如 2006-01-02 15:04:05+08:00
+// 不使用 strftime，strftime 会把时间转换为 UTC
+var timelinePrefixLength = map[string]int{
+	TIMELINE_YEAR:  len("2006"),
+	TIMELINE_MONTH: len("2006-01"),
+	TIMELINE_DAY:   len("2006-01-02"),
+}
+
+func (ps *photoService) PhotoTimeline(param dto.TimelineParam) ([]dto.TimelineBucket, error) {
+	length, ok := timelinePrefixLength[param.Granularity]
+	if !ok {
+		length = timelinePrefixLength[TIMELINE_MONTH]
+	}
+	query, err := ps.filterPhotos(param.Params)
+	if err != nil {
+		return nil, err
+	}
+	photos := query.Select(fmt.Sprintf(
+		"photos.id, photos.photo_date, photos.width * photos.height AS pixels, substr(photos.photo_date, 1, %d) AS date",
+		length,
+	))
+
+	first, last := SORT_ORDER_DESC, SORT_ORDER_ASC
+	if param.Params.Order == SORT_ORDER_ASC {
+		first, last = last, first
+	}
+	// 同一个时间段的每一行窗口函数的结果都相同，去重后每个时间段一行
+	buckets := []dto.TimelineBucket{}
+	result := ps.db.Table("(?) AS t", photos).
+		Distinct(
+			"date",
+			"COUNT(*) OVER (PARTITION BY date) AS count",
+			fmt.Sprintf("FIRST_VALUE(id) OVER (PARTITION BY date ORDER BY photo_date %s, id %s) AS first_photo_id", first, first),
+			fmt.Sprintf("FIRST_VALUE(id) OVER (PARTITION BY date ORDER BY photo_date %s, id %s) AS last_photo_id", last, last),
+			"FIRST_VALUE(id) OVER (PARTITION BY date ORDER BY pixels DESC, id) AS cover_photo_id",
+		).
+		Order("date " + first).
+		Find(&buckets)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return buckets, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TAG_DEFAULT_LIMIT = 10
+	// 按标签过滤的方式
+	TAG_MODE_ANY = "any"
+	TAG_MODE_ALL = "all"
+)
+
+type TagService interface {
+	SearchTags(dto.TagSearchParam) ([]dto.TagDto, error)
+	AddTags(dto.PhotoTagsParam) error
+	RemoveTags(dto.PhotoTagsParam) error
+}
+
+type tagService struct {
+	logger.AppLogger
+	ctx *application.AppContext
+	db  *database.SqliteDB
+}
+
+func NewTagService(ctx *application.AppContext, db *database.SqliteDB) TagService {
+	return &tagService{ctx: ctx, db: db, AppLogger: *ctx.GetLogger()}
+}
+
+// SearchTags 按前缀查找标签，按照使用的次数排序，不包含只被回收站内图片使用的标签
+func (ts *tagService) SearchTags(param dto.TagSearchParam) ([]dto.TagDto, error) {
+	limit := param.Limit
+	if limit == 0 {
+		limit = TAG_DEFAULT_LIMIT
+	}
+
+	tagDtoList := make([]dto.TagDto, 0)
+	query := ts.db.Model(&model.Tag{}).
+		Select("tags.id, tags.name, COUNT(*) AS count").
+		Joins("JOIN photo_tags ON photo_tags.tag_id = tags.id").
+		Joins("JOIN photos ON photos.id = photo_tags.photo_id AND photos.deleted_at IS NULL")
+	if prefix := strings.TrimSpace(param.Prefix); prefix != "" {
+		query = query.Where(`tags.name LIKE ? ESCAPE '\'`, EscapeLike(prefix)+"%")
+	}
+	result := query.
+		Group("tags.id").
+		Order("count desc, tags.name").
+		Limit(limit).
+		Scan(&tagDtoList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return tagDtoList, nil
+}
+
+// AddTags 给图片添加标签，不存在的标签自动创建
+func (ts *tagService) AddTags(param dto.PhotoTagsParam) error {
+	names, err := NormalizeTags(param.Tags)
+	if err != nil {
+		return err
+	}
+	photoIDs := uniqueIds(param.PhotoIDs)
+
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if result := tx.Model(&model.Photo{}).Where("id IN ?", photoIDs).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count != int64(len(photoIDs)) {
+			return application.NewAppError(http.StatusBadRequest, "添加标签的图片不存在")
+		}
+		return AddPhotoTags(tx, photoIDs, names)
+	})
+}
+
+// AddPhotoTags 给已经存在的图片添加标签，标签需要先使用 NormalizeTags 处理
+func AddPhotoTags(tx *gorm.DB, photoIDs []uint, names []string) error {
+	tags := make([]model.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, model.Tag{Name: name})
+	}
+	if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags); result.Error != nil {
+		return result.Error
+	}
+	var tagIDs []uint
+	if result := tx.Model(&model.Tag{}).Where("name IN ?", names).Pluck("id", &tagIDs); result.Error != nil {
+		return result.Error
+	}
+
+	photoTags := make([]model.PhotoTag, 0, len(photoIDs)*len(tagIDs))
+	for _, photoID := range photoIDs {
+		for _, tagID := range tagIDs {
+			photoTags = append(photoTags, model.PhotoTag{PhotoID: photoID, TagID: tagID})
+		}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&photoTags, SQL_INSERT_BATCH_SIZE).Error
+}
+
+// RemoveTags 删除图片的标签，没有图片使用的标签同时删除
+func (ts *tagService) RemoveTags(param dto.PhotoTagsParam) error {
+	names, err := NormalizeTags(param.Tags)
+	if err != nil {
+		return err
+	}
+
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("photo_id IN ?", param.PhotoIDs).
+			Where("tag_id IN (?)", tx.Model(&model.Tag{}).Select("id").Where("name IN ?", names)).
+			Delete(&model.PhotoTag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		return DeleteUnusedTags(tx)
+	})
+}
+
+// DeleteUnusedTags 删除没有图片使用的标签
+func DeleteUnusedTags(tx *gorm.DB) error {
+	return tx.
+		Where("id NOT IN (?)", tx.Model(&model.PhotoTag{}).Select("tag_id")).
+		Delete(&model.Tag{}).Error
+}
+
+// NormalizeTags 去除标签两端的空白，忽略大小写去重
+func NormalizeTags(tags []string) ([]string, error) {
+	names := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		name := strings.TrimSpace(tag)
+		if name == "" {
+			return nil, application.NewAppError(http.StatusBadRequest, "标签不能为空")
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// FillPhotoTags 查询图片的标签，按标签名排序
+func FillPhotoTags(db *gorm.DB, photoDtoList []dto.PhotoDto) error {
+	if len(photoDtoList) == 0 {
+		return nil
+	}
+	photoIDs := make([]uint, 0, len(photoDtoList))
+	for _, photoDto := range photoDtoList {
+		photoIDs = append(photoIDs, photoDto.ID)
+	}
+
+	var rows []struct {
+		PhotoID uint
+		Name    string
+	}
+	result := db.Model(&model.PhotoTag{}).
+		Select("photo_tags.photo_id, tags.name").
+		Joins("JOIN tags ON tags.id = photo_tags.tag_id").
+		Where("photo_tags.photo_id IN ?", photoIDs).
+		Order("tags.name").
+		Scan(&rows)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	tagMap := make(map[uint][]string, len(photoDtoList))
+	for _, row := range rows {
+		tagMap[row.PhotoID] = append(tagMap[row.PhotoID], row.Name)
+	}
+	for i := range photoDtoList {
+		photoDtoList[i].Tags = tagMap[photoDtoList[i].ID]
+	}
+	return nil
+}
+
+// EscapeLike 转义 LIKE 内的通配符，需要配合 ESCAPE '\' 使用
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
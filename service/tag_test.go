@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/stretchr/testify/suite"
+)
+
+type TagServiceSuite struct {
+	suite.Suite
+	photoServ service.PhotoService
+	trashServ service.TrashService
+	serv      service.TagService
+	db        *database.SqliteDB
+	config    *config.Config
+}
+
+func TestTagServiceSuite(t *testing.T) {
+	suite.Run(t, &TagServiceSuite{})
+}
+
+func (s *TagServiceSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	db, err := appgen.GenDatabase(appComponents)
+	s.Nil(err)
+
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.Nil(migrator.InitOrMigrate())
+
+	s.photoServ = service.NewPhotoService(ctx, db)
+	s.trashServ = service.NewTrashService(ctx, db)
+	s.serv = service.NewTagService(ctx, db)
+	s.db = db
+	s.config = appComponents.Config
+}
+
+func (s *TagServiceSuite) TearDownSuite() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+func (s *TagServiceSuite) SetupTest() {
+	s.db.Migrator().CreateTable(&model.Photo{}, &model.Tag{}, &model.PhotoTag{})
+}
+
+func (s *TagServiceSuite) TearDownTest() {
+	s.db.Migrator().DropTable(&model.Photo{}, &model.Tag{}, &model.PhotoTag{})
+}
+
+func (s *TagServiceSuite) createPhotos(n int) []uint {
+	params := make([]dto.CreatePhotoParam, 0, n)
+	for i := range n {
+		buf := new(bytes.Buffer)
+		_, err := imagegen.GenImage(buf)
+		s.Nil(err)
+		param := dto.CreatePhotoParam{UploadID: uint(i)}
+		param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(buf.Bytes()), "tag")
+		params = append(params, param)
+	}
+	s.Len(s.photoServ.CreatePhoto(params), 0)
+
+	var ids []uint
+	s.Nil(s.db.Model(&model.Photo{}).Order("id").Pluck("id", &ids).Error)
+	return ids
+}
+
+func (s *TagServiceSuite) pageIds(params dto.PhotoPageParam) []uint {
+	result, err := s.photoServ.PhotoPage(dto.PageParam[dto.PhotoPageParam]{Params: params, PageNum: 1, PageSize: 100})
+	if err == application.ErrDataNotFound {
+		return nil
+	}
+	s.Nil(err)
+	ids := make([]uint, 0, len(result.List))
+	for _, photoDto := range result.List {
+		ids = append(ids, photoDto.ID)
+	}
+	return ids
+}
+
+func (s *TagServiceSuite) TestAddTags() {
+	ids := s.createPhotos(2)
+
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids, Tags: []string{"travel", " Beach ", "beach"}}))
+	// 重复添加不会报错
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids[:1], Tags: []string{"TRAVEL", "sea"}}))
+
+	photoDto, err := s.photoServ.GetPhotoById(ids[0])
+	s.Nil(err)
+	s.Equal([]string{"Beach", "sea", "travel"}, photoDto.Tags)
+	photoDto, err = s.photoServ.GetPhotoById(ids[1])
+	s.Nil(err)
+	s.Equal([]string{"Beach", "travel"}, photoDto.Tags)
+
+	scenarios := []dto.PhotoTagsParam{
+		{PhotoIDs: []uint{ids[1] + 100}, Tags: []string{"travel"}},
+		{PhotoIDs: ids, Tags: []string{" "}},
+	}
+	for _, scenario := range scenarios {
+		appErr, ok := s.serv.AddTags(scenario).(*application.AppError)
+		s.True(ok)
+		s.Equal(http.StatusBadRequest, appErr.Code)
+	}
+}
+
+func (s *TagServiceSuite) TestRemoveTags() {
+	ids := s.createPhotos(2)
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids, Tags: []string{"travel", "beach"}}))
+
+	s.Nil(s.serv.RemoveTags(dto.PhotoTagsParam{PhotoIDs: ids[:1], Tags: []string{"Travel"}}))
+	photoDto, err := s.photoServ.GetPhotoById(ids[0])
+	s.Nil(err)
+	s.Equal([]string{"beach"}, photoDto.Tags)
+
+	// 没有图片使用的标签被删除
+	s.Nil(s.serv.RemoveTags(dto.PhotoTagsParam{PhotoIDs: ids, Tags: []string{"beach"}}))
+	var count int64
+	s.db.Model(&model.Tag{}).Where("name = ?", "beach").Count(&count)
+	s.Equal(int64(0), count)
+}
+
+func (s *TagServiceSuite) TestSearchTags() {
+	ids := s.createPhotos(3)
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids, Tags: []string{"paris"}}))
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids[:2], Tags: []string{"party"}}))
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids[:1], Tags: []string{"pa_rk", "beach"}}))
+
+	scenarios := []struct {
+		param    dto.TagSearchParam
+		expected []dto.TagDto
+	}{
+		{dto.TagSearchParam{Prefix: "PA"}, []dto.TagDto{{Name: "paris", Count: 3}, {Name: "party", Count: 2}, {Name: "pa_rk", Count: 1}}},
+		{dto.TagSearchParam{Prefix: "pa_"}, []dto.TagDto{{Name: "pa_rk", Count: 1}}},
+		{dto.TagSearchParam{Limit: 1}, []dto.TagDto{{Name: "paris", Count: 3}}},
+		{dto.TagSearchParam{Prefix: "x"}, []dto.TagDto{}},
+	}
+	for _, scenario := range scenarios {
+		tagDtoList, err := s.serv.SearchTags(scenario.param)
+		s.Nil(err)
+		s.Len(tagDtoList, len(scenario.expected))
+		for i, expected := range scenario.expected {
+			s.Equal(expected.Name, tagDtoList[i].Name)
+			s.Equal(expected.Count, tagDtoList[i].Count)
+		}
+	}
+
+	// 回收站内的图片不计数
+	s.Nil(s.photoServ.DeletePhoto(ids[0]))
+	tagDtoList, err := s.serv.SearchTags(dto.TagSearchParam{Prefix: "paris"})
+	s.Nil(err)
+	s.Equal(int64(2), tagDtoList[0].Count)
+	tagDtoList, err = s.serv.SearchTags(dto.TagSearchParam{Prefix: "beach"})
+	s.Nil(err)
+	s.Len(tagDtoList, 0)
+
+	// 彻底删除后删除没有使用的标签
+	s.Nil(s.trashServ.DeletePhoto(ids[0]))
+	var count int64
+	s.db.Model(&model.Tag{}).Where("name = ?", "beach").Count(&count)
+	s.Equal(int64(0), count)
+}
+
+func (s *TagServiceSuite) TestPhotoPageByTags() {
+	ids := s.createPhotos(3)
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids[:2], Tags: []string{"travel"}}))
+	s.Nil(s.serv.AddTags(dto.PhotoTagsParam{PhotoIDs: ids[1:], Tags: []string{"beach"}}))
+
+	scenarios := []struct {
+		params   dto.PhotoPageParam
+		expected []uint
+	}{
+		{dto.PhotoPageParam{Tags: []string{"travel"}}, ids[:2]},
+		{dto.PhotoPageParam{Tags: []string{"Travel", "beach"}}, ids},
+		{dto.PhotoPageParam{Tags: []string{"travel", "beach"}, TagMode: service.TAG_MODE_ANY}, ids},
+		{dto.PhotoPageParam{Tags: []string{"travel", "beach"}, TagMode: service.TAG_MODE_ALL}, ids[1:2]},
+		{dto.PhotoPageParam{Tags: []string{"travel", "travel"}, TagMode: service.TAG_MODE_ALL}, ids[:2]},
+		{dto.PhotoPageParam{Tags: []string{"sea"}}, nil},
+	}
+	for _, scenario := range scenarios {
+		s.ElementsMatch(scenario.expected, s.pageIds(scenario.params), scenario.params)
+	}
+}
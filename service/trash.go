@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"gorm.io/gorm"
+)
+
+const (
+	TRASH_DEFAULT_PAGE_SIZE = 20
+	// 回收站自动清理的间隔
+	TRASH_PURGE_INTERVAL = time.Hour
+)
+
+// TrashService 回收站，删除的图片只标记删除时间，彻底删除时才删除文件
+type TrashService interface {
+	TrashPage(dto.PageParam[struct{}]) (*dto.PageResult[dto.TrashPhotoDto], error)
+	RestorePhoto(uint) (*dto.PhotoDto, error)
+	DeletePhoto(uint) error
+	PurgeExpired() (int, error)
+}
+
+type trashService struct {
+	logger.AppLogger
+	ctx *application.AppContext
+	db  *database.SqliteDB
+}
+
+func NewTrashService(ctx *application.AppContext, db *database.SqliteDB) TrashService {
+	return &trashService{ctx: ctx, db: db, AppLogger: *ctx.GetLogger()}
+}
+
+func (ts *trashService) trashQuery() *gorm.DB {
+	return ts.db.Unscoped().Model(&model.Photo{}).Where("deleted_at IS NOT NULL")
+}
+
+func (ts *trashService) takeTrashPhoto(id uint) (*model.Photo, error) {
+	var photo model.Photo
+	if result := ts.trashQuery().Where("id = ?", id).Take(&photo); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, application.ErrDataNotFound
+		}
+		return nil, result.Error
+	}
+	return &photo, nil
+}
+
+func (ts *trashService) TrashPage(pageParam dto.PageParam[struct{}]) (*dto.PageResult[dto.TrashPhotoDto], error) {
+	if pageParam.PageNum <= 0 {
+		pageParam.PageNum = 1
+	}
+	if pageParam.PageSize <= 0 {
+		pageParam.PageSize = TRASH_DEFAULT_PAGE_SIZE
+	}
+
+	var (
+		trashDtoList []dto.TrashPhotoDto
+		total        int64
+	)
+	if result := ts.trashQuery().Count(&total); result.Error != nil {
+		return nil, result.Error
+	}
+	result := ts.trashQuery().
+		Order("deleted_at desc").
+		Offset((pageParam.PageNum - 1) * pageParam.PageSize).
+		Limit(pageParam.PageSize).
+		Find(&trashDtoList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(trashDtoList) == 0 {
+		return nil, application.ErrDataNotFound
+	}
+
+	retention := ts.ctx.GetConfig().GetTrashRetention()
+	if retention > 0 {
+		for i := range trashDtoList {
+			purgeAt := trashDtoList[i].DeletedAt.Add(retention)
+			trashDtoList[i].PurgeAt = &purgeAt
+		}
+	}
+
+	return &dto.PageResult[dto.TrashPhotoDto]{
+		List:     trashDtoList,
+		PageNum:  pageParam.PageNum,
+		PageSize: pageParam.PageSize,
+		Total:    total,
+	}, nil
+}
+
+func (ts *trashService) RestorePhoto(id uint) (*dto.PhotoDto, error) {
+	photo, err := ts.takeTrashPhoto(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删除后又上传了相同的图片时不能恢复
+	var count int64
+	if result := ts.db.Model(&model.Photo{}).Where("sum = ?", photo.Sum).Count(&count); result.Error != nil {
+		return nil, result.Error
+	}
+	if count > 0 {
+		return nil, application.NewAppError(http.StatusConflict, "已经存在相同的图片，无法恢复")
+	}
+
+	if result := ts.db.Unscoped().Model(photo).UpdateColumn("deleted_at", nil); result.Error != nil {
+		return nil, result.Error
+	}
+	photo.DeletedAt = gorm.DeletedAt{}
+	photoDto := &dto.PhotoDto{}
+	photoDto.Update(photo)
+	return photoDto, nil
+}
+
+// DeletePhoto 彻底删除回收站内的图片，没有其他数据使用图片文件时同时删除文件
+func (ts *trashService) DeletePhoto(id uint) error {
+	photo, err := ts.takeTrashPhoto(id)
+	if err != nil {
+		return err
+	}
+	return ts.purge(photo)
+}
+
+// purge 删除数据和文件，文件删除失败时回滚
+// 正在上传相同内容的图片时等待上传完成，上传的图片可能使用已经存在的文件
+func (ts *trashService) purge(photo *model.Photo) error {
+	if photo.Sum != "" {
+		contentLock := ts.ctx.GetImageManager().GetContentLock()
+		contentLock.Lock(photo.Sum)
+		defer contentLock.Unlock(photo.Sum)
+	}
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Unscoped().Delete(photo); result.Error != nil {
+			return result.Error
+		}
+		// 从相册内移除，作为封面时使用默认封面
+		if result := tx.Where("photo_id = ?", photo.ID).Delete(&model.AlbumPhoto{}); result.Error != nil {
+			return result.Error
+		}
+		result := tx.Model(&model.Album{}).Where("cover_photo_id = ?", photo.ID).Update("cover_photo_id", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("photo_id = ?", photo.ID).Delete(&model.PhotoTag{}); result.Error != nil {
+			return result.Error
+		}
+		if err := DeleteUnusedTags(tx); err != nil {
+			return err
+		}
+		// 相同内容的图片使用同一个文件
+		var count int64
+		if result := tx.Unscoped().Model(&model.Photo{}).Where("uri = ?", photo.Uri).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count > 0 || photo.Uri == "" {
+			return nil
+		}
+		if err := ts.ctx.GetImageManager().NewDeleteManager(photo.Uri).Delete(); err != nil {
+			ts.Error("delete photo %d files error: %v", photo.ID, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// PurgeExpired 彻底删除超过保存时间的图片，返回删除的数量
+func (ts *trashService) PurgeExpired() (int, error) {
+	retention := ts.ctx.GetConfig().GetTrashRetention()
+	if retention <= 0 {
+		return 0, nil
+	}
+
+	var photos []model.Photo
+	if result := ts.trashQuery().Where("deleted_at < ?", time.Now().Add(-retention)).Find(&photos); result.Error != nil {
+		return 0, result.Error
+	}
+	purged := 0
+	for _, photo := range photos {
+		if err := ts.purge(&photo); err != nil {
+			ts.Error("purge photo %d error: %v", photo.ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,223 @@
+package service_test
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type TrashServiceSuite struct {
+	suite.Suite
+	photoServ service.PhotoService
+	serv      service.TrashService
+	db        *database.SqliteDB
+	config    *config.Config
+}
+
+func TestTrashServiceSuite(t *testing.T) {
+	suite.Run(t, &TrashServiceSuite{})
+}
+
+func (s *TrashServiceSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	db, err := appgen.GenDatabase(appComponents)
+	s.Nil(err)
+
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.Nil(migrator.InitOrMigrate())
+
+	s.photoServ = service.NewPhotoService(ctx, db)
+	s.serv = service.NewTrashService(ctx, db)
+	s.db = db
+	s.config = appComponents.Config
+}
+
+func (s *TrashServiceSuite) TearDownSuite() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+func (s *TrashServiceSuite) SetupTest() {
+	s.db.Migrator().CreateTable(&model.Photo{})
+}
+
+func (s *TrashServiceSuite) TearDownTest() {
+	s.db.Migrator().DropTable(&model.Photo{})
+}
+
+// createPhoto 上传图片，返回图片数据
+func (s *TrashServiceSuite) createPhoto(data []byte) *model.Photo {
+	param := dto.CreatePhotoParam{UploadID: 1}
+	param.ImageSource = imagemanager.NewReaderSource(bytes.NewReader(data), "trash")
+	s.Len(s.photoServ.CreatePhoto([]dto.CreatePhotoParam{param}), 0)
+
+	var photo model.Photo
+	s.Nil(s.db.Order("id desc").First(&photo).Error)
+	return &photo
+}
+
+func (s *TrashServiceSuite) genImage() []byte {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	return buf.Bytes()
+}
+
+func (s *TrashServiceSuite) originalExists(uri string) bool {
+	fileUri := imagemanager.NewFileUri(s.config.GetFilesPath(), uri)
+	_, err := os.Stat(fileUri.GetOriginalFilePath())
+	return err == nil
+}
+
+func (s *TrashServiceSuite) TestTrashPage() {
+	photo := s.createPhoto(s.genImage())
+	s.createPhoto(s.genImage())
+
+	_, err := s.serv.TrashPage(dto.PageParam[struct{}]{})
+	s.Equal(application.ErrDataNotFound, err)
+
+	s.Nil(s.photoServ.DeletePhoto(photo.ID))
+	result, err := s.serv.TrashPage(dto.PageParam[struct{}]{PageNum: 1, PageSize: 10})
+	s.Nil(err)
+	s.Equal(int64(1), result.Total)
+	s.Equal(photo.ID, result.List[0].ID)
+	s.False(result.List[0].DeletedAt.IsZero())
+	s.NotNil(result.List[0].PurgeAt)
+	s.True(result.List[0].PurgeAt.After(result.List[0].DeletedAt))
+}
+
+func (s *TrashServiceSuite) TestRestorePhoto() {
+	data := s.genImage()
+	photo := s.createPhoto(data)
+	s.Nil(s.photoServ.DeletePhoto(photo.ID))
+
+	_, err := s.photoServ.GetPhotoById(photo.ID)
+	s.Equal(application.ErrDataNotFound, err)
+
+	photoDto, err := s.serv.RestorePhoto(photo.ID)
+	s.Nil(err)
+	s.Equal(photo.ID, photoDto.ID)
+	_, err = s.photoServ.GetPhotoById(photo.ID)
+	s.Nil(err)
+
+	// 不在回收站内
+	_, err = s.serv.RestorePhoto(photo.ID)
+	s.Equal(application.ErrDataNotFound, err)
+
+	// 删除后又上传了相同的图片
+	s.Nil(s.photoServ.DeletePhoto(photo.ID))
+	s.createPhoto(data)
+	_, err = s.serv.RestorePhoto(photo.ID)
+	appErr, ok := err.(*application.AppError)
+	s.True(ok)
+	s.Equal(http.StatusConflict, appErr.Code)
+}
+
+func (s *TrashServiceSuite) TestDeletePhoto() {
+	photo := s.createPhoto(s.genImage())
+	s.True(s.originalExists(photo.Uri))
+
+	// 不在回收站内的图片不能彻底删除
+	s.Equal(application.ErrDataNotFound, s.serv.DeletePhoto(photo.ID))
+
+	s.Nil(s.photoServ.DeletePhoto(photo.ID))
+	s.Nil(s.serv.DeletePhoto(photo.ID))
+	s.False(s.originalExists(photo.Uri))
+	for _, rendition := range imagemanager.Renditions {
+		fileUri := imagemanager.NewFileUri(s.config.GetFilesPath(), photo.Uri)
+		_, err := os.Stat(fileUri.GetRenditionFilePath(rendition))
+		s.True(os.IsNotExist(err))
+	}
+
+	var count int64
+	s.db.Unscoped().Model(&model.Photo{}).Where("id = ?", photo.ID).Count(&count)
+	s.Equal(int64(0), count)
+}
+
+func (s *TrashServiceSuite) TestDeleteSharedFile() {
+	data := s.genImage()
+	photo := s.createPhoto(data)
+	s.Nil(s.photoServ.DeletePhoto(photo.ID))
+	// 相同的图片使用同一个文件
+	other := s.createPhoto(data)
+	s.Equal(photo.Uri, other.Uri)
+
+	s.Nil(s.serv.DeletePhoto(photo.ID))
+	s.True(s.originalExists(other.Uri))
+}
+
+func (s *TrashServiceSuite) TestPurgeExpired() {
+	expired := s.createPhoto(s.genImage())
+	recent := s.createPhoto(s.genImage())
+	s.Nil(s.photoServ.DeletePhoto(expired.ID))
+	s.Nil(s.photoServ.DeletePhoto(recent.ID))
+
+	deletedAt := time.Now().Add(-s.config.GetTrashRetention() - time.Hour)
+	s.Nil(s.db.Unscoped().Model(expired).UpdateColumn("deleted_at", deletedAt).Error)
+
+	purged, err := s.serv.PurgeExpired()
+	s.Nil(err)
+	s.Equal(1, purged)
+	s.False(s.originalExists(expired.Uri))
+	s.True(s.originalExists(recent.Uri))
+
+	result, err := s.serv.TrashPage(dto.PageParam[struct{}]{})
+	s.Nil(err)
+	s.Equal(int64(1), result.Total)
+	s.Equal(recent.ID, result.List[0].ID)
+}
+
+// 上传回收站内已经存在的图片时，上传完成前不删除文件
+func (s *TrashServiceSuite) TestPurgeDuringUpload() {
+	data := s.genImage()
+	trashed := s.createPhoto(data)
+	s.Nil(s.photoServ.DeletePhoto(trashed.ID))
+	deletedAt := time.Now().Add(-s.config.GetTrashRetention() - time.Hour)
+	s.Nil(s.db.Unscoped().Model(trashed).UpdateColumn("deleted_at", deletedAt).Error)
+
+	// 上传的图片已经保存文件，保存数据前清理回收站
+	purgeResults := make(chan int, 1)
+	callbackName := "test:purge_photo"
+	s.Nil(s.db.Callback().Create().Before("gorm:create").Register(callbackName, func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*model.Photo); ok {
+			go func() {
+				purged, err := s.serv.PurgeExpired()
+				s.Nil(err)
+				purgeResults <- purged
+			}()
+			select {
+			case <-purgeResults:
+				s.Fail("purge should wait for upload")
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}))
+	uploaded := s.createPhoto(data)
+	s.Equal(1, <-purgeResults)
+	s.Nil(s.db.Callback().Create().Remove(callbackName))
+
+	s.Equal(trashed.Uri, uploaded.Uri)
+	s.True(s.originalExists(uploaded.Uri))
+	var count int64
+	s.Nil(s.db.Unscoped().Model(&model.Photo{}).Count(&count).Error)
+	s.Equal(int64(1), count)
+}
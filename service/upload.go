@@ -0,0 +1,295 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/imagemanager"
+	"github.com/follow1123/photos/logger"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/google/uuid"
+)
+
+const (
+	// 单个文件最大 4GB
+	UPLOAD_MAX_SIZE int64 = 4 << 30
+	// 超过时间没有继续上传的文件会被删除
+	UPLOAD_EXPIRATION = 24 * time.Hour
+	// 自动清理过期上传的间隔
+	UPLOAD_PURGE_INTERVAL = time.Hour
+
+	UPLOAD_META_FILENAME   = "filename"
+	UPLOAD_META_DESC       = "desc"
+	UPLOAD_META_PHOTO_DATE = "photoDate"
+
+	UPLOAD_PHOTO_DATE_FORMAT = "2006-01-02 15:04:05"
+
+	uploadInfoExt = ".json"
+)
+
+// UploadService 断点续传，未完成的文件保存在数据目录下，上传完成后创建图片
+type UploadService interface {
+	CreateUpload(dto.UploadParam) (*dto.UploadDto, error)
+	GetUpload(string) (*dto.UploadDto, error)
+	WriteUpload(string, int64, io.Reader) (*dto.UploadDto, error)
+	DeleteUpload(string) error
+	PurgeExpired() (int, error)
+}
+
+type uploadService struct {
+	logger.AppLogger
+	ctx       *application.AppContext
+	photoServ PhotoService
+
+	mu sync.Mutex
+	// 正在写入或删除的上传，同一个上传同时只能有一个请求处理
+	busy map[string]bool
+}
+
+func NewUploadService(ctx *application.AppContext, photoServ PhotoService) UploadService {
+	return &uploadService{
+		ctx:       ctx,
+		photoServ: photoServ,
+		busy:      make(map[string]bool),
+		AppLogger: *ctx.GetLogger(),
+	}
+}
+
+// uploadInfo 保存在上传的文件旁边，创建后不再修改，已上传的大小使用文件的大小
+type uploadInfo struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+func (us *uploadService) dataPath(id string) string {
+	return filepath.Join(us.ctx.GetConfig().GetUploadsPath(), id)
+}
+
+func (us *uploadService) infoPath(id string) string {
+	return us.dataPath(id) + uploadInfoExt
+}
+
+func (us *uploadService) acquire(id string) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.busy[id] {
+		return application.NewAppError(http.StatusLocked, "文件正在上传")
+	}
+	us.busy[id] = true
+	return nil
+}
+
+func (us *uploadService) release(id string) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	delete(us.busy, id)
+}
+
+func parseUploadPhotoDate(metadata map[string]string) (time.Time, error) {
+	value := metadata[UPLOAD_META_PHOTO_DATE]
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(UPLOAD_PHOTO_DATE_FORMAT, value, time.Local)
+}
+
+func (us *uploadService) CreateUpload(param dto.UploadParam) (*dto.UploadDto, error) {
+	if param.Length <= 0 {
+		return nil, application.NewAppError(http.StatusBadRequest, "文件大小必须大于 0")
+	}
+	if param.Length > UPLOAD_MAX_SIZE {
+		return nil, application.NewAppError(
+			http.StatusRequestEntityTooLarge, "文件大小 %d 超过了最大值 %d", param.Length, UPLOAD_MAX_SIZE)
+	}
+	if _, err := parseUploadPhotoDate(param.Metadata); err != nil {
+		return nil, application.NewAppError(http.StatusBadRequest, "photoDate 格式错误: %v", err)
+	}
+	if param.Metadata == nil {
+		param.Metadata = map[string]string{}
+	}
+
+	info := uploadInfo{ID: uuid.NewString(), Length: param.Length, Metadata: param.Metadata, CreatedAt: time.Now()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(us.dataPath(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	if err := os.WriteFile(us.infoPath(info.ID), data, 0644); err != nil {
+		us.removeUpload(info.ID)
+		return nil, err
+	}
+	return us.loadUpload(info.ID)
+}
+
+// loadUpload 读取上传的信息，不存在或已经过期时返回 404
+func (us *uploadService) loadUpload(id string) (*dto.UploadDto, error) {
+	// id 作为文件名，不能包含路径
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, application.ErrDataNotFound
+	}
+	data, err := os.ReadFile(us.infoPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, application.ErrDataNotFound
+		}
+		return nil, err
+	}
+	var info uploadInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(us.dataPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, application.ErrDataNotFound
+		}
+		return nil, err
+	}
+	expiresAt := stat.ModTime().Add(UPLOAD_EXPIRATION)
+	if time.Now().After(expiresAt) {
+		return nil, application.ErrDataNotFound
+	}
+	return &dto.UploadDto{
+		ID:        info.ID,
+		Length:    info.Length,
+		Offset:    stat.Size(),
+		Metadata:  info.Metadata,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (us *uploadService) GetUpload(id string) (*dto.UploadDto, error) {
+	return us.loadUpload(id)
+}
+
+// WriteUpload 从 offset 位置继续写入，连接中断时已经写入的数据会保留
+// 写入完成后创建图片，并删除上传的文件
+func (us *uploadService) WriteUpload(id string, offset int64, r io.Reader) (*dto.UploadDto, error) {
+	if err := us.acquire(id); err != nil {
+		return nil, err
+	}
+	defer us.release(id)
+
+	upload, err := us.loadUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return nil, application.NewAppError(
+			http.StatusConflict, "上传的位置 %d 和已上传的大小 %d 不一致", offset, upload.Offset)
+	}
+
+	file, err := os.OpenFile(us.dataPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	remaining := upload.Length - upload.Offset
+	written, err := io.Copy(file, io.LimitReader(r, remaining))
+	upload.Offset += written
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		us.Error("write upload %s error: %v", id, err)
+		return nil, err
+	}
+	if written == remaining {
+		// 超出文件大小的数据不写入
+		if _, err := io.ReadFull(r, make([]byte, 1)); err == nil {
+			return nil, application.NewAppError(http.StatusRequestEntityTooLarge, "上传的数据超过了文件大小 %d", upload.Length)
+		}
+	}
+
+	if upload.Completed() {
+		if err := us.completeUpload(upload); err != nil {
+			return nil, err
+		}
+	}
+	return upload, nil
+}
+
+// completeUpload 使用上传完成的文件创建图片，无论是否成功都删除上传的文件
+func (us *uploadService) completeUpload(upload *dto.UploadDto) error {
+	defer us.removeUpload(upload.ID)
+
+	name := upload.Metadata[UPLOAD_META_FILENAME]
+	if name == "" {
+		name = upload.ID
+	}
+	photoDate, _ := parseUploadPhotoDate(upload.Metadata)
+	failedResults := us.photoServ.CreatePhoto([]dto.CreatePhotoParam{{
+		Desc:        upload.Metadata[UPLOAD_META_DESC],
+		PhotoDate:   photoDate,
+		ImageSource: imagemanager.NewFileSource(us.dataPath(upload.ID), name),
+	}})
+	if len(failedResults) > 0 {
+		appError := application.NewAppError(http.StatusUnprocessableEntity, "创建图片失败: %s", failedResults[0].Message)
+		appError.Details = failedResults
+		return appError
+	}
+	return nil
+}
+
+func (us *uploadService) removeUpload(id string) {
+	for _, path := range []string{us.dataPath(id), us.infoPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			us.Error("remove upload file %s error: %v", path, err)
+		}
+	}
+}
+
+func (us *uploadService) DeleteUpload(id string) error {
+	if err := us.acquire(id); err != nil {
+		return err
+	}
+	defer us.release(id)
+
+	if _, err := us.loadUpload(id); err != nil {
+		return err
+	}
+	us.removeUpload(id)
+	return nil
+}
+
+// PurgeExpired 删除超过时间没有继续上传的文件，返回删除的上传数量
+func (us *uploadService) PurgeExpired() (int, error) {
+	entries, err := os.ReadDir(us.ctx.GetConfig().GetUploadsPath())
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, entry := range entries {
+		id := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(id, uploadInfoExt) {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return purged, err
+		}
+		if time.Since(stat.ModTime()) <= UPLOAD_EXPIRATION {
+			continue
+		}
+		if err := us.acquire(id); err != nil {
+			continue
+		}
+		us.removeUpload(id)
+		us.release(id)
+		purged++
+	}
+	return purged, nil
+}
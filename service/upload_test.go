@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/follow1123/photos/application"
+	"github.com/follow1123/photos/config"
+	"github.com/follow1123/photos/database"
+	"github.com/follow1123/photos/generator/appgen"
+	"github.com/follow1123/photos/generator/imagegen"
+	"github.com/follow1123/photos/model"
+	"github.com/follow1123/photos/model/dto"
+	"github.com/follow1123/photos/service"
+	"github.com/stretchr/testify/suite"
+)
+
+type UploadServiceSuite struct {
+	suite.Suite
+	serv   service.UploadService
+	db     *database.SqliteDB
+	config *config.Config
+}
+
+func TestUploadServiceSuite(t *testing.T) {
+	suite.Run(t, &UploadServiceSuite{})
+}
+
+func (s *UploadServiceSuite) SetupSuite() {
+	appComponents := &appgen.AppComponents{}
+	ctx, err := appgen.GenAppContext(appComponents)
+	s.Nil(err)
+	db, err := appgen.GenDatabase(appComponents)
+	s.Nil(err)
+
+	migrator, err := appgen.GenDBMigrator(appComponents)
+	s.Nil(err)
+	s.Nil(migrator.InitOrMigrate())
+
+	s.serv = service.NewUploadService(ctx, service.NewPhotoService(ctx, db))
+	s.db = db
+	s.config = appComponents.Config
+}
+
+func (s *UploadServiceSuite) TearDownSuite() {
+	session, err := s.db.DB.DB()
+	s.Nil(err)
+	session.Close()
+	s.config.DeletePath()
+}
+
+func (s *UploadServiceSuite) SetupTest() {
+	s.db.Migrator().CreateTable(&model.Photo{})
+	s.Nil(s.db.InitFullTextSearch())
+}
+
+func (s *UploadServiceSuite) TearDownTest() {
+	s.db.Migrator().DropTable(&model.Photo{}, database.FTS_TABLE)
+}
+
+func (s *UploadServiceSuite) assertAppError(err error, code int) {
+	var appError *application.AppError
+	s.True(errors.As(err, &appError), err)
+	s.Equal(code, appError.Code)
+}
+
+func (s *UploadServiceSuite) genImage() []byte {
+	buf := new(bytes.Buffer)
+	_, err := imagegen.GenImage(buf)
+	s.Nil(err)
+	return buf.Bytes()
+}
+
+func (s *UploadServiceSuite) TestCreateUpload() {
+	scenarios := []struct {
+		param        dto.UploadParam
+		expectedCode int
+	}{
+		{dto.UploadParam{Length: 10}, 0},
+		{dto.UploadParam{Length: 10, Metadata: map[string]string{"photoDate": "2024-01-02 15:04:05"}}, 0},
+		{dto.UploadParam{Length: 0}, http.StatusBadRequest},
+		{dto.UploadParam{Length: service.UPLOAD_MAX_SIZE + 1}, http.StatusRequestEntityTooLarge},
+		{dto.UploadParam{Length: 10, Metadata: map[string]string{"photoDate": "2024-01-02"}}, http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		upload, err := s.serv.CreateUpload(scenario.param)
+		if scenario.expectedCode != 0 {
+			s.assertAppError(err, scenario.expectedCode)
+			continue
+		}
+		s.Nil(err)
+		s.Equal(scenario.param.Length, upload.Length)
+		s.Equal(int64(0), upload.Offset)
+		s.False(upload.Completed())
+		s.True(upload.ExpiresAt.After(time.Now()))
+		s.Nil(s.serv.DeleteUpload(upload.ID))
+	}
+}
+
+func (s *UploadServiceSuite) TestWriteUpload() {
+	img := s.genImage()
+	metadata := map[string]string{"filename": "chunked.png", "desc": "tus", "photoDate": "2024-01-02 15:04:05"}
+	upload, err := s.serv.CreateUpload(dto.UploadParam{Length: int64(len(img)), Metadata: metadata})
+	s.Nil(err)
+
+	// 第一次上传中断，只写入了一半
+	half := int64(len(img) / 2)
+	upload, err = s.serv.WriteUpload(upload.ID, 0, bytes.NewReader(img[:half]))
+	s.Nil(err)
+	s.Equal(half, upload.Offset)
+
+	upload, err = s.serv.GetUpload(upload.ID)
+	s.Nil(err)
+	s.Equal(half, upload.Offset)
+	s.Equal(metadata, upload.Metadata)
+
+	// 位置不一致
+	_, err = s.serv.WriteUpload(upload.ID, 0, bytes.NewReader(img))
+	s.assertAppError(err, http.StatusConflict)
+
+	// 继续上传剩下的数据，完成后创建图片
+	upload, err = s.serv.WriteUpload(upload.ID, half, bytes.NewReader(img[half:]))
+	s.Nil(err)
+	s.True(upload.Completed())
+
+	var photo model.Photo
+	s.Nil(s.db.Take(&photo).Error)
+	s.Equal("chunked.png", photo.FileName)
+	s.Contains(photo.Desc, "tus")
+	s.Equal(time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local), photo.PhotoDate.Local())
+
+	// 上传的文件已经删除
+	_, err = s.serv.GetUpload(upload.ID)
+	s.Equal(application.ErrDataNotFound, err)
+	entries, err := os.ReadDir(s.config.GetUploadsPath())
+	s.Nil(err)
+	s.Empty(entries)
+
+	// 重复的图片创建失败
+	upload, err = s.serv.CreateUpload(dto.UploadParam{Length: int64(len(img))})
+	s.Nil(err)
+	_, err = s.serv.WriteUpload(upload.ID, 0, bytes.NewReader(img))
+	s.assertAppError(err, http.StatusUnprocessableEntity)
+	_, err = s.serv.GetUpload(upload.ID)
+	s.Equal(application.ErrDataNotFound, err)
+}
+
+func (s *UploadServiceSuite) TestWriteUploadTooLarge() {
+	upload, err := s.serv.CreateUpload(dto.UploadParam{Length: 4})
+	s.Nil(err)
+
+	_, err = s.serv.WriteUpload(upload.ID, 0, bytes.NewReader([]byte("abcdef")))
+	s.assertAppError(err, http.StatusRequestEntityTooLarge)
+
+	// 超出的数据不写入
+	data, err := os.ReadFile(filepath.Join(s.config.GetUploadsPath(), upload.ID))
+	s.Nil(err)
+	s.Equal("abcd", string(data))
+	s.Nil(s.serv.DeleteUpload(upload.ID))
+}
+
+func (s *UploadServiceSuite) TestDeleteUpload() {
+	upload, err := s.serv.CreateUpload(dto.UploadParam{Length: 10})
+	s.Nil(err)
+
+	s.Nil(s.serv.DeleteUpload(upload.ID))
+	s.Equal(application.ErrDataNotFound, s.serv.DeleteUpload(upload.ID))
+	_, err = s.serv.WriteUpload(upload.ID, 0, bytes.NewReader([]byte("a")))
+	s.Equal(application.ErrDataNotFound, err)
+
+	// id 不能作为路径使用
+	_, err = s.serv.GetUpload("../photos.db")
+	s.Equal(application.ErrDataNotFound, err)
+}
+
+func (s *UploadServiceSuite) TestPurgeExpired() {
+	expired, err := s.serv.CreateUpload(dto.UploadParam{Length: 10})
+	s.Nil(err)
+	active, err := s.serv.CreateUpload(dto.UploadParam{Length: 10})
+	s.Nil(err)
+
+	lastWrite := time.Now().Add(-service.UPLOAD_EXPIRATION - time.Minute)
+	s.Nil(os.Chtimes(filepath.Join(s.config.GetUploadsPath(), expired.ID), lastWrite, lastWrite))
+
+	_, err = s.serv.GetUpload(expired.ID)
+	s.Equal(application.ErrDataNotFound, err)
+
+	purged, err := s.serv.PurgeExpired()
+	s.Nil(err)
+	s.Equal(1, purged)
+
+	entries, err := os.ReadDir(s.config.GetUploadsPath())
+	s.Nil(err)
+	s.Len(entries, 2)
+	_, err = s.serv.GetUpload(active.ID)
+	s.Nil(err)
+	s.Nil(s.serv.DeleteUpload(active.ID))
+}
@@ -1,6 +1,6 @@
 /**
  * @typedef {Object} Options
- * @property {string} desc
+ * @property {string} query 查询语句，如 tag:beach date:2023-06..2023-08 -tag:private
  */
 
 export default class Condition {
@@ -17,10 +17,9 @@ export default class Condition {
   }
 
   /**
-   * TODO: 后面换成复杂条件
    * @returns {string}
    */
   build() {
-    return `&desc=${this.#opts.desc}`;
+    return `&query=${encodeURIComponent(this.#opts.query)}`;
   }
 }
@@ -31,7 +31,7 @@ export default class SearchBar extends HTMLElement {
         let input = /** @type {HTMLInputElement} */ (e.target);
         if (!input.value || input.value === "") return;
         console.log("Enter pressed!, value: ", input.value);
-        eventBus.emit("query", new Condition({ desc: input.value }));
+        eventBus.emit("query", new Condition({ query: input.value }));
       }
     });
   }
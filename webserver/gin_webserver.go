@@ -47,7 +47,15 @@ func (gws *GinWebServer) InitMiddleware() {
 	gws.UseLoggerMiddleware()
 	gws.UseRecoveryMiddleware()
 	gws.UseErrorHandlerMiddleware()
-	gws.engine.Use(cors.Default())
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	// 断点续传使用的请求头和响应头
+	corsConfig.AddAllowHeaders("Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset")
+	corsConfig.AddExposeHeaders(
+		"Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+		"Location", "Upload-Offset", "Upload-Length", "Upload-Metadata", "Upload-Expires",
+	)
+	gws.engine.Use(cors.New(corsConfig))
 }
 
 func (gws *GinWebServer) Start() {